@@ -0,0 +1,300 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/gitutil"
+	"fuchsia.googlesource.com/jiri/project"
+)
+
+var branchDeleteForceFlag bool
+
+func init() {
+	cmdBranchDelete.Flags.BoolVar(&branchDeleteForceFlag, "force", false, "Delete the branch even if it is not merged into master.")
+}
+
+// cmdBranch represents the "jiri branch" command.
+var cmdBranch = &cmdline.Command{
+	Name:  "branch",
+	Short: "Manage local branches across projects",
+	Long: `
+Manage local branches across all jiri projects.  A branch of a given name may
+exist in any number of projects; the subcommands operate on all of them at once.
+`,
+	Children: []*cmdline.Command{cmdBranchCheckout, cmdBranchDelete, cmdBranchList, cmdBranchPruneMerged},
+}
+
+// cmdBranchList represents the "jiri branch list" command.
+var cmdBranchList = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runBranchList),
+	Name:   "list",
+	Short:  "List local branches and the projects that have them",
+	Long: `
+Lists every local branch other than master, followed by the projects that have
+it.  Each project is marked as merged if all commits on the branch are on its
+master branch, and as current if the branch is checked out.
+`,
+}
+
+// cmdBranchCheckout represents the "jiri branch checkout" command.
+var cmdBranchCheckout = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runBranchCheckout),
+	Name:   "checkout",
+	Short:  "Check out a branch in all projects that have it",
+	Long: `
+Checks out the given branch in every project that has it, and checks out master
+in all other projects.  Fails without changing anything if a project that would
+switch branches has uncommitted changes.
+`,
+	ArgsName: "<branch>",
+	ArgsLong: "<branch> is the branch to check out.",
+}
+
+// cmdBranchDelete represents the "jiri branch delete" command.
+var cmdBranchDelete = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runBranchDelete),
+	Name:   "delete",
+	Short:  "Delete a branch in all projects that have it",
+	Long: `
+Deletes the given branch in every project that has it.  Projects that have the
+branch checked out are switched to master first.  Unless -force is given, fails
+without deleting anything if the branch is not merged into master in some
+project.
+`,
+	ArgsName: "<branch>",
+	ArgsLong: "<branch> is the branch to delete.",
+}
+
+// cmdBranchPruneMerged represents the "jiri branch prune-merged" command.
+var cmdBranchPruneMerged = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runBranchPruneMerged),
+	Name:   "prune-merged",
+	Short:  "Delete all branches that are merged into master",
+	Long: `
+Deletes every local branch, other than master, whose commits are all on master.
+Projects that have such a branch checked out are switched to master first,
+unless they have uncommitted changes, in which case the branch is kept.
+`,
+}
+
+type statesByName []*project.ProjectState
+
+func (s statesByName) Len() int      { return len(s) }
+func (s statesByName) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s statesByName) Less(i, j int) bool {
+	if s[i].Project.Name != s[j].Project.Name {
+		return s[i].Project.Name < s[j].Project.Name
+	}
+	return s[i].Project.Path < s[j].Project.Path
+}
+
+// sortedStates returns the given project states sorted by project name.
+func sortedStates(states map[project.ProjectKey]*project.ProjectState) []*project.ProjectState {
+	var sorted statesByName
+	for _, state := range states {
+		sorted = append(sorted, state)
+	}
+	sort.Sort(sorted)
+	return sorted
+}
+
+// findBranch returns the state of the given branch in the given project, or
+// nil if the project doesn't have it.
+func findBranch(state *project.ProjectState, branch string) *project.BranchState {
+	for i := range state.Branches {
+		if state.Branches[i].Name == branch {
+			return &state.Branches[i]
+		}
+	}
+	return nil
+}
+
+// deleteBranch deletes the given branch of the given project, along with its
+// jiri metadata, first checking out master if the branch is current.
+func deleteBranch(jirix *jiri.X, state *project.ProjectState, branch string) error {
+	git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(state.Project.Path))
+	if state.CurrentBranch == branch {
+		if err := git.CheckoutBranch("master"); err != nil {
+			return err
+		}
+		state.CurrentBranch = "master"
+	}
+	if err := git.DeleteBranch(branch, gitutil.ForceOpt(true)); err != nil {
+		return err
+	}
+	return jirix.NewSeq().RemoveAll(filepath.Join(state.Project.Path, jiri.ProjectMetaDir, branch)).Done()
+}
+
+// isMerged returns true if all commits on the given branch of the project of
+// state are known to be on master.  Whether the branch is merged is unknown for
+// a detached head, which is listed as "(HEAD detached at ...)", and if the
+// commits can't be counted, e.g. because the project has no master branch.
+func isMerged(jirix *jiri.X, state *project.ProjectState, branch string) bool {
+	if strings.HasPrefix(branch, "(") {
+		return false
+	}
+	git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(state.Project.Path))
+	unmerged, err := git.CountCommits(branch, "master")
+	return err == nil && unmerged == 0
+}
+
+func runBranchList(jirix *jiri.X, args []string) error {
+	if len(args) != 0 {
+		return jirix.UsageErrorf("unexpected arguments")
+	}
+	states, err := project.GetProjectStates(jirix, false)
+	if err != nil {
+		return err
+	}
+	byBranch := map[string][]string{}
+	for _, state := range sortedStates(states) {
+		for _, branch := range state.Branches {
+			if branch.Name == "master" || strings.HasPrefix(branch.Name, "(") {
+				continue
+			}
+			status := "unmerged"
+			if isMerged(jirix, state, branch.Name) {
+				status = "merged"
+			}
+			if state.CurrentBranch == branch.Name {
+				status += ", current"
+			}
+			byBranch[branch.Name] = append(byBranch[branch.Name], fmt.Sprintf("%s (%s)", state.Project.Name, status))
+		}
+	}
+	var branches []string
+	for branch := range byBranch {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	for _, branch := range branches {
+		fmt.Fprintf(jirix.Stdout(), "%s:\n", branch)
+		for _, line := range byBranch[branch] {
+			fmt.Fprintf(jirix.Stdout(), "  %s\n", line)
+		}
+	}
+	return nil
+}
+
+func runBranchCheckout(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("expected one branch, got %d arguments", len(args))
+	}
+	branch := args[0]
+	states, err := project.GetProjectStates(jirix, true)
+	if err != nil {
+		return err
+	}
+	sorted := sortedStates(states)
+	found := false
+	var dirty []string
+	for _, state := range sorted {
+		target := "master"
+		if findBranch(state, branch) != nil {
+			target, found = branch, true
+		}
+		if state.CurrentBranch != target && state.HasUncommitted {
+			dirty = append(dirty, state.Project.Name)
+		}
+	}
+	if !found {
+		return fmt.Errorf("no project has branch %q", branch)
+	}
+	if len(dirty) > 0 {
+		return fmt.Errorf("projects with uncommitted changes: %v", strings.Join(dirty, ", "))
+	}
+	for _, state := range sorted {
+		target := "master"
+		if findBranch(state, branch) != nil {
+			target = branch
+		}
+		if state.CurrentBranch == target {
+			continue
+		}
+		git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(state.Project.Path))
+		if err := git.CheckoutBranch(target); err != nil {
+			return fmt.Errorf("error checking out %q in project %q: %v", target, state.Project.Name, err)
+		}
+	}
+	return nil
+}
+
+func runBranchDelete(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("expected one branch, got %d arguments", len(args))
+	}
+	branch := args[0]
+	if branch == "master" {
+		return fmt.Errorf("cannot delete the master branch")
+	}
+	states, err := project.GetProjectStates(jirix, true)
+	if err != nil {
+		return err
+	}
+	var matches []*project.ProjectState
+	var unmerged, dirty []string
+	for _, state := range sortedStates(states) {
+		b := findBranch(state, branch)
+		if b == nil {
+			continue
+		}
+		matches = append(matches, state)
+		if !branchDeleteForceFlag && !isMerged(jirix, state, b.Name) {
+			unmerged = append(unmerged, state.Project.Name)
+		}
+		if state.CurrentBranch == branch && state.HasUncommitted {
+			dirty = append(dirty, state.Project.Name)
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no project has branch %q", branch)
+	}
+	if len(unmerged) > 0 {
+		return fmt.Errorf("branch %q is not merged into master in projects: %v; use -force to delete it anyway", branch, strings.Join(unmerged, ", "))
+	}
+	if len(dirty) > 0 {
+		return fmt.Errorf("projects with uncommitted changes: %v", strings.Join(dirty, ", "))
+	}
+	for _, state := range matches {
+		if err := deleteBranch(jirix, state, branch); err != nil {
+			return fmt.Errorf("error deleting branch %q in project %q: %v", branch, state.Project.Name, err)
+		}
+		fmt.Fprintf(jirix.Stdout(), "Deleted branch %q in project %q\n", branch, state.Project.Name)
+	}
+	return nil
+}
+
+func runBranchPruneMerged(jirix *jiri.X, args []string) error {
+	if len(args) != 0 {
+		return jirix.UsageErrorf("unexpected arguments")
+	}
+	states, err := project.GetProjectStates(jirix, true)
+	if err != nil {
+		return err
+	}
+	for _, state := range sortedStates(states) {
+		for _, branch := range state.Branches {
+			if branch.Name == "master" || strings.HasPrefix(branch.Name, "(") || !isMerged(jirix, state, branch.Name) {
+				continue
+			}
+			if state.CurrentBranch == branch.Name && state.HasUncommitted {
+				fmt.Fprintf(jirix.Stderr(), "Keeping branch %q in project %q: uncommitted changes\n", branch.Name, state.Project.Name)
+				continue
+			}
+			if err := deleteBranch(jirix, state, branch.Name); err != nil {
+				return fmt.Errorf("error deleting branch %q in project %q: %v", branch.Name, state.Project.Name, err)
+			}
+			fmt.Fprintf(jirix.Stdout(), "Deleted branch %q in project %q\n", branch.Name, state.Project.Name)
+		}
+	}
+	return nil
+}
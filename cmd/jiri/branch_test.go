@@ -0,0 +1,123 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri/gitutil"
+	"fuchsia.googlesource.com/jiri/jiritest"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+func TestBranch(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	projects := addProjects(t, fake)
+	a, b, c := projects[0], projects[1], projects[2]
+	currentBranch := func(dir string) string {
+		branch, err := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(dir)).CurrentBranchName()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return branch
+	}
+	gitA := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(a.Path))
+	gitB := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(b.Path))
+	gitC := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(c.Path))
+
+	// "feature" is merged in a and has a new commit in b; "stale" is merged
+	// in c.
+	if err := gitA.CreateBranch("feature"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitB.CreateAndCheckoutBranch("feature"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(b.Path, "file"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitB.CommitFile("file", "add file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitC.CreateBranch("stale"); err != nil {
+		t.Fatal(err)
+	}
+	// A detached head is not a branch.
+	revision, err := gitC.CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gitC.CheckoutBranch(revision); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &stdout})
+	defer func() { branchDeleteForceFlag = false }()
+
+	if err := runBranchList(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "feature:\n  r.a (merged)\n  r.b (unmerged, current)\nstale:\n  r.c (merged)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got list output %q, want %q", got, want)
+	}
+
+	if err := gitB.CheckoutBranch("master"); err != nil {
+		t.Fatal(err)
+	}
+	if err := runBranchCheckout(fake.X, []string{"feature"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct{ dir, branch string }{
+		{a.Path, "feature"},
+		{b.Path, "feature"},
+		{c.Path, "master"},
+	} {
+		if got := currentBranch(test.dir); got != test.branch {
+			t.Errorf("%v: got branch %q, want %q", test.dir, got, test.branch)
+		}
+	}
+	if err := runBranchCheckout(fake.X, []string{"missing"}); err == nil {
+		t.Errorf("checkout of missing branch succeeded")
+	}
+
+	// Deleting an unmerged branch requires -force.
+	if err := runBranchDelete(fake.X, []string{"feature"}); err == nil || !strings.Contains(err.Error(), "-force") {
+		t.Errorf("got error %v, want unmerged error", err)
+	}
+	if got := currentBranch(a.Path); got != "feature" {
+		t.Errorf("got branch %q in %v after failed delete, want feature", got, a.Path)
+	}
+	branchDeleteForceFlag = true
+	if err := runBranchDelete(fake.X, []string{"feature"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{a.Path, b.Path} {
+		if got := currentBranch(p); got != "master" {
+			t.Errorf("%v: got branch %q after delete, want master", p, got)
+		}
+	}
+
+	stdout.Reset()
+	if err := runBranchPruneMerged(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "Deleted branch \"stale\" in project \"r.c\"\n"; got != want {
+		t.Errorf("got prune output %q, want %q", got, want)
+	}
+	stdout.Reset()
+	if err := runBranchList(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); got != "" {
+		t.Errorf("got branches left after prune: %q", got)
+	}
+}
@@ -82,6 +82,7 @@ func init() {
 	cmdCLPatch.Flags.BoolVar(&deleteFlag, "delete", false, "Delete the existing branch if already exists")
 	cmdCLPatch.Flags.BoolVar(&forceFlag, "force", false, "Use force when deleting the existing branch")
 	cmdCLPatch.Flags.StringVar(&hostFlag, "host", "", `Gerrit host to use.  Defaults to gerrit host specified in manifest.`)
+	cmdCLPatch.CompleteFlags = map[string]cmdline.CompleteFunc{"branch": completeBranches}
 	cmdCLSync.Flags.StringVar(&remoteBranchFlag, "remote-branch", "master", `Name of the remote branch the CL pertains to, without the leading "origin/".`)
 }
 
@@ -176,8 +177,12 @@ func cleanupCL(jirix *jiri.X, branches []string) (e error) {
 		}
 		return nil
 	}, &e)
-	if err := git.FetchRefspec("origin", remoteBranchFlag); err != nil {
-		return err
+	// In offline mode, check for merged changes against the remote branch as
+	// last fetched.
+	if !jirix.Offline {
+		if err := git.FetchRefspec("origin", remoteBranchFlag); err != nil {
+			return err
+		}
 	}
 	s := jirix.NewSeq()
 	for _, branch := range branches {
@@ -608,6 +613,9 @@ func runCLMail(jirix *jiri.X, args []string) error {
 // runCLUpload is a wrapper that sets up and runs a review instance across
 // multiple projects.
 func runCLUpload(jirix *jiri.X, _ []string) error {
+	if err := jirix.RequireOnline("cl upload"); err != nil {
+		return err
+	}
 	mp, err := initForMultiPart(jirix)
 	if err != nil {
 		return err
@@ -1559,6 +1567,9 @@ func runCLPatch(jirix *jiri.X, args []string) error {
 	if expected, got := 1, len(args); expected != got {
 		return jirix.UsageErrorf("unexpected number of arguments: expected %v, got %v", expected, got)
 	}
+	if err := jirix.RequireOnline("cl patch"); err != nil {
+		return err
+	}
 	return patchCL(jirix, args[0])
 }
 
@@ -1634,11 +1645,16 @@ func syncCL(jirix *jiri.X) (e error) {
 	}
 	branches = append(branches, originalBranch)
 
-	// Sync from upstream.
+	// Sync from upstream.  In offline mode, sync from the remote branch as
+	// last fetched.
 	if err := git.CheckoutBranch(branches[0]); err != nil {
 		return err
 	}
-	if err := git.Pull("origin", branches[0]); err != nil {
+	if jirix.Offline {
+		if err := git.Merge("origin/" + branches[0]); err != nil {
+			return err
+		}
+	} else if err := git.Pull("origin", branches[0]); err != nil {
 		return err
 	}
 
@@ -8,6 +8,7 @@
 package main
 
 import (
+	"os"
 	"runtime"
 
 	"fuchsia.googlesource.com/jiri/cmdline"
@@ -22,7 +23,9 @@ func init() {
 }
 
 func main() {
-	cmdline.Main(cmdRoot)
+	code := cmdline.Run(cmdRoot)
+	savePluginCache()
+	os.Exit(code)
 }
 
 // cmdRoot represents the root of the jiri tool.
@@ -37,15 +40,41 @@ func newCmdRoot() *cmdline.Command {
 		Short: "Multi-purpose tool for multi-repo development",
 		Long: `
 Command jiri is a multi-purpose tool for multi-repo development.
+
+With -offline, or if the JIRI_OFFLINE environment variable is set to true,
+jiri doesn't access the network: git operations use only local objects, and
+commands that need the network fail.
+
+Flags can also be set by environment variables named after the command and the
+flag, e.g. JIRI_UPDATE_GC=true for "jiri update -gc"; help shows the variable of
+each flag.  Default flag values and aliases can be set in the root and user
+config files; see "jiri help config".
 `,
-		LookPath: true,
+		LookPath:      true,
+		EnvFlags:      true,
+		NoEnvFlags:    []string{"offline"}, // See jiri.OfflineEnv.
+		FlagDefaults:  flagDefaults,
+		ExpandAlias:   expandAlias,
+		ExternalShort: pluginShort,
 		Children: []*cmdline.Command{
+			cmdAm,
+			cmdBranch,
 			cmdCL,
+			cmdCompletion,
+			cmdConfig,
+			cmdDiff,
+			cmdFormatPatch,
+			cmdGrep,
 			cmdImport,
+			cmdInit,
+			cmdLog,
+			cmdLogs,
+			cmdPlugins,
 			cmdProject,
 			cmdRebuild,
 			cmdSnapshot,
 			cmdUpdate,
+			cmdVersion,
 			cmdWhich,
 		},
 		Topics: []cmdline.Topic{
@@ -66,6 +95,8 @@ looks like this:
  [root]                              # root directory (name picked by user)
  [root]/.jiri_root                   # root metadata directory
  [root]/.jiri_root/bin               # contains tool binaries (jiri, etc.)
+ [root]/.jiri_root/config            # root config file
+ [root]/.jiri_root/tool_cache        # records what each tool was built from
  [root]/.jiri_root/update_history    # contains history of update snapshots
  [root]/.manifest                    # contains jiri manifests
  [root]/[project1]                   # project directory (name picked by user)
@@ -158,11 +189,25 @@ Manifests have the following XML schema:
     />
     ...
   </projects>
+  <packages>
+    <package name="my-toolchain"
+             path="prebuilt/toolchain"
+             url="https://example.com/toolchain.tar.gz"
+             sha256="e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+             format="tar.gz"
+    />
+    ...
+  </packages>
   <tools>
     <tool name="jiri"
           package="fuchsia.googlesource.com/jiri"
           project="release.go.jiri"
     />
+    <tool name="my-script"
+          build="make my-script"
+          output="out/my-script"
+          project="my-project"
+    />
     ...
   </tools>
 </manifest>
@@ -216,23 +261,66 @@ specified, then running "jiri cl upload" will upload a CL to this Gerrit host.
 
 * githooks (optional) - The path (relative to $JIRI_ROOT) of a directory
 containing git hooks that will be installed in the projects .git/hooks
-directory during each update.
+directory during each update.  Hooks installed by jiri run before any hook of
+the same name found in the projects .git/hooks.local directory, which is where
+developers should install their own hooks.  Existing hooks that were not
+installed by jiri are moved there automatically.
 
 * runhook (optional) - The path (relate to $JIRI_ROOT) of a script that will be
 run during each update.
 
+The <package> tags describe prebuilt archives, such as toolchains and SDKs, that
+are downloaded and extracted during each update instead of being checked out
+from a repository.  They are configured via the following attributes:
+
+* name (required) - The name of the package.
+
+* path (required) - The directory where the package will be extracted, relative
+to the jiri root.  Any existing contents of the directory are replaced.
+
+* url (required) - The url of the archive.  Both "http(s)://" and "file://" urls
+are supported.
+
+* sha256 (required) - The hex-encoded SHA-256 digest of the archive.  The update
+fails if the downloaded archive does not match.
+
+* format (optional) - The format of the archive, either "tar.gz" or "zip".
+Defaults to the format implied by the extension of the url.
+
+Packages are only downloaded again when their url, sha256 or format changes.
+Like projects, packages that are removed from the manifest are deleted by "jiri
+update -gc".
+
 The <tool> tags describe the tools that will be compiled and installed in
-$JIRI_ROOT/.jiri_root/bin after each update.  The tools must be written in go,
-and are identified by their package name and the project that contains their
-code.  They are configured via the following attributes:
+$JIRI_ROOT/.jiri_root/bin after each update.  Go tools are identified by their
+package name and the project that contains their code; other tools specify a
+build command and the artifact it produces instead.  They are configured via the
+following attributes:
 
 * name (required) - The name of the binary that will be installed in
   JIRI_ROOT/.jiri_root/bin
 
-* package (required) - The name of the Go package that will be passed to "go
-  build".
+* package (required for Go tools) - The name of the Go package that will be
+  passed to "go build".
+
+* build (required for other tools) - A shell command that builds the tool.  It
+  is run from the root of a temporary copy of the project, so that build
+  products never end up in the project itself.
+
+* output (required with build) - The path of the artifact produced by the build
+  command, relative to the project root.  It is installed under the tool name.
 
 * project (required) - The name of the project that contains the source code
   for the tool.
+
+* plugin (optional) - Set to "true" if the tool is a jiri plugin, i.e. a
+  subcommand of jiri.  The name of a plugin must start with "jiri-"; see "jiri
+  help plugins".
+
+If the project contains a go.mod file at its root, the tools in it are built in
+module mode, with module and build caches kept in $JIRI_ROOT/.jiri_root/go.
+Modules with a vendor directory are built with "-mod=vendor", all others with
+"-mod=readonly".  Tools in projects without a go.mod file are built in GOPATH
+mode.
 `,
 }
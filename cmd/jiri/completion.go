@@ -0,0 +1,111 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/gitutil"
+	"fuchsia.googlesource.com/jiri/project"
+)
+
+// cmdCompletion represents the "jiri completion" command.
+var cmdCompletion = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runCompletion),
+	Name:   "completion",
+	Short:  "Print a shell completion script",
+	Long: `
+Command "completion" prints a script that sets up completion of jiri commands,
+flags and args for the given shell.  Project names, snapshot labels and branch
+names are completed where the commands expect them.
+
+Example:
+  $ source <(jiri completion bash)
+  $ jiri completion zsh > "${fpath[1]}/_jiri"
+  $ jiri completion fish > ~/.config/fish/completions/jiri.fish
+`,
+	ArgsName: "<shell>",
+	ArgsLong: "<shell> is one of bash, fish or zsh.",
+	CompleteArgs: func(env *cmdline.Env, args []string) []string {
+		if len(args) > 0 {
+			return nil
+		}
+		return []string{"bash", "fish", "zsh"}
+	},
+}
+
+func runCompletion(env *cmdline.Env, args []string) error {
+	if len(args) != 1 {
+		return env.UsageErrorf("wrong number of arguments")
+	}
+	return cmdline.CompletionScript(env.Stdout, cmdRoot, args[0])
+}
+
+// completeProjects returns the names of the local projects.
+func completeProjects(env *cmdline.Env, args []string) []string {
+	jirix, err := jiri.NewX(env)
+	if err != nil {
+		return nil
+	}
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, p := range projects {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// completeSnapshotLabels returns the known snapshot labels.
+func completeSnapshotLabels(env *cmdline.Env, args []string) []string {
+	labels, _ := snapshotLabelCompletions(env, args)
+	return labels
+}
+
+// completeSnapshotFiles returns the paths of the symbolic links to the latest
+// snapshot of each known label.
+func completeSnapshotFiles(env *cmdline.Env, args []string) []string {
+	labels, snapshotDir := snapshotLabelCompletions(env, args)
+	var files []string
+	for _, label := range labels {
+		files = append(files, filepath.Join(snapshotDir, label))
+	}
+	return files
+}
+
+// snapshotLabelCompletions returns the known snapshot labels, and the snapshot
+// directory that holds them, for the first arg of a command.
+func snapshotLabelCompletions(env *cmdline.Env, args []string) ([]string, string) {
+	if len(args) > 0 {
+		return nil, ""
+	}
+	jirix, err := jiri.NewX(env)
+	if err != nil {
+		return nil, ""
+	}
+	snapshotDir, err := getSnapshotDir(jirix)
+	if err != nil {
+		return nil, ""
+	}
+	labels, _ := snapshotLabels(snapshotDir)
+	return labels, snapshotDir
+}
+
+// completeBranches returns the local branches of the current project.
+func completeBranches(env *cmdline.Env, args []string) []string {
+	jirix, err := jiri.NewX(env)
+	if err != nil {
+		return nil
+	}
+	branches, _, err := gitutil.New(jirix.NewSeq()).GetBranches()
+	if err != nil {
+		return nil
+	}
+	return branches
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/jiritest"
+)
+
+// TestCompletion checks that project names, snapshot labels and snapshot files
+// are completed.
+func TestCompletion(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	addProjects(t, fake)
+	if err := runSnapshotCreate(fake.X, []string{"stable"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The completers create their own jiri.X, which prepends to PATH.
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	defer os.Setenv(jiri.RootEnv, os.Getenv(jiri.RootEnv))
+	if err := os.Setenv(jiri.RootEnv, fake.X.Root); err != nil {
+		t.Fatal(err)
+	}
+	env := cmdline.EnvFromOS()
+	env.Stdout, env.Stderr = ioutil.Discard, ioutil.Discard
+
+	names := map[string]bool{}
+	for _, name := range completeProjects(env, nil) {
+		names[name] = true
+	}
+	for _, want := range []string{"r.a", "r.b", "r.c"} {
+		if !names[want] {
+			t.Errorf("project %q not completed, got %v", want, names)
+		}
+	}
+	if got, want := completeSnapshotLabels(env, nil), []string{"stable"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got labels %v, want %v", got, want)
+	}
+	if got := completeSnapshotLabels(env, []string{"stable"}); got != nil {
+		t.Errorf("got labels %v for a second arg, want none", got)
+	}
+
+	files := completeSnapshotFiles(env, nil)
+	snapshotDir, err := getSnapshotDir(fake.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(snapshotDir, "stable")}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("got snapshot files %v, want %v", files, want)
+	}
+	if err := runSnapshotCheckout(fake.X, files); err != nil {
+		t.Errorf("checkout of the completed snapshot file failed: %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+)
+
+var configUserFlag bool
+
+func init() {
+	cmdConfig.Flags.BoolVar(&configUserFlag, "user", false, "Use the user config file, rather than the root config file.")
+}
+
+// cmdConfig represents the "jiri config" command.
+var cmdConfig = &cmdline.Command{
+	Name:  "config",
+	Short: "Manage jiri config files",
+	Long: `
+Manage the settings of the jiri config files.  Settings are read from the root
+config file, $JIRI_ROOT/.jiri_root/config, and the user config file,
+$XDG_CONFIG_HOME/jiri/config, where XDG_CONFIG_HOME defaults to ~/.config.
+Settings of the root config file take precedence.
+
+Besides settings such as "cache.dir", the config files provide default values
+for the flags of jiri commands.  The key of such a setting is the flag name,
+prefixed with the dot-separated names of the command or one of its ancestors,
+e.g. "update.gc", "runp.collate-stdout", "cl.host" or "snapshot.dir".  Keys
+without a prefix, e.g. "offline", apply to the flags of all commands.  Settings
+of more specific commands take precedence.
+
+Flags given on the command line take precedence over environment variables such
+as JIRI_UPDATE_GC, which take precedence over the config files.  The config
+command itself ignores the flag defaults of the config files, so that invalid
+values can always be fixed.
+
+Settings with keys of the form "alias.<name>" define aliases, that expand to a
+command followed by flags and args, separated by spaces.  Aliases of
+subcommands are prefixed with the names of their parent commands, e.g.
+"alias.snapshot.co".  Aliases never override jiri commands.
+
+Example:
+  $ jiri config set update.gc true
+  $ jiri config -user set snapshot.dir ~/snapshots
+  $ jiri config -user set alias.up "update -rebase-tracked"
+`,
+	Children: []*cmdline.Command{cmdConfigGet, cmdConfigList, cmdConfigSet, cmdConfigUnset},
+}
+
+// cmdConfigGet represents the "jiri config get" command.
+var cmdConfigGet = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runConfigGet),
+	Name:   "get",
+	Short:  "Print the value of a setting",
+	Long: `
+Prints the value of the given setting.  Without -user, the value is the one in
+effect, from either the root or the user config file.  Fails if the setting is
+not set.
+`,
+	ArgsName: "<key>",
+	ArgsLong: "<key> is the key of the setting.",
+}
+
+// cmdConfigList represents the "jiri config list" command.
+var cmdConfigList = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runConfigList),
+	Name:   "list",
+	Short:  "List settings",
+	Long: `
+Lists the settings as <key>=<value> lines, sorted by key.  Without -user, the
+settings in effect are listed, from both the root and the user config files.
+`,
+}
+
+// cmdConfigSet represents the "jiri config set" command.
+var cmdConfigSet = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runConfigSet),
+	Name:   "set",
+	Short:  "Set the value of a setting",
+	Long: `
+Sets the value of the given setting in the root config file, or in the user
+config file with -user.
+`,
+	ArgsName: "<key> <value>",
+	ArgsLong: `
+<key> is the key of the setting.
+
+<value> is its new value.
+`,
+}
+
+// cmdConfigUnset represents the "jiri config unset" command.
+var cmdConfigUnset = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runConfigUnset),
+	Name:   "unset",
+	Short:  "Remove a setting",
+	Long: `
+Removes the given setting from the root config file, or from the user config
+file with -user.
+`,
+	ArgsName: "<key>",
+	ArgsLong: "<key> is the key of the setting.",
+}
+
+// flagDefaults returns the default flag values for the jiri command with the
+// given path, from the root and user config files.  The config command doesn't
+// use the config files, so that it can always fix invalid values.
+func flagDefaults(path []string) (map[string]string, error) {
+	if len(path) > 1 && path[1] == cmdConfig.Name {
+		return nil, nil
+	}
+	config, err := jiri.LoadConfigs(jiri.FindRoot())
+	if err != nil {
+		return nil, err
+	}
+	return config.FlagDefaults(path[1:]), nil
+}
+
+// expandAlias returns the expansion of the alias with the given name, for the
+// jiri command with the given path, from the root and user config files.
+func expandAlias(path []string, name string) ([]string, error) {
+	config, err := jiri.LoadConfigs(jiri.FindRoot())
+	if err != nil {
+		return nil, err
+	}
+	return config.Alias(path[1:], name), nil
+}
+
+// configFile returns the config file edited by the config subcommands.  A
+// jiri root is only required for the root config file.
+func configFile(env *cmdline.Env) (string, error) {
+	if configUserFlag {
+		return jiri.UserConfigFile(), nil
+	}
+	jirix, err := jiri.NewX(env)
+	if err != nil {
+		return "", err
+	}
+	return jirix.ConfigFile(), nil
+}
+
+// loadConfig returns the config read by the config subcommands.  A jiri root
+// is only required for the settings in effect in the root.
+func loadConfig(env *cmdline.Env) (*jiri.Config, error) {
+	if configUserFlag {
+		return jiri.LoadConfig(jiri.UserConfigFile())
+	}
+	jirix, err := jiri.NewX(env)
+	if err != nil {
+		return nil, err
+	}
+	return jirix.Config, nil
+}
+
+func runConfigGet(env *cmdline.Env, args []string) error {
+	if len(args) != 1 {
+		return env.UsageErrorf("wrong number of arguments")
+	}
+	config, err := loadConfig(env)
+	if err != nil {
+		return err
+	}
+	value, ok := config.Get(args[0])
+	if !ok {
+		return fmt.Errorf("%q is not set", args[0])
+	}
+	fmt.Fprintln(env.Stdout, value)
+	return nil
+}
+
+func runConfigList(env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return env.UsageErrorf("unexpected arguments")
+	}
+	config, err := loadConfig(env)
+	if err != nil {
+		return err
+	}
+	for _, s := range config.SortedSettings() {
+		fmt.Fprintf(env.Stdout, "%s=%s\n", s.Key, s.Value)
+	}
+	return nil
+}
+
+func runConfigSet(env *cmdline.Env, args []string) error {
+	if len(args) != 2 {
+		return env.UsageErrorf("wrong number of arguments")
+	}
+	file, err := configFile(env)
+	if err != nil {
+		return err
+	}
+	config, err := jiri.LoadConfig(file)
+	if err != nil {
+		return err
+	}
+	config.Set(args[0], args[1])
+	return config.Write(file)
+}
+
+func runConfigUnset(env *cmdline.Env, args []string) error {
+	if len(args) != 1 {
+		return env.UsageErrorf("wrong number of arguments")
+	}
+	file, err := configFile(env)
+	if err != nil {
+		return err
+	}
+	config, err := jiri.LoadConfig(file)
+	if err != nil {
+		return err
+	}
+	if !config.Unset(args[0]) {
+		return fmt.Errorf("%q is not set in %v", args[0], file)
+	}
+	return config.Write(file)
+}
@@ -0,0 +1,209 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/jiritest"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+// TestEnvFlags checks that environment variables take precedence over the
+// config files, and that the sources of the flag values are reported.
+func TestEnvFlags(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	config := &jiri.Config{}
+	config.Set("update.gc", "true")
+	config.Set("update.attempts", "3")
+	if err := config.Write(fake.X.ConfigFile()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { gcFlag, attemptsFlag, tool.VerboseFlag, tool.ManifestFlag = false, 1, false, "" }()
+
+	env := cmdline.EnvFromOS()
+	env.Vars[jiri.RootEnv] = fake.X.Root
+	env.Vars["XDG_CONFIG_HOME"] = filepath.Join(fake.X.Root, "home")
+	env.Vars["JIRI_UPDATE_GC"] = "false"
+	env.Vars["JIRI_V"] = "true"
+	env.Vars[jiri.PreservePathEnv] = "true"
+	defer os.Setenv(jiri.RootEnv, os.Getenv(jiri.RootEnv))
+	if err := os.Setenv(jiri.RootEnv, fake.X.Root); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cmdline.Parse(cmdRoot, env, []string{"update", "-manifest=m"}); err != nil {
+		t.Fatal(err)
+	}
+	if gcFlag || attemptsFlag != 3 || !tool.VerboseFlag {
+		t.Errorf("got -gc=%v -attempts=%v -v=%v, want false, 3 and true", gcFlag, attemptsFlag, tool.VerboseFlag)
+	}
+	for name, want := range map[string]cmdline.FlagSource{
+		"gc":       cmdline.FlagSourceEnv,
+		"attempts": cmdline.FlagSourceConfig,
+		"v":        cmdline.FlagSourceEnv,
+		"manifest": cmdline.FlagSourceCommandLine,
+		"color":    cmdline.FlagSourceDefault,
+	} {
+		if got := cmdUpdate.FlagSource(name); got != want {
+			t.Errorf("-%v: got source %v, want %v", name, got, want)
+		}
+	}
+
+	// JIRI_OFFLINE isn't bound to -offline, but parsed as a boolean by jiri.X.
+	for _, test := range []struct {
+		value   string
+		offline bool
+		err     string
+	}{
+		{"1", true, ""},
+		{"true", true, ""},
+		{"0", false, ""},
+		{"false", false, ""},
+		{"yes", false, `invalid value "yes" of $JIRI_OFFLINE`},
+	} {
+		env.Vars[jiri.OfflineEnv] = test.value
+		if _, _, err := cmdline.Parse(cmdRoot, env, []string{"update"}); err != nil {
+			t.Fatalf("%s=%s: %v", jiri.OfflineEnv, test.value, err)
+		}
+		if tool.OfflineFlag {
+			t.Errorf("%s=%s: got -offline=true, want false", jiri.OfflineEnv, test.value)
+		}
+		x, err := jiri.NewX(env)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s=%s: got error %v, want %q", jiri.OfflineEnv, test.value, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.Offline != test.offline {
+			t.Errorf("%s=%s: got offline %v, want %v", jiri.OfflineEnv, test.value, x.Offline, test.offline)
+		}
+	}
+}
+
+// TestConfig checks that settings can be edited in the root and user config
+// files, and that they provide flag defaults with the right precedence.
+func TestConfig(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	if err := os.Setenv("XDG_CONFIG_HOME", filepath.Join(fake.X.Root, "home")); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { configUserFlag = false }()
+	defer os.Setenv(jiri.RootEnv, os.Getenv(jiri.RootEnv))
+	var stdout bytes.Buffer
+	env := &cmdline.Env{Stdout: &stdout, Stderr: &stdout, Vars: map[string]string{
+		"PATH":               os.Getenv("PATH"),
+		jiri.PreservePathEnv: "true",
+	}}
+
+	// The user config file can be edited outside of a jiri root.
+	if err := os.Unsetenv(jiri.RootEnv); err != nil {
+		t.Fatal(err)
+	}
+	configUserFlag = true
+	for _, args := range [][]string{{"snapshot.dir", "user-dir"}, {"update.gc", "true"}} {
+		if err := runConfigSet(env, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := runConfigList(env, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "snapshot.dir=user-dir\nupdate.gc=true\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	configUserFlag = false
+	if err := runConfigSet(env, []string{"update.gc", "false"}); err == nil {
+		t.Errorf("setting the root config outside of a jiri root succeeded")
+	}
+
+	if err := os.Setenv(jiri.RootEnv, fake.X.Root); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"update.gc", "false"}, {"runp.collate-stdout", "false"}} {
+		if err := runConfigSet(env, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := runConfigUnset(env, []string{"runp.collate-stdout"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runConfigUnset(env, []string{"runp.collate-stdout"}); err == nil {
+		t.Errorf("unsetting a missing setting succeeded")
+	}
+
+	for _, test := range []struct {
+		user bool
+		want string
+	}{
+		{false, "snapshot.dir=user-dir\nupdate.gc=false\n"},
+		{true, "snapshot.dir=user-dir\nupdate.gc=true\n"},
+	} {
+		stdout.Reset()
+		configUserFlag = test.user
+		if err := runConfigList(env, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := stdout.String(); got != test.want {
+			t.Errorf("user %v: got %q, want %q", test.user, got, test.want)
+		}
+	}
+	configUserFlag = false
+	stdout.Reset()
+	if err := runConfigGet(env, []string{"update.gc"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "false\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := runConfigGet(env, []string{"missing"}); err == nil {
+		t.Errorf("getting a missing setting succeeded")
+	}
+
+	// Flag defaults come from both config files.
+	defaults, err := flagDefaults([]string{"jiri", "update"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := defaults, map[string]string{"gc": "false"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got defaults %v, want %v", got, want)
+	}
+	if defaults, err = flagDefaults([]string{"jiri", "config", "set"}); err != nil || len(defaults) != 0 {
+		t.Errorf("got defaults %v, %v for jiri config, want none", defaults, err)
+	}
+
+	// Aliases come from the config files.
+	if err := runConfigSet(env, []string{"alias.up", "update  -gc"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		path []string
+		want []string
+	}{
+		{[]string{"jiri"}, []string{"update", "-gc"}},
+		{[]string{"jiri", "snapshot"}, nil},
+	} {
+		got, err := expandAlias(test.path, "up")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got expansion %v, want %v", test.path, got, test.want)
+		}
+	}
+}
@@ -8,28 +8,53 @@
 /*
 Command jiri is a multi-purpose tool for multi-repo development.
 
+With -offline, or if the JIRI_OFFLINE environment variable is set to true, jiri
+doesn't access the network: git operations use only local objects, and commands
+that need the network fail.
+
+Flags can also be set by environment variables named after the command and the
+flag, e.g. JIRI_UPDATE_GC=true for "jiri update -gc"; help shows the variable of
+each flag.  Default flag values and aliases can be set in the root and user
+config files; see "jiri help config".
+
 Usage:
    jiri [flags] <command>
 
 The jiri commands are:
-   cl          Manage changelists for multiple projects
-   import      Adds imports to .jiri_manifest file
-   project     Manage the jiri projects
-   rebuild     Rebuild all jiri tools
-   snapshot    Manage project snapshots
-   update      Update all jiri tools and projects
-   which       Show path to the jiri tool
-   runp        Run a command in parallel across jiri projects
-   help        Display help for commands or topics
+   am           Apply patches exported by jiri format-patch
+   branch       Manage local branches across projects
+   cl           Manage changelists for multiple projects
+   completion   Print a shell completion script
+   config       Manage jiri config files
+   diff         Show changes across projects as a single diff
+   format-patch Export the commits of a branch across projects as patches
+   grep         Search for a pattern across jiri projects
+   import       Adds imports to .jiri_manifest file
+   init         Create a new jiri root
+   log          Show the commit history of all projects
+   logs         Show the trace log of the commands run by jiri
+   plugins      Manage jiri plugins
+   project      Manage the jiri projects
+   rebuild      Rebuild all jiri tools
+   snapshot     Manage project snapshots
+   update       Update all jiri tools and projects
+   version      Print version information for jiri and its tools
+   which        Show path to the jiri tool
+   runp         Run a command in parallel across jiri projects
+   help         Display help for commands or topics
 
 The jiri additional help topics are:
    filesystem  Description of jiri file system layout
    manifest    Description of manifest files
 
 The jiri flags are:
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 The global flags are:
@@ -37,6 +62,142 @@ The global flags are:
    Displays metadata for the program and exits.
  -time=false
    Dump timing information to stderr before exiting the program.
+ -time-trace=
+   Write timing information to the given file before exiting the program, in the
+   Chrome Trace Event format; load it in chrome://tracing or Perfetto.
+
+Jiri am - Apply patches exported by jiri format-patch
+
+Applies a mailbox of patches created by "jiri format-patch" to the current
+branches of the projects they touch.  Each patch is mapped to a project by the
+project path that "jiri format-patch" records in its X-Jiri-Project header, and
+is committed to that project with "git am".
+
+If a patch doesn't apply, the "git am" of that project is aborted, and projects
+that were already patched are left as they are.
+
+Usage:
+   jiri am [flags] <file>
+
+<file> is the mailbox of patches to apply.
+
+The jiri am flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri branch - Manage local branches across projects
+
+Manage local branches across all jiri projects.  A branch of a given name may
+exist in any number of projects; the subcommands operate on all of them at once.
+
+Usage:
+   jiri branch [flags] <command>
+
+The jiri branch commands are:
+   checkout     Check out a branch in all projects that have it
+   delete       Delete a branch in all projects that have it
+   list         List local branches and the projects that have them
+   prune-merged Delete all branches that are merged into master
+
+The jiri branch flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri branch checkout - Check out a branch in all projects that have it
+
+Checks out the given branch in every project that has it, and checks out master
+in all other projects.  Fails without changing anything if a project that would
+switch branches has uncommitted changes.
+
+Usage:
+   jiri branch checkout [flags] <branch>
+
+<branch> is the branch to check out.
+
+The jiri branch checkout flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri branch delete - Delete a branch in all projects that have it
+
+Deletes the given branch in every project that has it.  Projects that have the
+branch checked out are switched to master first.  Unless -force is given, fails
+without deleting anything if the branch is not merged into master in some
+project.
+
+Usage:
+   jiri branch delete [flags] <branch>
+
+<branch> is the branch to delete.
+
+The jiri branch delete flags are:
+ -force=false ($JIRI_BRANCH_DELETE_FORCE)
+   Delete the branch even if it is not merged into master.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri branch list - List local branches and the projects that have them
+
+Lists every local branch other than master, followed by the projects that have
+it.  Each project is marked as merged if all commits on the branch are on its
+master branch, and as current if the branch is checked out.
+
+Usage:
+   jiri branch list [flags]
+
+The jiri branch list flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri branch prune-merged - Delete all branches that are merged into master
+
+Deletes every local branch, other than master, whose commits are all on master.
+Projects that have such a branch checked out are switched to master first,
+unless they have uncommitted changes, in which case the branch is kept.
+
+Usage:
+   jiri branch prune-merged [flags]
+
+The jiri branch prune-merged flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
 
 Jiri cl - Manage changelists for multiple projects
 
@@ -47,14 +208,20 @@ Usage:
 
 The jiri cl commands are:
    cleanup     Clean up changelists that have been merged
-   upload      Upload a changelist for review
+   mail        Upload a changelist for review
    new         Create a new local branch for a changelist
+   patch       Patch in the existing change
    sync        Bring a changelist up to date
+   upload      Upload a changelist for review
 
 The jiri cl flags are:
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri cl cleanup - Clean up changelists that have been merged
@@ -70,67 +237,75 @@ Usage:
 <branches> is a list of branches to cleanup.
 
 The jiri cl cleanup flags are:
- -f=false
+ -f=false ($JIRI_CL_CLEANUP_F)
    Ignore unmerged changes.
- -remote-branch=master
+ -remote-branch=master ($JIRI_CL_CLEANUP_REMOTE_BRANCH)
    Name of the remote branch the CL pertains to, without the leading "origin/".
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
-Jiri cl upload - Upload a changelist for review
+Jiri cl mail - Upload a changelist for review
 
-Command "upload" squashes all commits of a local branch into a single "changelist"
-and uploads this changelist to Gerrit as a single commit. First time the command
-is invoked, it generates a Change-Id for the changelist, which is appended to
-the commit message. Consecutive invocations of the command use the same
-Change-Id by default, informing Gerrit that the incomming commit is an update of
-an existing changelist.
+Command "upload" squashes all commits of a local branch into a single
+"changelist" and uploads this changelist to Gerrit as a single commit. First
+time the command is invoked, it generates a Change-Id for the changelist, which
+is appended to the commit message. Consecutive invocations of the command use
+the same Change-Id by default, informing Gerrit that the incomming commit is an
+update of an existing changelist.
 
 Usage:
-   jiri cl upload [flags]
+   jiri cl mail [flags]
 
-The jiri cl upload flags are:
- -autosubmit=false
+The jiri cl mail flags are:
+ -autosubmit=false ($JIRI_CL_MAIL_AUTOSUBMIT)
    Automatically submit the changelist when feasible.
- -cc=
+ -cc= ($JIRI_CL_MAIL_CC)
    Comma-seperated list of emails or LDAPs to cc.
- -check-uncommitted=true
+ -check-uncommitted=true ($JIRI_CL_MAIL_CHECK_UNCOMMITTED)
    Check that no uncommitted changes exist.
- -clean-multipart-metadata=false
+ -clean-multipart-metadata=false ($JIRI_CL_MAIL_CLEAN_MULTIPART_METADATA)
    Cleanup the metadata associated with multipart CLs pertaining the MultiPart:
    x/y message without uploading any CLs.
- -commit-message-body-file=
+ -commit-message-body-file= ($JIRI_CL_MAIL_COMMIT_MESSAGE_BODY_FILE)
    file containing the body of the CL description, that is, text without a
    ChangeID, MultiPart etc.
- -current-project-only=false
+ -current-project-only=false ($JIRI_CL_MAIL_CURRENT_PROJECT_ONLY)
    Run upload in the current project only.
- -d=false
+ -d=false ($JIRI_CL_MAIL_D)
    Send a draft changelist.
- -edit=true
+ -edit=true ($JIRI_CL_MAIL_EDIT)
    Open an editor to edit the CL description.
- -host=
+ -host= ($JIRI_CL_MAIL_HOST)
    Gerrit host to use.  Defaults to gerrit host specified in manifest.
- -m=
+ -m= ($JIRI_CL_MAIL_M)
    CL description.
- -presubmit=all
+ -presubmit=all ($JIRI_CL_MAIL_PRESUBMIT)
    The type of presubmit tests to run. Valid values: none,all.
- -r=
+ -r= ($JIRI_CL_MAIL_R)
    Comma-seperated list of emails or LDAPs to request review.
- -remote-branch=master
+ -remote-branch=master ($JIRI_CL_MAIL_REMOTE_BRANCH)
    Name of the remote branch the CL pertains to, without the leading "origin/".
- -set-topic=true
+ -set-topic=true ($JIRI_CL_MAIL_SET_TOPIC)
    Set Gerrit CL topic.
- -topic=
+ -topic= ($JIRI_CL_MAIL_TOPIC)
    CL topic, defaults to <username>-<branchname>.
- -verify=true
+ -verify=true ($JIRI_CL_MAIL_VERIFY)
    Run pre-push git hooks.
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri cl new - Create a new local branch for a changelist
@@ -148,9 +323,49 @@ Usage:
 <name> is the changelist name.
 
 The jiri cl new flags are:
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri cl patch - Patch in the existing change
+
+Command "patch" applies the existing changelist to the current project. The
+change can be identified either using change ID, in which case the latest
+patchset will be used, or the the full reference.
+
+A new branch will be created to apply the patch to. The default name of this
+branch is "change/<changeset>/<patchset>", but this can be overriden using the
+-branch flag. The command will fail if the branch already exists. The -delete
+flag will delete the branch if already exists. Use the -force flag to force
+deleting the branch even if it contains unmerged changes).
+
+Usage:
+   jiri cl patch [flags] <change>
+
+<change> is a change ID or a full reference.
+
+The jiri cl patch flags are:
+ -branch= ($JIRI_CL_PATCH_BRANCH)
+   Name of the branch the patch will be applied to
+ -delete=false ($JIRI_CL_PATCH_DELETE)
+   Delete the existing branch if already exists
+ -force=false ($JIRI_CL_PATCH_FORCE)
+   Use force when deleting the existing branch
+ -host= ($JIRI_CL_PATCH_HOST)
+   Gerrit host to use.  Defaults to gerrit host specified in manifest.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri cl sync - Bring a changelist up to date
@@ -172,12 +387,324 @@ Usage:
    jiri cl sync [flags]
 
 The jiri cl sync flags are:
- -remote-branch=master
+ -remote-branch=master ($JIRI_CL_SYNC_REMOTE_BRANCH)
    Name of the remote branch the CL pertains to, without the leading "origin/".
 
- -color=true
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri cl upload - Upload a changelist for review
+
+Command "upload" squashes all commits of a local branch into a single
+"changelist" and uploads this changelist to Gerrit as a single commit. First
+time the command is invoked, it generates a Change-Id for the changelist, which
+is appended to the commit message. Consecutive invocations of the command use
+the same Change-Id by default, informing Gerrit that the incomming commit is an
+update of an existing changelist.
+
+Usage:
+   jiri cl upload [flags]
+
+The jiri cl upload flags are:
+ -autosubmit=false ($JIRI_CL_UPLOAD_AUTOSUBMIT)
+   Automatically submit the changelist when feasible.
+ -cc= ($JIRI_CL_UPLOAD_CC)
+   Comma-seperated list of emails or LDAPs to cc.
+ -check-uncommitted=true ($JIRI_CL_UPLOAD_CHECK_UNCOMMITTED)
+   Check that no uncommitted changes exist.
+ -clean-multipart-metadata=false ($JIRI_CL_UPLOAD_CLEAN_MULTIPART_METADATA)
+   Cleanup the metadata associated with multipart CLs pertaining the MultiPart:
+   x/y message without uploading any CLs.
+ -commit-message-body-file= ($JIRI_CL_UPLOAD_COMMIT_MESSAGE_BODY_FILE)
+   file containing the body of the CL description, that is, text without a
+   ChangeID, MultiPart etc.
+ -current-project-only=false ($JIRI_CL_UPLOAD_CURRENT_PROJECT_ONLY)
+   Run upload in the current project only.
+ -d=false ($JIRI_CL_UPLOAD_D)
+   Send a draft changelist.
+ -edit=true ($JIRI_CL_UPLOAD_EDIT)
+   Open an editor to edit the CL description.
+ -host= ($JIRI_CL_UPLOAD_HOST)
+   Gerrit host to use.  Defaults to gerrit host specified in manifest.
+ -m= ($JIRI_CL_UPLOAD_M)
+   CL description.
+ -presubmit=all ($JIRI_CL_UPLOAD_PRESUBMIT)
+   The type of presubmit tests to run. Valid values: none,all.
+ -r= ($JIRI_CL_UPLOAD_R)
+   Comma-seperated list of emails or LDAPs to request review.
+ -remote-branch=master ($JIRI_CL_UPLOAD_REMOTE_BRANCH)
+   Name of the remote branch the CL pertains to, without the leading "origin/".
+ -set-topic=true ($JIRI_CL_UPLOAD_SET_TOPIC)
+   Set Gerrit CL topic.
+ -topic= ($JIRI_CL_UPLOAD_TOPIC)
+   CL topic, defaults to <username>-<branchname>.
+ -verify=true ($JIRI_CL_UPLOAD_VERIFY)
+   Run pre-push git hooks.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri completion - Print a shell completion script
+
+Command "completion" prints a script that sets up completion of jiri commands,
+flags and args for the given shell.  Project names, snapshot labels and branch
+names are completed where the commands expect them.
+
+Example:
+  $ source <(jiri completion bash)
+  $ jiri completion zsh > "${fpath[1]}/_jiri"
+  $ jiri completion fish > ~/.config/fish/completions/jiri.fish
+
+Usage:
+   jiri completion [flags] <shell>
+
+<shell> is one of bash, fish or zsh.
+
+The jiri completion flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri config - Manage jiri config files
+
+Manage the settings of the jiri config files.  Settings are read from the root
+config file, $JIRI_ROOT/.jiri_root/config, and the user config file,
+$XDG_CONFIG_HOME/jiri/config, where XDG_CONFIG_HOME defaults to ~/.config.
+Settings of the root config file take precedence.
+
+Besides settings such as "cache.dir", the config files provide default values
+for the flags of jiri commands.  The key of such a setting is the flag name,
+prefixed with the dot-separated names of the command or one of its ancestors,
+e.g. "update.gc", "runp.collate-stdout", "cl.host" or "snapshot.dir".  Keys
+without a prefix, e.g. "offline", apply to the flags of all commands.  Settings
+of more specific commands take precedence.
+
+Flags given on the command line take precedence over environment variables such
+as JIRI_UPDATE_GC, which take precedence over the config files.  The config
+command itself ignores the flag defaults of the config files, so that invalid
+values can always be fixed.
+
+Settings with keys of the form "alias.<name>" define aliases, that expand to a
+command followed by flags and args, separated by spaces.  Aliases of subcommands
+are prefixed with the names of their parent commands, e.g. "alias.snapshot.co".
+Aliases never override jiri commands.
+
+Example:
+  $ jiri config set update.gc true
+  $ jiri config -user set snapshot.dir ~/snapshots
+  $ jiri config -user set alias.up "update -rebase-tracked"
+
+Usage:
+   jiri config [flags] <command>
+
+The jiri config commands are:
+   get         Print the value of a setting
+   list        List settings
+   set         Set the value of a setting
+   unset       Remove a setting
+
+The jiri config flags are:
+ -user=false ($JIRI_CONFIG_USER)
+   Use the user config file, rather than the root config file.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri config get - Print the value of a setting
+
+Prints the value of the given setting.  Without -user, the value is the one in
+effect, from either the root or the user config file.  Fails if the setting is
+not set.
+
+Usage:
+   jiri config get [flags] <key>
+
+<key> is the key of the setting.
+
+The jiri config get flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -user=false ($JIRI_CONFIG_USER)
+   Use the user config file, rather than the root config file.
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri config list - List settings
+
+Lists the settings as <key>=<value> lines, sorted by key.  Without -user, the
+settings in effect are listed, from both the root and the user config files.
+
+Usage:
+   jiri config list [flags]
+
+The jiri config list flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -user=false ($JIRI_CONFIG_USER)
+   Use the user config file, rather than the root config file.
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri config set - Set the value of a setting
+
+Sets the value of the given setting in the root config file, or in the user
+config file with -user.
+
+Usage:
+   jiri config set [flags] <key> <value>
+
+<key> is the key of the setting.
+
+<value> is its new value.
+
+The jiri config set flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -user=false ($JIRI_CONFIG_USER)
+   Use the user config file, rather than the root config file.
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri config unset - Remove a setting
+
+Removes the given setting from the root config file, or from the user config
+file with -user.
+
+Usage:
+   jiri config unset [flags] <key>
+
+<key> is the key of the setting.
+
+The jiri config unset flags are:
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -user=false ($JIRI_CONFIG_USER)
+   Use the user config file, rather than the root config file.
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri diff - Show changes across projects as a single diff
+
+Prints the changes in all jiri projects as a single unified diff, with file
+names relative to $JIRI_ROOT, e.g. "a/<project path>/<file>".
+
+Without arguments, shows the uncommitted changes to tracked files of each
+project.  Given a branch, shows the changes on that branch since it forked from
+master, in each project that has the branch.  Given a snapshot file, shows the
+differences between the revisions in the snapshot and the working tree of each
+project in the snapshot.
+
+Usage:
+   jiri diff [flags] [<branch> | <snapshot>]
+
+<branch> is a local branch, and <snapshot> a snapshot file.
+
+The jiri diff flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri format-patch - Export the commits of a branch across projects as patches
+
+Exports the commits on the given branch that are not on master, in every project
+that has the branch, as a single mailbox of patches.  File names in the patches
+are relative to $JIRI_ROOT, so that "jiri am" can apply them to the right
+projects of another jiri root, without network access.
+
+Usage:
+   jiri format-patch [flags] <branch>
+
+<branch> is the branch to export.
+
+The jiri format-patch flags are:
+ -o= ($JIRI_FORMAT_PATCH_O)
+   Write the patches to this file instead of stdout.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri grep - Search for a pattern across jiri projects
+
+Runs "git grep" in parallel across all jiri projects, or those selected by
+-projects, searching the tracked files of each project for the given pattern.
+Untracked files, .git directories and anything ignored by git are not searched.
+
+Matches are printed as <path>:<line>:<text>, where <path> is relative to
+$JIRI_ROOT.  They are sorted by project path and then by file, so the output is
+the same from run to run.
+
+Usage:
+   jiri grep [flags] <pattern> [-- <pathspec>...]
+
+<pattern> is a regular expression, as understood by "git grep".  The optional
+<pathspec>... limit the search within each project, as for "git grep".
+
+The jiri grep flags are:
+ -i=false ($JIRI_GREP_I)
+   Ignore case differences between the pattern and the files.
+ -json=false ($JIRI_GREP_JSON)
+   Print the matches in JSON format.
+ -projects= ($JIRI_GREP_PROJECTS)
+   A regular expression specifying the keys of the projects to search. By
+   default, all projects are searched.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri import
@@ -204,26 +731,289 @@ Usage:
 <remote> specifies the remote manifest repository.
 
 The jiri import flags are:
- -name=manifest
+ -name=manifest ($JIRI_IMPORT_NAME)
    The name of the remote manifest project.
- -out=
+ -out= ($JIRI_IMPORT_OUT)
    The output file.  Uses $JIRI_ROOT/.jiri_manifest if unspecified.  Uses stdout
    if set to "-".
- -overwrite=false
+ -overwrite=false ($JIRI_IMPORT_OVERWRITE)
    Write a new .jiri_manifest file with the given specification.  If it already
    exists, the existing content will be ignored and the file will be
    overwritten.
- -protocol=git
-   The version control protocol used by the remote manifest project.
- -remote-branch=master
+ -remote-branch=master ($JIRI_IMPORT_REMOTE_BRANCH)
    The branch of the remote manifest project to track, without the leading
    "origin/".
- -root=
+ -root= ($JIRI_IMPORT_ROOT)
    Root to store the manifest project locally.
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri init - Create a new jiri root
+
+Command "init" creates a new jiri root in the given directory, or the current
+directory if none is given.  It creates the .jiri_root metadata directory,
+installs the running jiri binary in .jiri_root/bin, and writes the root config
+file, .jiri_root/config, from the -cache-dir and -set flags.
+
+If a manifest and remote are given, an import of the remote manifest is written
+to the .jiri_manifest file, as for "jiri import", and with -update, the projects
+and tools of the manifest are then fetched and built, as by "jiri update" with
+the flag defaults of the new config.
+
+The new root can't be inside an existing jiri root.
+
+Example:
+  $ jiri init -update myroot manifest https://foo.com/bar.git
+
+Usage:
+   jiri init [flags] [<dir> [<manifest> <remote>]]
+
+<dir> is the directory of the new root, which is created if it doesn't exist.
+
+<manifest> and <remote> specify the manifest to import, as for "jiri import".
+
+The jiri init flags are:
+ -cache-dir= ($JIRI_INIT_CACHE_DIR)
+   Directory holding the caches of the root, relative to the root unless
+   absolute.  Defaults to $JIRI_ROOT/.jiri_root.
+ -name=manifest ($JIRI_INIT_NAME)
+   The name of the remote manifest project.
+ -remote-branch=master ($JIRI_INIT_REMOTE_BRANCH)
+   The branch of the remote manifest project to track, without the leading
+   "origin/".
+ -root= ($JIRI_INIT_ROOT)
+   Root to store the manifest project locally.
+ -set= ($JIRI_INIT_SET)
+   A <key>=<value> setting to write to the root config file.  May be repeated.
+ -update=false ($JIRI_INIT_UPDATE)
+   Run "jiri update" once the root is created.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri log - Show the commit history of all projects
+
+Shows the commits on the master branches of all jiri projects, or those selected
+by -projects, merged into a single stream, newest first.  Each commit is tagged
+with its project.
+
+The commits can be limited to those between two snapshots with -from and -to, to
+a time range with -since and -until, and to an author with -author.
+
+Usage:
+   jiri log [flags]
+
+The jiri log flags are:
+ -author= ($JIRI_LOG_AUTHOR)
+   Only show commits whose author name or email matches this regular expression.
+ -format=text ($JIRI_LOG_FORMAT)
+   The output format, text or json.
+ -from= ($JIRI_LOG_FROM)
+   Only show commits that are not in the given snapshot file.
+ -projects= ($JIRI_LOG_PROJECTS)
+   A regular expression specifying the keys of the projects to show. By default,
+   all projects are shown.
+ -since= ($JIRI_LOG_SINCE)
+   Only show commits more recent than this, given as a date (2006-01-02), a time
+   (2006-01-02T15:04:05Z07:00) or a duration before now (48h).
+ -to= ($JIRI_LOG_TO)
+   Show the commits of the given snapshot file, instead of those of the master
+   branches.
+ -until= ($JIRI_LOG_UNTIL)
+   Only show commits older than this, in the same formats as -since.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri logs - Show the trace log of the commands run by jiri
+
+With the -trace flag, or if the JIRI_TRACE environment variable is set to true,
+jiri records every command it runs, such as git, in the trace log
+$JIRI_ROOT/.jiri_root/logs/trace.jsonl.  Each line of the log is a JSON object
+with the args of the command, its working directory, the environment variables
+it was run with that differ from those of jiri, how long it ran, its exit code,
+and the start of its output.
+
+Once the log grows beyond 4MB, it is renamed to trace.jsonl.1, and older logs
+are renamed in turn; the 3 most recent of those are kept.
+
+Usage:
+   jiri logs [flags] <command>
+
+The jiri logs commands are:
+   show        Show the most recent commands in the trace log
+   last        Show the details of the last command in the trace log
+
+The jiri logs flags are:
+ -failed=false ($JIRI_LOGS_FAILED)
+   Only consider commands that failed.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri logs show - Show the most recent commands in the trace log
+
+Shows the most recent commands in the trace log, oldest first, one per line:
+when each started, how long it ran, its exit code, its working directory
+relative to the jiri root, and its args.
+
+Usage:
+   jiri logs show [flags]
+
+The jiri logs show flags are:
+ -json=false ($JIRI_LOGS_SHOW_JSON)
+   Print the records as they are stored, one JSON object per line.
+ -n=20 ($JIRI_LOGS_SHOW_N)
+   Number of most recent commands to show; 0 shows all.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -failed=false ($JIRI_LOGS_FAILED)
+   Only consider commands that failed.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri logs last - Show the details of the last command in the trace log
+
+Shows all that the trace log records about the last command, including its
+environment and output.  Use -failed to show the last command that failed.
+
+Usage:
+   jiri logs last [flags]
+
+The jiri logs last flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -failed=false ($JIRI_LOGS_FAILED)
+   Only consider commands that failed.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri plugins - Manage jiri plugins
+
+Plugins are binaries on PATH whose names start with "jiri-"; "jiri foo" runs the
+jiri-foo plugin with the remaining args.  Plugins describe themselves by
+printing JSON like the following when run with --jiri-plugin-info:
+
+  {
+    "name": "foo",
+    "short": "Do foo",
+    "min_jiri_version": "1.2",
+    "needs_root": true
+  }
+
+The "name" is the plugin name without the "jiri-" prefix, and "short" is the
+description shown by "jiri help".  The optional "min_jiri_version" is the oldest
+version of jiri the plugin works with, and "needs_root" indicates whether the
+plugin must be run in a jiri root.  The latter is advisory: it is reported by
+"jiri plugins", but not checked when jiri runs the plugin.
+
+The info is cached in $XDG_CACHE_HOME/jiri/plugins.json, and refreshed when a
+plugin binary changes; failures to write the cache are only reported.  Plugins
+without info are still run, and described by running them with -help.
+
+Manifests can declare the tools they build as plugins; see "jiri help manifest".
+
+Usage:
+   jiri plugins [flags] <command>
+
+The jiri plugins commands are:
+   list        List the plugins on PATH
+   doctor      Report broken or incompatible plugins
+
+The jiri plugins flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri plugins list - List the plugins on PATH
+
+Lists the plugins that "jiri" runs, with their descriptions, and whether they
+need a jiri root.  Plugins shadowed by others of the same name earlier on PATH
+aren't listed.
+
+Usage:
+   jiri plugins list [flags]
+
+The jiri plugins list flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri plugins doctor - Report broken or incompatible plugins
+
+Checks the plugins on PATH, and reports the problems it finds:
+
+* Plugins that fail to print valid info when run with --jiri-plugin-info.
+
+* Plugins whose info names a different plugin.
+
+* Plugins that need a more recent version of jiri.
+
+* Plugins that need a jiri root, when run outside of one.
+
+* Plugins that are shadowed by a jiri command, or by another plugin of the
+  same name earlier on PATH.
+
+* Plugins declared by the manifest that aren't installed in
+  $JIRI_ROOT/.jiri_root/bin, when run in a jiri root.
+
+Fails if there are any problems.
+
+Usage:
+   jiri plugins doctor [flags]
+
+The jiri plugins doctor flags are:
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri project - Manage the jiri projects
@@ -241,9 +1031,13 @@ The jiri project commands are:
    shell-prompt Print a succinct status of projects suitable for shell prompts
 
 The jiri project flags are:
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri project clean - Restore jiri projects to their pristine state
@@ -257,12 +1051,16 @@ Usage:
 <project ...> is a list of projects to clean up.
 
 The jiri project clean flags are:
- -branches=false
+ -branches=false ($JIRI_PROJECT_CLEAN_BRANCHES)
    Delete all non-master branches.
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri project info - Provided structured input for existing jiri projects and branches
@@ -273,12 +1071,12 @@ matched against project keys. If no command line arguments are provided the
 project that the contains the current directory is used, or if run from outside
 of a given project, all projects will be used. The information to be displayed
 is specified using a go template, supplied via the -f flag, that is executed
-against the fuchsia.googlesource.com/jiri/project.ProjectState structure. This structure currently
-has the following fields:
+against the fuchsia.googlesource.com/jiri/project.ProjectState structure. This
+structure currently has the following fields:
 project.ProjectState{Branches:[]project.BranchState(nil), CurrentBranch:"",
 HasUncommitted:false, HasUntracked:false, Project:project.Project{Name:"",
-Path:"", Remote:"", RemoteBranch:"", Revision:"", GerritHost:"",
-GitHooks:"", RunHook:"", XMLName:struct {}{}}}
+Path:"", Remote:"", RemoteBranch:"", Revision:"", GerritHost:"", GitHooks:"",
+RunHook:"", XMLName:struct {}{}}}
 
 Usage:
    jiri project info [flags] <project-keys>...
@@ -287,12 +1085,16 @@ Usage:
 format to
 
 The jiri project info flags are:
- -f={{.Project.Name}}
+ -f={{.Project.Name}} ($JIRI_PROJECT_INFO_F)
    The go template for the fields to display.
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri project list - List existing jiri projects and branches
@@ -303,15 +1105,19 @@ Usage:
    jiri project list [flags]
 
 The jiri project list flags are:
- -branches=false
+ -branches=false ($JIRI_PROJECT_LIST_BRANCHES)
    Show project branches.
- -nopristine=false
+ -nopristine=false ($JIRI_PROJECT_LIST_NOPRISTINE)
    If true, omit pristine projects, i.e. projects with a clean master branch and
    no other branches.
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri project shell-prompt - Print a succinct status of projects suitable for shell prompts
@@ -325,16 +1131,20 @@ Usage:
    jiri project shell-prompt [flags]
 
 The jiri project shell-prompt flags are:
- -check-dirty=true
+ -check-dirty=true ($JIRI_PROJECT_SHELL_PROMPT_CHECK_DIRTY)
    If false, don't check for uncommitted changes or untracked files. Setting
    this option to false is dangerous: dirty master branches will not appear in
    the output.
- -show-name=false
+ -show-name=false ($JIRI_PROJECT_SHELL_PROMPT_SHOW_NAME)
    Show the name of the current repo.
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri rebuild - Rebuild all jiri tools
@@ -344,15 +1154,27 @@ $JIRI_ROOT/.jiri_root/bin. This is similar to "jiri update", but does not update
 any projects before building the tools. The set of tools to rebuild is described
 in the manifest.
 
+Tools are built from the current checkout of their projects.  Tools whose
+project revision, Go version and manifest definition haven't changed since they
+were last built are skipped, unless -force is specified.  Tools in projects with
+uncommitted changes or untracked files are always rebuilt.
+
 Run "jiri help manifest" for details on manifests.
 
 Usage:
    jiri rebuild [flags]
 
 The jiri rebuild flags are:
- -color=true
+ -force=false ($JIRI_REBUILD_FORCE)
+   Rebuild all tools, even those that are up to date.
+
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri snapshot - Manage project snapshots
@@ -370,12 +1192,16 @@ The jiri snapshot commands are:
    list        List existing project snapshots
 
 The jiri snapshot flags are:
- -dir=
+ -dir= ($JIRI_SNAPSHOT_DIR)
    Directory where snapshot are stored.  Defaults to $JIRI_ROOT/.snapshot.
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri snapshot checkout - Checkout a project snapshot
@@ -389,14 +1215,18 @@ Usage:
 <snapshot> is the snapshot manifest file.
 
 The jiri snapshot checkout flags are:
- -gc=false
+ -gc=false ($JIRI_SNAPSHOT_CHECKOUT_GC)
    Garbage collect obsolete repositories.
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -dir=
+ -dir= ($JIRI_SNAPSHOT_DIR)
    Directory where snapshot are stored.  Defaults to $JIRI_ROOT/.snapshot.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri snapshot create - Create a new project snapshot
@@ -432,16 +1262,20 @@ Usage:
 <label> is the snapshot label.
 
 The jiri snapshot create flags are:
- -push-remote=false
+ -push-remote=false ($JIRI_SNAPSHOT_CREATE_PUSH_REMOTE)
    Commit and push snapshot upstream.
- -time-format=2006-01-02T15:04:05Z07:00
+ -time-format=2006-01-02T15:04:05Z07:00 ($JIRI_SNAPSHOT_CREATE_TIME_FORMAT)
    Time format for snapshot file name.
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -dir=
+ -dir= ($JIRI_SNAPSHOT_DIR)
    Directory where snapshot are stored.  Defaults to $JIRI_ROOT/.snapshot.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri snapshot list - List existing project snapshots
@@ -456,11 +1290,15 @@ Usage:
 <label ...> is a list of snapshot labels.
 
 The jiri snapshot list flags are:
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -dir=
+ -dir= ($JIRI_SNAPSHOT_DIR)
    Directory where snapshot are stored.  Defaults to $JIRI_ROOT/.snapshot.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri update - Update all jiri tools and projects
@@ -471,22 +1309,82 @@ individual updates happen guarantees that we end up with a consistent set of
 tools and source code. The set of projects and tools to update is described in
 the manifest.
 
+Tools are only rebuilt if their project revision, the Go version or their
+manifest definition changed since they were last built.  Use "jiri rebuild
+-force" to rebuild all tools unconditionally.
+
+If projects are given, only those projects are updated, along with the manifest
+projects they are imported through, and only the tools built from them are
+rebuilt.  Projects may be given by name, by a path inside the project, or by a
+regular expression matching project keys.  Packages are not updated, and -gc is
+not supported in this mode.
+
+With -local, nothing is fetched: the projects are reset to the revisions the
+manifest specifies, as known from their last fetch.  This is useful to re-apply
+the manifest while offline.  Projects that don't exist locally can't be created
+with -local.
+
+Update only advances the master branch of each project.  With -rebase-tracked,
+local branches that track master or origin/master are then rebased onto the
+updated master; with -rebase-all, all local branches are.  Projects with
+uncommitted changes are skipped, and rebases that run into conflicts are
+aborted, leaving the branch as it was.  A report of what was rebased, skipped or
+conflicted is printed for each project.
+
 Run "jiri help manifest" for details on manifests.
 
 Usage:
-   jiri update [flags]
+   jiri update [flags] <projects>
+
+<projects> is a list of projects to update; if omitted, all projects are
+updated.
 
 The jiri update flags are:
- -attempts=1
+ -attempts=1 ($JIRI_UPDATE_ATTEMPTS)
    Number of attempts before failing.
- -gc=false
+ -gc=false ($JIRI_UPDATE_GC)
    Garbage collect obsolete repositories.
- -manifest=
+ -local=false ($JIRI_UPDATE_LOCAL)
+   Update projects without fetching from their remotes.
+ -manifest= ($JIRI_UPDATE_MANIFEST)
    Name of the project manifest.
+ -rebase-all=false ($JIRI_UPDATE_REBASE_ALL)
+   Rebase all local branches onto the updated master.
+ -rebase-tracked=false ($JIRI_UPDATE_REBASE_TRACKED)
+   Rebase local branches that track master onto the updated master.
+
+ -color=true ($JIRI_COLOR)
+   Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
+   Print verbose output.
+
+Jiri version - Print version information for jiri and its tools
+
+Prints the metadata built into the running jiri binary, followed by the metadata
+of every tool installed in $JIRI_ROOT/.jiri_root/bin.
 
- -color=true
+Tools built by jiri record the project and revision they were built from, the
+manifest or snapshot file that specified them, the build time and, for Go tools,
+the Go version.  Tools that weren't built by jiri have no metadata.
+
+Usage:
+   jiri version [flags]
+
+The jiri version flags are:
+ -json=false ($JIRI_VERSION_JSON)
+   Print the versions in JSON format.
+
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri which - Show path to the jiri tool
@@ -509,16 +1407,20 @@ Usage:
    jiri which [flags]
 
 The jiri which flags are:
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
- -v=false
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
+ -v=false ($JIRI_V)
    Print verbose output.
 
 Jiri runp - Run a command in parallel across jiri projects
 
 Run a command in parallel across one or more jiri projects. Commands are run
-using the shell specified by the users $SHELL environment variable, or "sh"
-if that's not set. Thus commands are run as $SHELL -c "args..."
+using the shell specified by the users $SHELL environment variable, or "sh" if
+that's not set. Thus commands are run as $SHELL -c "args..."
 
 Usage:
    jiri runp [flags] <command line>
@@ -529,53 +1431,53 @@ is run must be quoted to avoid expansion before being passed to runp by the
 shell.
 
 The jiri runp flags are:
- -collate-stdout=true
+ -collate-stdout=true ($JIRI_RUNP_COLLATE_STDOUT)
    Collate all stdout output from each parallel invocation and display it as if
    had been generated sequentially. This flag cannot be used with
    -show-name-prefix, -show-key-prefix or -interactive.
- -env=
-   specify an environment variable in the form: <var>=[<val>],...
- -exit-on-error=false
+ -exit-on-error=false ($JIRI_RUNP_EXIT_ON_ERROR)
    If set, all commands will killed as soon as one reports an error, otherwise,
    each will run to completion.
- -has-branch=
+ -has-branch= ($JIRI_RUNP_HAS_BRANCH)
    A regular expression specifying branch names to use in matching projects. A
    project will match if the specified branch exists, even if it is not checked
    out.
- -has-gerrit-message=false
+ -has-gerrit-message=false ($JIRI_RUNP_HAS_GERRIT_MESSAGE)
    If specified, match branches that have, or have no, gerrit message
- -has-uncommitted=false
+ -has-uncommitted=false ($JIRI_RUNP_HAS_UNCOMMITTED)
    If specified, match projects that have, or have no, uncommitted changes
- -has-untracked=false
+ -has-untracked=false ($JIRI_RUNP_HAS_UNTRACKED)
    If specified, match projects that have, or have no, untracked files
- -interactive=true
+ -interactive=true ($JIRI_RUNP_INTERACTIVE)
    If set, the command to be run is interactive and should not have its
    stdout/stderr manipulated. This flag cannot be used with -show-name-prefix,
    -show-key-prefix or -collate-stdout.
- -merge-policies=+CCFLAGS,+CGO_CFLAGS,+CGO_CXXFLAGS,+CGO_LDFLAGS,+CXXFLAGS,GOARCH,GOOS,GOPATH:,^GOROOT*,+LDFLAGS,:PATH,VDLPATH:
-   specify policies for merging environment variables
- -projects=
+ -projects= ($JIRI_RUNP_PROJECTS)
    A Regular expression specifying project keys to run commands in. By default,
    runp will use projects that have the same branch checked as the current
    project unless it is run from outside of a project in which case it will
    default to using all projects.
- -show-key-prefix=false
+ -show-key-prefix=false ($JIRI_RUNP_SHOW_KEY_PREFIX)
    If set, each line of output from each project will begin with the key of the
    project followed by a colon. This is intended for use with long running
    commands where the output needs to be streamed. Stdout and stderr are spliced
    apart. This flag cannot be used with -interactive, -show-name-prefix or
    -collate-stdout
- -show-name-prefix=false
+ -show-name-prefix=false ($JIRI_RUNP_SHOW_NAME_PREFIX)
    If set, each line of output from each project will begin with the name of the
    project followed by a colon. This is intended for use with long running
    commands where the output needs to be streamed. Stdout and stderr are spliced
    apart. This flag cannot be used with -interactive, -show-key-prefix or
    -collate-stdout.
- -v=false
+ -v=false ($JIRI_RUNP_V)
    Print verbose logging information
 
- -color=true
+ -color=true ($JIRI_COLOR)
    Use color to format output.
+ -offline=false
+   Don't access the network; use only local objects.
+ -trace=false ($JIRI_TRACE)
+   Record the commands that are run in a trace log; see "jiri help logs".
 
 Jiri help - Display help for commands or topics
 
@@ -591,14 +1493,19 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The jiri help flags are:
- -style=compact
+ -dir= ($JIRI_HELP_DIR)
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
+ -style=compact ($JIRI_HELP_STYLE)
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
- -width=<terminal width>
+ -width=<terminal width> ($JIRI_HELP_WIDTH)
    Format output to this target width in runes, or unlimited if width < 0.
    Defaults to the terminal width if available.  Override the default by setting
    the CMDLINE_WIDTH environment variable.
@@ -612,6 +1519,8 @@ looks like this:
  [root]                              # root directory (name picked by user)
  [root]/.jiri_root                   # root metadata directory
  [root]/.jiri_root/bin               # contains tool binaries (jiri, etc.)
+ [root]/.jiri_root/config            # root config file
+ [root]/.jiri_root/tool_cache        # records what each tool was built from
  [root]/.jiri_root/update_history    # contains history of update snapshots
  [root]/.manifest                    # contains jiri manifests
  [root]/[project1]                   # project directory (name picked by user)
@@ -648,7 +1557,8 @@ projects.  By using the shim script, you only need to remember to invoke the
 jiri tool from within the appropriate [root] directory, and the projects and
 tools under that [root] directory will be updated.
 
-The shim script is located at [root]/release/go/src/fuchsia.googlesource.com/jiri/scripts/jiri
+The shim script is located at
+[root]/release/go/src/fuchsia.googlesource.com/jiri/scripts/jiri
 
 2) Direct binary.  This is the jiri binary, containing all of the actual jiri
 tool logic.  The binary requires the JIRI_ROOT environment variable to point to
@@ -700,11 +1610,25 @@ Manifests have the following XML schema:
     />
     ...
   </projects>
+  <packages>
+    <package name="my-toolchain"
+             path="prebuilt/toolchain"
+             url="https://example.com/toolchain.tar.gz"
+             sha256="e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+             format="tar.gz"
+    />
+    ...
+  </packages>
   <tools>
     <tool name="jiri"
           package="fuchsia.googlesource.com/jiri"
           project="release.go.jiri"
     />
+    <tool name="my-script"
+          build="make my-script"
+          output="out/my-script"
+          project="my-project"
+    />
     ...
   </tools>
 </manifest>
@@ -758,23 +1682,66 @@ specified, then running "jiri cl upload" will upload a CL to this Gerrit host.
 
 * githooks (optional) - The path (relative to $JIRI_ROOT) of a directory
 containing git hooks that will be installed in the projects .git/hooks directory
-during each update.
+during each update.  Hooks installed by jiri run before any hook of the same
+name found in the projects .git/hooks.local directory, which is where developers
+should install their own hooks.  Existing hooks that were not installed by jiri
+are moved there automatically.
 
 * runhook (optional) - The path (relate to $JIRI_ROOT) of a script that will be
 run during each update.
 
+The <package> tags describe prebuilt archives, such as toolchains and SDKs, that
+are downloaded and extracted during each update instead of being checked out
+from a repository.  They are configured via the following attributes:
+
+* name (required) - The name of the package.
+
+* path (required) - The directory where the package will be extracted, relative
+to the jiri root.  Any existing contents of the directory are replaced.
+
+* url (required) - The url of the archive.  Both "http(s)://" and "file://" urls
+are supported.
+
+* sha256 (required) - The hex-encoded SHA-256 digest of the archive.  The update
+fails if the downloaded archive does not match.
+
+* format (optional) - The format of the archive, either "tar.gz" or "zip".
+Defaults to the format implied by the extension of the url.
+
+Packages are only downloaded again when their url, sha256 or format changes.
+Like projects, packages that are removed from the manifest are deleted by "jiri
+update -gc".
+
 The <tool> tags describe the tools that will be compiled and installed in
-$JIRI_ROOT/.jiri_root/bin after each update.  The tools must be written in go,
-and are identified by their package name and the project that contains their
-code.  They are configured via the following attributes:
+$JIRI_ROOT/.jiri_root/bin after each update.  Go tools are identified by their
+package name and the project that contains their code; other tools specify a
+build command and the artifact it produces instead.  They are configured via the
+following attributes:
 
 * name (required) - The name of the binary that will be installed in
   JIRI_ROOT/.jiri_root/bin
 
-* package (required) - The name of the Go package that will be passed to "go
-  build".
+* package (required for Go tools) - The name of the Go package that will be
+  passed to "go build".
+
+* build (required for other tools) - A shell command that builds the tool.  It
+  is run from the root of a temporary copy of the project, so that build
+  products never end up in the project itself.
+
+* output (required with build) - The path of the artifact produced by the build
+  command, relative to the project root.  It is installed under the tool name.
 
 * project (required) - The name of the project that contains the source code
   for the tool.
+
+* plugin (optional) - Set to "true" if the tool is a jiri plugin, i.e. a
+  subcommand of jiri.  The name of a plugin must start with "jiri-"; see "jiri
+  help plugins".
+
+If the project contains a go.mod file at its root, the tools in it are built in
+module mode, with module and build caches kept in $JIRI_ROOT/.jiri_root/go.
+Modules with a vendor directory are built with "-mod=vendor", all others with
+"-mod=readonly".  Tools in projects without a go.mod file are built in GOPATH
+mode.
 */
 package main
@@ -0,0 +1,193 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/project"
+	"fuchsia.googlesource.com/jiri/runutil"
+	"fuchsia.googlesource.com/jiri/simplemr"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+var (
+	grepIgnoreCaseFlag bool
+	grepJSONFlag       bool
+	grepProjectsFlag   string
+)
+
+func init() {
+	cmdGrep.Flags.BoolVar(&grepIgnoreCaseFlag, "i", false, "Ignore case differences between the pattern and the files.")
+	cmdGrep.Flags.BoolVar(&grepJSONFlag, "json", false, "Print the matches in JSON format.")
+	cmdGrep.Flags.StringVar(&grepProjectsFlag, "projects", "", "A regular expression specifying the keys of the projects to search. By default, all projects are searched.")
+	cmdGrep.CompleteFlags = map[string]cmdline.CompleteFunc{"projects": completeProjects}
+}
+
+// cmdGrep represents the "jiri grep" command.
+var cmdGrep = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runGrep),
+	Name:   "grep",
+	Short:  "Search for a pattern across jiri projects",
+	Long: `
+Runs "git grep" in parallel across all jiri projects, or those selected by
+-projects, searching the tracked files of each project for the given pattern.
+Untracked files, .git directories and anything ignored by git are not searched.
+
+Matches are printed as <path>:<line>:<text>, where <path> is relative to
+$JIRI_ROOT.  They are sorted by project path and then by file, so the output is
+the same from run to run.
+`,
+	ArgsName: "<pattern> [-- <pathspec>...]",
+	ArgsLong: `
+<pattern> is a regular expression, as understood by "git grep".  The optional
+<pathspec>... limit the search within each project, as for "git grep".
+`,
+}
+
+// grepMatch describes a single line matching the pattern.
+type grepMatch struct {
+	Project string `json:"project"`
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+}
+
+// grepInput is the input to the grep mapper for a single project.
+type grepInput struct {
+	// jirix is a clone of the X of the command for the mapper of the project,
+	// since X is not threadsafe.
+	jirix   *jiri.X
+	project project.Project
+	// relPath is the path of the project relative to the jiri root.
+	relPath string
+}
+
+type grepper struct {
+	args    []string
+	matches []grepMatch
+}
+
+func (g *grepper) Map(mr *simplemr.MR, key string, val interface{}) error {
+	in := val.(*grepInput)
+	if timer := in.jirix.TimerFork(in.project.Name); timer != nil {
+		defer timer.Finish()
+	}
+	var stdout, stderr bytes.Buffer
+	if err := in.jirix.NewSeq().Dir(in.project.Path).Capture(&stdout, &stderr).Last("git", g.args...); err != nil {
+		// git grep exits with status 1 when nothing matches.
+		if runutil.TranslateExitCode(err) == cmdline.ErrExitCode(1) && stderr.Len() == 0 {
+			return nil
+		}
+		return fmt.Errorf("git grep failed in project %q: %v\n%s", in.project.Name, err, stderr.String())
+	}
+	var matches []grepMatch
+	// With -z, each match is printed as <file>\0<line>\0<text>\n.
+	for _, line := range strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+		fields := strings.SplitN(line, "\x00", 3)
+		if len(fields) != 3 {
+			return fmt.Errorf("unexpected git grep output in project %q: %q", in.project.Name, line)
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("unexpected git grep output in project %q: %q", in.project.Name, line)
+		}
+		matches = append(matches, grepMatch{
+			Project: in.project.Name,
+			Path:    filepath.Join(in.relPath, fields[0]),
+			Line:    n,
+			Text:    fields[2],
+		})
+	}
+	mr.MapOut(key, matches)
+	return nil
+}
+
+// Reduce is called in order of the keys, i.e. the project paths, and so
+// accumulates the matches in a deterministic order.
+func (g *grepper) Reduce(mr *simplemr.MR, key string, values []interface{}) error {
+	for _, v := range values {
+		g.matches = append(g.matches, v.([]grepMatch)...)
+	}
+	return nil
+}
+
+func runGrep(jirix *jiri.X, args []string) error {
+	if len(args) == 0 {
+		return jirix.UsageErrorf("no pattern specified")
+	}
+	pattern, pathspecs := args[0], args[1:]
+	if len(pathspecs) > 0 && pathspecs[0] == "--" {
+		pathspecs = pathspecs[1:]
+	}
+	var keysRE *regexp.Regexp
+	if grepProjectsFlag != "" {
+		var err error
+		if keysRE, err = regexp.Compile(grepProjectsFlag); err != nil {
+			return fmt.Errorf("failed to compile projects regexp: %q: %v", grepProjectsFlag, err)
+		}
+	}
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	inputs := map[string]*grepInput{}
+	for key, p := range projects {
+		if keysRE != nil && !keysRE.MatchString(string(key)) {
+			continue
+		}
+		relPath, err := filepath.Rel(jirix.Root, p.Path)
+		if err != nil {
+			return err
+		}
+		// Key the inputs by path, which sorts the output by project path.
+		inputs[relPath] = &grepInput{jirix: jirix.Clone(tool.ContextOpts{}), project: p, relPath: relPath}
+	}
+
+	gitArgs := []string{"grep", "-n", "-z", "-I", "--no-color"}
+	if grepIgnoreCaseFlag {
+		gitArgs = append(gitArgs, "-i")
+	}
+	gitArgs = append(gitArgs, "-e", pattern, "--")
+	gitArgs = append(gitArgs, pathspecs...)
+	g := &grepper{args: gitArgs}
+
+	mr := simplemr.MR{}
+	in, out := make(chan *simplemr.Record, len(inputs)), make(chan *simplemr.Record, len(inputs))
+	go mr.Run(in, out, g, g)
+	for key, input := range inputs {
+		in <- &simplemr.Record{Key: key, Values: []interface{}{input}}
+	}
+	close(in)
+	<-out
+	if err := mr.Error(); err != nil {
+		return err
+	}
+
+	if grepJSONFlag {
+		matches := g.matches
+		if matches == nil {
+			matches = []grepMatch{}
+		}
+		data, err := json.MarshalIndent(matches, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(jirix.Stdout(), "%s\n", data)
+		return nil
+	}
+	for _, m := range g.matches {
+		fmt.Fprintf(jirix.Stdout(), "%s:%d:%s\n", m.Path, m.Line, m.Text)
+	}
+	return nil
+}
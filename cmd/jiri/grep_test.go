@@ -0,0 +1,90 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri/gitutil"
+	"fuchsia.googlesource.com/jiri/jiritest"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+func TestGrep(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	projects := addProjects(t, fake)
+	addFile := func(dir, name, contents string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(dir)).CommitFile(name, "add "+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addFile(projects[0].Path, "a.txt", "needle\nhay\n")
+	addFile(projects[0].Path, "sub/b.txt", "more hay\nNeedle again\n")
+	addFile(projects[2].Path, "c.txt", "needle in c\n")
+	// Untracked files are not searched.
+	if err := ioutil.WriteFile(filepath.Join(projects[1].Path, "untracked"), []byte("needle"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &stdout})
+	defer func() {
+		grepIgnoreCaseFlag, grepJSONFlag, grepProjectsFlag = false, false, ""
+	}()
+
+	if err := runGrep(fake.X, []string{"needle"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "r.a/a.txt:1:needle\nr.c/c.txt:1:needle in c\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	stdout.Reset()
+	grepIgnoreCaseFlag = true
+	if err := runGrep(fake.X, []string{"needle", "--", "sub"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "r.a/sub/b.txt:2:Needle again\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	stdout.Reset()
+	grepProjectsFlag = "r.c"
+	grepJSONFlag = true
+	if err := runGrep(fake.X, []string{"needle"}); err != nil {
+		t.Fatal(err)
+	}
+	var matches []grepMatch
+	if err := json.Unmarshal(stdout.Bytes(), &matches); err != nil {
+		t.Fatalf("Unmarshal(%q) failed: %v", stdout.String(), err)
+	}
+	want := []grepMatch{{Project: "r.c", Path: "r.c/c.txt", Line: 1, Text: "needle in c"}}
+	if !reflect.DeepEqual(matches, want) {
+		t.Errorf("got %#v, want %#v", matches, want)
+	}
+
+	stdout.Reset()
+	grepJSONFlag = false
+	if err := runGrep(fake.X, []string{"nothing-matches-this"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); got != "" {
+		t.Errorf("got %q, want no output", got)
+	}
+}
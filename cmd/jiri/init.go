@@ -0,0 +1,206 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+)
+
+var (
+	initCacheDirFlag string
+	initSetFlag      settingsFlag
+	initUpdateFlag   bool
+)
+
+func init() {
+	cmdInit.Flags.StringVar(&initCacheDirFlag, "cache-dir", "", "Directory holding the caches of the root, relative to the root unless absolute.  Defaults to $JIRI_ROOT/.jiri_root.")
+	cmdInit.Flags.Var(&initSetFlag, "set", "A <key>=<value> setting to write to the root config file.  May be repeated.")
+	cmdInit.Flags.BoolVar(&initUpdateFlag, "update", false, "Run \"jiri update\" once the root is created.")
+	// The manifest import is configured as for "jiri import".
+	cmdInit.Flags.StringVar(&flagImportName, "name", "manifest", `The name of the remote manifest project.`)
+	cmdInit.Flags.StringVar(&flagImportRemoteBranch, "remote-branch", "master", `The branch of the remote manifest project to track, without the leading "origin/".`)
+	cmdInit.Flags.StringVar(&flagImportRoot, "root", "", `Root to store the manifest project locally.`)
+}
+
+// settingsFlag is a flag.Value that collects repeated <key>=<value> flags.
+type settingsFlag []jiri.ConfigSetting
+
+func (f *settingsFlag) String() string {
+	var settings []string
+	for _, s := range *f {
+		settings = append(settings, s.Key+"="+s.Value)
+	}
+	return strings.Join(settings, ",")
+}
+
+func (f *settingsFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("invalid setting %q: want <key>=<value>", value)
+	}
+	*f = append(*f, jiri.ConfigSetting{Key: parts[0], Value: parts[1]})
+	return nil
+}
+
+// cmdInit represents the "jiri init" command.
+var cmdInit = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runInit),
+	Name:   "init",
+	Short:  "Create a new jiri root",
+	Long: `
+Command "init" creates a new jiri root in the given directory, or the current
+directory if none is given.  It creates the .jiri_root metadata directory,
+installs the running jiri binary in .jiri_root/bin, and writes the root config
+file, .jiri_root/config, from the -cache-dir and -set flags.
+
+If a manifest and remote are given, an import of the remote manifest is written
+to the .jiri_manifest file, as for "jiri import", and with -update, the
+projects and tools of the manifest are then fetched and built, as by "jiri
+update" with the flag defaults of the new config.
+
+The new root can't be inside an existing jiri root.
+
+Example:
+  $ jiri init -update myroot manifest https://foo.com/bar.git
+`,
+	ArgsName: "[<dir> [<manifest> <remote>]]",
+	ArgsLong: `
+<dir> is the directory of the new root, which is created if it doesn't exist.
+
+<manifest> and <remote> specify the manifest to import, as for "jiri import".
+`,
+}
+
+// enclosingRoot returns the existing jiri root that contains dir, or dir
+// itself, or the empty string if there is none.  The roots considered are the
+// one specified by the environment and the ones found by walking up from dir.
+func enclosingRoot(dir string) string {
+	isRoot := func(dir string) bool {
+		fi, err := os.Stat(filepath.Join(dir, jiri.RootMetaDir))
+		return err == nil && fi.IsDir()
+	}
+	if root := jiri.FindRoot(); root != "" && isRoot(root) {
+		if dir == root || strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return root
+		}
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		if isRoot(d) {
+			return d
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+func runInit(env *cmdline.Env, args []string) error {
+	var dir string
+	var importArgs []string
+	switch len(args) {
+	case 0:
+		dir = "."
+	case 1:
+		dir = args[0]
+	case 3:
+		dir, importArgs = args[0], args[1:]
+	default:
+		return env.UsageErrorf("wrong number of arguments")
+	}
+	if initUpdateFlag && importArgs == nil {
+		return env.UsageErrorf("-update requires a manifest and remote to import")
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	if root := enclosingRoot(dir); root == dir {
+		return fmt.Errorf("%v is already a jiri root", dir)
+	} else if root != "" {
+		return fmt.Errorf("cannot create a jiri root in %v, inside the jiri root %v", dir, root)
+	}
+
+	jirix, err := jiri.NewXForRoot(env, dir)
+	if err != nil {
+		return err
+	}
+	s := jirix.NewSeq()
+	if err := s.MkdirAll(jirix.BinDir(), 0755).Done(); err != nil {
+		return err
+	}
+
+	// Install the running jiri binary, so that the root is usable right away.
+	path, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	data, err := s.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := s.WriteFile(filepath.Join(jirix.BinDir(), "jiri"), data, 0755).Done(); err != nil {
+		return err
+	}
+
+	// Only the root config is written; jirix.Config also holds the settings of
+	// the user config.
+	config, err := jiri.LoadConfig(jirix.ConfigFile())
+	if err != nil {
+		return err
+	}
+	if initCacheDirFlag != "" {
+		config.Set(jiri.CacheDirKey, initCacheDirFlag)
+	}
+	for _, setting := range initSetFlag {
+		config.Set(setting.Key, setting.Value)
+	}
+	if len(config.Settings) > 0 {
+		if err := config.Write(jirix.ConfigFile()); err != nil {
+			return err
+		}
+		if jirix.Config, err = jiri.LoadConfigs(jirix.Root); err != nil {
+			return err
+		}
+	}
+
+	if importArgs != nil {
+		flagImportOverwrite, flagImportOut = false, ""
+		if err := runImport(jirix, importArgs); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(jirix.Stdout(), "Initialized jiri root in %v\n", dir)
+	if initUpdateFlag {
+		if err := setUpdateFlagDefaults(jirix); err != nil {
+			return err
+		}
+		return runUpdate(jirix, nil)
+	}
+	return nil
+}
+
+// setUpdateFlagDefaults sets the flags of "jiri update" to their defaults from
+// the config of the new root, which didn't exist when the flags were parsed.
+func setUpdateFlagDefaults(jirix *jiri.X) error {
+	for name, value := range jirix.Config.FlagDefaults([]string{cmdUpdate.Name}) {
+		f := cmdUpdate.Flags.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("invalid default %q of flag -%v of jiri update: %v", value, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/project"
+)
+
+func TestInit(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if tmpDir, err = filepath.EvalSymlinks(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	// Creating the root prepends its bin directory to PATH.
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	if oldRoot, ok := os.LookupEnv(jiri.RootEnv); ok {
+		os.Unsetenv(jiri.RootEnv)
+		defer os.Setenv(jiri.RootEnv, oldRoot)
+	}
+	defer func() {
+		initCacheDirFlag, initSetFlag = "", nil
+	}()
+
+	var stdout bytes.Buffer
+	env := cmdline.EnvFromOS()
+	env.Stdout, env.Stderr = &stdout, &stdout
+	root := filepath.Join(tmpDir, "root")
+	initCacheDirFlag = "cache"
+	for _, setting := range []string{"update.gc=true", "update.attempts=3"} {
+		if err := initSetFlag.Set(setting); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := runInit(env, []string{root, "public", "https://example.com/manifest"}); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(filepath.Join(root, jiri.RootMetaDir, "bin", "jiri")); err != nil || fi.Mode().Perm()&0100 == 0 {
+		t.Errorf("jiri binary not installed: %v", err)
+	}
+	config, err := jiri.LoadConfig(filepath.Join(root, jiri.RootMetaDir, jiri.ConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{jiri.CacheDirKey: "cache", "update.gc": "true", "update.attempts": "3"} {
+		if got, _ := config.Get(key); got != want {
+			t.Errorf("got %v %q, want %q", key, got, want)
+		}
+	}
+	jirix, err := jiri.NewXForRoot(env, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := project.ManifestFromFile(jirix, jirix.JiriManifestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Imports) != 1 || manifest.Imports[0].Remote != "https://example.com/manifest" || manifest.Imports[0].Manifest != "public" {
+		t.Errorf("got imports %#v", manifest.Imports)
+	}
+
+	// With -update, the update flags get their defaults from the new config.
+	defer func() {
+		gcFlag, attemptsFlag = false, 1
+	}()
+	if err := setUpdateFlagDefaults(jirix); err != nil {
+		t.Fatal(err)
+	}
+	if !gcFlag || attemptsFlag != 3 {
+		t.Errorf("got update flags -gc=%v -attempts=%v, want -gc=true -attempts=3", gcFlag, attemptsFlag)
+	}
+
+	// Roots can't be created again, or nested.
+	for _, dir := range []string{root, filepath.Join(root, "sub", "dir")} {
+		err := runInit(env, []string{dir})
+		if err == nil || !strings.Contains(err.Error(), root) {
+			t.Errorf("init of %v: got error %v, want nesting error", dir, err)
+		}
+	}
+}
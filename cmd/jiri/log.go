@@ -0,0 +1,134 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/project"
+)
+
+var (
+	logAuthorFlag   string
+	logFormatFlag   string
+	logFromFlag     string
+	logProjectsFlag string
+	logSinceFlag    string
+	logToFlag       string
+	logUntilFlag    string
+)
+
+func init() {
+	cmdLog.Flags.StringVar(&logAuthorFlag, "author", "", "Only show commits whose author name or email matches this regular expression.")
+	cmdLog.Flags.StringVar(&logFormatFlag, "format", "text", "The output format, text or json.")
+	cmdLog.Flags.StringVar(&logFromFlag, "from", "", "Only show commits that are not in the given snapshot file.")
+	cmdLog.Flags.StringVar(&logProjectsFlag, "projects", "", "A regular expression specifying the keys of the projects to show. By default, all projects are shown.")
+	cmdLog.CompleteFlags = map[string]cmdline.CompleteFunc{"projects": completeProjects}
+	cmdLog.Flags.StringVar(&logSinceFlag, "since", "", "Only show commits more recent than this, given as a date (2006-01-02), a time (2006-01-02T15:04:05Z07:00) or a duration before now (48h).")
+	cmdLog.Flags.StringVar(&logToFlag, "to", "", "Show the commits of the given snapshot file, instead of those of the master branches.")
+	cmdLog.Flags.StringVar(&logUntilFlag, "until", "", "Only show commits older than this, in the same formats as -since.")
+}
+
+// cmdLog represents the "jiri log" command.
+var cmdLog = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runLog),
+	Name:   "log",
+	Short:  "Show the commit history of all projects",
+	Long: `
+Shows the commits on the master branches of all jiri projects, or those selected
+by -projects, merged into a single stream, newest first.  Each commit is tagged
+with its project.
+
+The commits can be limited to those between two snapshots with -from and -to,
+to a time range with -since and -until, and to an author with -author.
+`,
+}
+
+// parseLogTime parses the value of the -since and -until flags.
+func parseLogTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: want a date, a time or a duration", value)
+}
+
+func runLog(jirix *jiri.X, args []string) error {
+	if len(args) != 0 {
+		return jirix.UsageErrorf("unexpected arguments")
+	}
+	if logFormatFlag != "text" && logFormatFlag != "json" {
+		return jirix.UsageErrorf("unknown format %q", logFormatFlag)
+	}
+	var opts project.LogOpts
+	now := time.Now()
+	var err error
+	if logSinceFlag != "" {
+		if opts.Since, err = parseLogTime(logSinceFlag, now); err != nil {
+			return jirix.UsageErrorf("-since: %v", err)
+		}
+	}
+	if logUntilFlag != "" {
+		if opts.Until, err = parseLogTime(logUntilFlag, now); err != nil {
+			return jirix.UsageErrorf("-until: %v", err)
+		}
+	}
+	opts.Author = logAuthorFlag
+	if logFromFlag != "" {
+		if opts.From, _, err = project.LoadSnapshotFile(jirix, logFromFlag); err != nil {
+			return err
+		}
+	}
+	if logToFlag != "" {
+		if opts.To, _, err = project.LoadSnapshotFile(jirix, logToFlag); err != nil {
+			return err
+		}
+	}
+
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	if logProjectsFlag != "" {
+		keysRE, err := regexp.Compile(logProjectsFlag)
+		if err != nil {
+			return fmt.Errorf("failed to compile projects regexp: %q: %v", logProjectsFlag, err)
+		}
+		for key := range projects {
+			if !keysRE.MatchString(string(key)) {
+				delete(projects, key)
+			}
+		}
+	}
+	commits, err := project.ProjectLog(jirix, projects, opts)
+	if err != nil {
+		return err
+	}
+
+	if logFormatFlag == "json" {
+		if commits == nil {
+			commits = []project.Commit{}
+		}
+		data, err := json.MarshalIndent(commits, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(jirix.Stdout(), "%s\n", data)
+		return nil
+	}
+	for _, c := range commits {
+		fmt.Fprintf(jirix.Stdout(), "%s %s %s %s: %s\n", c.Time.Local().Format("2006-01-02 15:04"), c.Project, c.Revision[:12], c.Author, c.Subject())
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"fuchsia.googlesource.com/jiri/jiritest"
+	"fuchsia.googlesource.com/jiri/project"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+func TestParseLogTime(t *testing.T) {
+	now := time.Date(2016, 5, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"48h", time.Date(2016, 5, 8, 12, 0, 0, 0, time.UTC)},
+		{"2016-05-01", time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"2016-05-01T10:00:00Z", time.Date(2016, 5, 1, 10, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		got, err := parseLogTime(test.value, now)
+		if err != nil {
+			t.Errorf("parseLogTime(%q) failed: %v", test.value, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("parseLogTime(%q): got %v, want %v", test.value, got, test.want)
+		}
+	}
+	if _, err := parseLogTime("yesterday", now); err == nil {
+		t.Errorf("parseLogTime(%q) succeeded", "yesterday")
+	}
+}
+
+func TestLog(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	addProjects(t, fake)
+
+	var stdout bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &stdout})
+	defer func() { logFormatFlag, logProjectsFlag = "text", "" }()
+
+	logProjectsFlag = `^r\.`
+	if err := runLog(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if got, want := len(lines), 5; got != want {
+		t.Errorf("got %d commits, want %d:\n%s", got, want, stdout.String())
+	}
+
+	stdout.Reset()
+	logFormatFlag, logProjectsFlag = "json", "^r.b"
+	if err := runLog(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	var commits []project.Commit
+	if err := json.Unmarshal(stdout.Bytes(), &commits); err != nil {
+		t.Fatalf("Unmarshal(%q) failed: %v", stdout.String(), err)
+	}
+	if len(commits) != 1 || commits[0].Project != "r.b" {
+		t.Errorf("got %#v, want one commit of r.b", commits)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/runutil"
+)
+
+var (
+	logsFailedFlag bool
+	logsJSONFlag   bool
+	logsNumFlag    int
+)
+
+func init() {
+	cmdLogs.Flags.BoolVar(&logsFailedFlag, "failed", false, "Only consider commands that failed.")
+	cmdLogsShow.Flags.BoolVar(&logsJSONFlag, "json", false, "Print the records as they are stored, one JSON object per line.")
+	cmdLogsShow.Flags.IntVar(&logsNumFlag, "n", 20, "Number of most recent commands to show; 0 shows all.")
+}
+
+// cmdLogs represents the "jiri logs" command.
+var cmdLogs = &cmdline.Command{
+	Name:  "logs",
+	Short: "Show the trace log of the commands run by jiri",
+	Long: `
+With the -trace flag, or if the JIRI_TRACE environment variable is set to
+true, jiri records every command it runs, such as git, in the trace log
+$JIRI_ROOT/.jiri_root/logs/trace.jsonl.  Each line of the log is a JSON object
+with the args of the command, its working directory, the environment variables
+it was run with that differ from those of jiri, how long it ran, its exit code,
+and the start of its output.
+
+Once the log grows beyond 4MB, it is renamed to trace.jsonl.1, and older logs
+are renamed in turn; the 3 most recent of those are kept.
+`,
+	Children: []*cmdline.Command{cmdLogsShow, cmdLogsLast},
+}
+
+// cmdLogsShow represents the "jiri logs show" command.
+var cmdLogsShow = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runLogsShow),
+	Name:   "show",
+	Short:  "Show the most recent commands in the trace log",
+	Long: `
+Shows the most recent commands in the trace log, oldest first, one per line:
+when each started, how long it ran, its exit code, its working directory
+relative to the jiri root, and its args.
+`,
+}
+
+// cmdLogsLast represents the "jiri logs last" command.
+var cmdLogsLast = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runLogsLast),
+	Name:   "last",
+	Short:  "Show the details of the last command in the trace log",
+	Long: `
+Shows all that the trace log records about the last command, including its
+environment and output.  Use -failed to show the last command that failed.
+`,
+}
+
+// readTraceLog returns the records of the trace log, oldest first, only of the
+// commands that failed if -failed is set.
+func readTraceLog(jirix *jiri.X) ([]runutil.TraceRecord, error) {
+	trace := jirix.TraceLog()
+	records, err := runutil.ReadTrace(trace.Files()...)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no commands in the trace log %s; run jiri with -trace to record them", trace.File())
+	}
+	if logsFailedFlag {
+		var failed []runutil.TraceRecord
+		for _, r := range records {
+			if r.Error != "" {
+				failed = append(failed, r)
+			}
+		}
+		if len(failed) == 0 {
+			return nil, fmt.Errorf("no failed commands in the trace log %s", trace.File())
+		}
+		records = failed
+	}
+	return records, nil
+}
+
+func runLogsShow(jirix *jiri.X, args []string) error {
+	if len(args) != 0 {
+		return jirix.UsageErrorf("unexpected arguments")
+	}
+	records, err := readTraceLog(jirix)
+	if err != nil {
+		return err
+	}
+	if logsNumFlag > 0 && len(records) > logsNumFlag {
+		records = records[len(records)-logsNumFlag:]
+	}
+	for _, r := range records {
+		if logsJSONFlag {
+			data, err := json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(jirix.Stdout(), "%s\n", data)
+			continue
+		}
+		fmt.Fprintf(jirix.Stdout(), "%s %6dms exit %-3d (%s) %s\n", r.Time.Format("2006-01-02 15:04:05"), r.DurationMs, r.ExitCode, relativeDir(jirix, r.Dir), formatArgs(r.Args))
+	}
+	return nil
+}
+
+func runLogsLast(jirix *jiri.X, args []string) error {
+	if len(args) != 0 {
+		return jirix.UsageErrorf("unexpected arguments")
+	}
+	records, err := readTraceLog(jirix)
+	if err != nil {
+		return err
+	}
+	printTraceRecord(jirix.Stdout(), records[len(records)-1])
+	return nil
+}
+
+// printTraceRecord prints all the fields of the given record.
+func printTraceRecord(w io.Writer, r runutil.TraceRecord) {
+	fmt.Fprintf(w, "Command:   %s\n", formatArgs(r.Args))
+	fmt.Fprintf(w, "Started:   %s\n", r.Time.Format("2006-01-02 15:04:05.000"))
+	fmt.Fprintf(w, "Duration:  %dms\n", r.DurationMs)
+	fmt.Fprintf(w, "Exit code: %d\n", r.ExitCode)
+	if r.Dir != "" {
+		fmt.Fprintf(w, "Directory: %s\n", r.Dir)
+	}
+	if r.Error != "" {
+		fmt.Fprintf(w, "Error:     %s\n", r.Error)
+	}
+	if len(r.Env) > 0 {
+		fmt.Fprintln(w, "Environment:")
+		var keys []string
+		for key := range r.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if value := r.Env[key]; value != nil {
+				fmt.Fprintf(w, "  %s=%s\n", key, *value)
+			} else {
+				fmt.Fprintf(w, "  %s (unset)\n", key)
+			}
+		}
+	}
+	if r.Output != "" {
+		fmt.Fprintln(w, "Output:")
+		for _, line := range strings.Split(strings.TrimSuffix(r.Output, "\n"), "\n") {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+		if r.Truncated {
+			fmt.Fprintln(w, "  ...")
+		}
+	}
+}
+
+// relativeDir returns dir relative to the jiri root, if it's inside it.
+func relativeDir(jirix *jiri.X, dir string) string {
+	if rel, err := filepath.Rel(jirix.Root, dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return dir
+}
+
+// formatArgs formats the args of a command like the verbose output of a
+// sequence does, with the base name of the binary.
+func formatArgs(args []string) string {
+	var quoted []string
+	for i, arg := range args {
+		if i == 0 {
+			arg = filepath.Base(arg)
+		}
+		if strings.IndexAny(arg, "\"' |") != -1 {
+			arg = strconv.Quote(arg)
+		}
+		quoted = append(quoted, arg)
+	}
+	return strings.Join(quoted, " ")
+}
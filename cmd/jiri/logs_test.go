@@ -0,0 +1,69 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fuchsia.googlesource.com/jiri/jiritest"
+	"fuchsia.googlesource.com/jiri/runutil"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+func TestLogs(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	var stdout bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &stdout})
+	defer func() { logsFailedFlag, logsJSONFlag, logsNumFlag = false, false, 20 }()
+
+	if err := runLogsShow(fake.X, nil); err == nil || !strings.Contains(err.Error(), "-trace") {
+		t.Errorf("got error %v for an empty log", err)
+	}
+
+	start := time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)
+	value := "1"
+	for _, r := range []runutil.TraceRecord{
+		{Time: start, Args: []string{"/usr/bin/git", "fetch", "origin"}, Dir: filepath.Join(fake.X.Root, "p"), DurationMs: 10},
+		{Time: start.Add(time.Second), Args: []string{"/usr/bin/git", "commit", "-m", "a message"}, Dir: fake.X.Root, Env: map[string]*string{"A": &value, "B": nil}, ExitCode: 1, Error: "exit status 1", Output: "nothing to commit\n"},
+		{Time: start.Add(2 * time.Second), Args: []string{"/usr/bin/git", "status"}, Dir: "/elsewhere"},
+	} {
+		if err := fake.X.TraceLog().Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logsNumFlag = 2
+	if err := runLogsShow(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `2016-05-01 12:00:01      0ms exit 1   (.) git commit -m "a message"
+2016-05-01 12:00:02      0ms exit 0   (/elsewhere) git status
+`
+	if got := stdout.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	stdout.Reset()
+	logsFailedFlag = true
+	if err := runLogsLast(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Command:   git commit -m \"a message\"\n",
+		"Exit code: 1\n",
+		"Error:     exit status 1\n",
+		"Environment:\n  A=1\n  B (unset)\n",
+		"Output:\n  nothing to commit\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+}
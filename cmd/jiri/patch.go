@@ -0,0 +1,290 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/gitutil"
+	"fuchsia.googlesource.com/jiri/project"
+)
+
+var formatPatchOutputFlag string
+
+func init() {
+	cmdFormatPatch.Flags.StringVar(&formatPatchOutputFlag, "o", "", "Write the patches to this file instead of stdout.")
+}
+
+// cmdDiff represents the "jiri diff" command.
+var cmdDiff = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runDiff),
+	Name:   "diff",
+	Short:  "Show changes across projects as a single diff",
+	Long: `
+Prints the changes in all jiri projects as a single unified diff, with file
+names relative to $JIRI_ROOT, e.g. "a/<project path>/<file>".
+
+Without arguments, shows the uncommitted changes to tracked files of each
+project.  Given a branch, shows the changes on that branch since it forked from
+master, in each project that has the branch.  Given a snapshot file, shows the
+differences between the revisions in the snapshot and the working tree of each
+project in the snapshot.
+`,
+	ArgsName: "[<branch> | <snapshot>]",
+	ArgsLong: "<branch> is a local branch, and <snapshot> a snapshot file.",
+}
+
+// cmdFormatPatch represents the "jiri format-patch" command.
+var cmdFormatPatch = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runFormatPatch),
+	Name:   "format-patch",
+	Short:  "Export the commits of a branch across projects as patches",
+	Long: `
+Exports the commits on the given branch that are not on master, in every project
+that has the branch, as a single mailbox of patches.  File names in the patches
+are relative to $JIRI_ROOT, so that "jiri am" can apply them to the right
+projects of another jiri root, without network access.
+`,
+	ArgsName: "<branch>",
+	ArgsLong: "<branch> is the branch to export.",
+}
+
+// cmdAm represents the "jiri am" command.
+var cmdAm = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runAm),
+	Name:   "am",
+	Short:  "Apply patches exported by jiri format-patch",
+	Long: `
+Applies a mailbox of patches created by "jiri format-patch" to the current
+branches of the projects they touch.  Each patch is mapped to a project by the
+project path that "jiri format-patch" records in its X-Jiri-Project header, and
+is committed to that project with "git am".
+
+If a patch doesn't apply, the "git am" of that project is aborted, and projects
+that were already patched are left as they are.
+`,
+	ArgsName: "<file>",
+	ArgsLong: "<file> is the mailbox of patches to apply.",
+}
+
+// localProjectPaths returns the given projects keyed by their paths relative
+// to the jiri root.
+func localProjectPaths(jirix *jiri.X, projects project.Projects) (map[string]project.Project, error) {
+	paths := map[string]project.Project{}
+	for _, p := range projects {
+		relPath, err := filepath.Rel(jirix.Root, p.Path)
+		if err != nil {
+			return nil, err
+		}
+		paths[filepath.ToSlash(relPath)] = p
+	}
+	return paths, nil
+}
+
+// sortedPaths returns the keys of the given map in order.
+func sortedPaths(paths map[string]project.Project) []string {
+	var sorted []string
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func runDiff(jirix *jiri.X, args []string) error {
+	if len(args) > 1 {
+		return jirix.UsageErrorf("expected at most one branch or snapshot, got %d arguments", len(args))
+	}
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	paths, err := localProjectPaths(jirix, projects)
+	if err != nil {
+		return err
+	}
+	// diffArgs returns the "git diff" arguments for the given project, or
+	// nil if the project should be skipped.
+	diffArgs := func(git *gitutil.Git, p project.Project) []string {
+		return []string{"HEAD"}
+	}
+	if len(args) == 1 {
+		arg := args[0]
+		if isFile, err := jirix.NewSeq().IsFile(arg); err == nil && isFile {
+			snapshot, _, err := project.LoadSnapshotFile(jirix, arg)
+			if err != nil {
+				return err
+			}
+			diffArgs = func(git *gitutil.Git, p project.Project) []string {
+				s, ok := snapshot[p.Key()]
+				if !ok || s.Revision == "" {
+					return nil
+				}
+				return []string{s.Revision}
+			}
+		} else {
+			diffArgs = func(git *gitutil.Git, p project.Project) []string {
+				if !git.BranchExists(arg) {
+					return nil
+				}
+				return []string{"master..." + arg}
+			}
+		}
+	}
+	for _, path := range sortedPaths(paths) {
+		p := paths[path]
+		git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(p.Path))
+		args := diffArgs(git, p)
+		if args == nil {
+			continue
+		}
+		diff, err := git.Diff(path, append(args, "--")...)
+		if err != nil {
+			return fmt.Errorf("error computing diff of project %q: %v", p.Name, err)
+		}
+		fmt.Fprint(jirix.Stdout(), diff)
+	}
+	return nil
+}
+
+func runFormatPatch(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("expected one branch, got %d arguments", len(args))
+	}
+	branch := args[0]
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	paths, err := localProjectPaths(jirix, projects)
+	if err != nil {
+		return err
+	}
+	var mbox bytes.Buffer
+	found := false
+	for _, path := range sortedPaths(paths) {
+		p := paths[path]
+		git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(p.Path))
+		if !git.BranchExists(branch) {
+			continue
+		}
+		found = true
+		patches, err := git.FormatPatch(path, "master.."+branch, projectHeader+": "+path)
+		if err != nil {
+			return fmt.Errorf("error exporting patches of project %q: %v", p.Name, err)
+		}
+		mbox.WriteString(patches)
+	}
+	if !found {
+		return fmt.Errorf("no project has branch %q", branch)
+	}
+	if formatPatchOutputFlag != "" {
+		return jirix.NewSeq().WriteFile(formatPatchOutputFlag, mbox.Bytes(), 0644).Done()
+	}
+	_, err = io.Copy(jirix.Stdout(), &mbox)
+	return err
+}
+
+// mboxFromRE matches the line that starts each message of a mailbox created
+// by "git format-patch".
+var mboxFromRE = regexp.MustCompile(`(?m)^From [0-9a-f]{40} `)
+
+// projectHeader is the header that records, in each message of a mailbox
+// created by "jiri format-patch", the path of the project that the patch
+// applies to, relative to the jiri root.
+const projectHeader = "X-Jiri-Project"
+
+// projectHeaderRE matches the project header of a message.
+var projectHeaderRE = regexp.MustCompile(`(?m)^` + projectHeader + `: (.*)$`)
+
+// projectPatches holds the patches that apply to a single project.
+type projectPatches struct {
+	path    string
+	project project.Project
+	mbox    bytes.Buffer
+}
+
+// splitPatches splits the given mailbox into the patches of each project,
+// identified by the given project paths.  The projects are returned in the
+// order in which they first appear in the mailbox.
+func splitPatches(mbox []byte, paths map[string]project.Project) ([]*projectPatches, error) {
+	starts := mboxFromRE.FindAllIndex(mbox, -1)
+	if len(starts) == 0 {
+		return nil, fmt.Errorf("no patches found")
+	}
+	var result []*projectPatches
+	byPath := map[string]*projectPatches{}
+	for i, start := range starts {
+		end := len(mbox)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		message := mbox[start[0]:end]
+		// The headers of the message end at the first empty line.
+		headers := message
+		if n := bytes.Index(message, []byte("\n\n")); n >= 0 {
+			headers = message[:n]
+		}
+		match := projectHeaderRE.FindSubmatch(headers)
+		if match == nil {
+			return nil, fmt.Errorf("patch %d has no %s header", i+1, projectHeader)
+		}
+		path := string(match[1])
+		if _, ok := paths[path]; !ok {
+			return nil, fmt.Errorf("project %q of patch %d is not in this root", path, i+1)
+		}
+		patches, ok := byPath[path]
+		if !ok {
+			patches = &projectPatches{path: path, project: paths[path]}
+			byPath[path] = patches
+			result = append(result, patches)
+		}
+		patches.mbox.Write(message)
+	}
+	return result, nil
+}
+
+func runAm(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("expected one file, got %d arguments", len(args))
+	}
+	mbox, err := jirix.NewSeq().ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	paths, err := localProjectPaths(jirix, projects)
+	if err != nil {
+		return err
+	}
+	patches, err := splitPatches(mbox, paths)
+	if err != nil {
+		return err
+	}
+	for _, pp := range patches {
+		git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(pp.project.Path))
+		// Strip the "a/" prefix and the project path.
+		strip := 2 + strings.Count(pp.path, "/")
+		if err := git.Am(pp.mbox.Bytes(), strip); err != nil {
+			if err := git.AmAbort(); err != nil {
+				return err
+			}
+			return fmt.Errorf("error applying patches to project %q: %v", pp.project.Name, err)
+		}
+		fmt.Fprintf(jirix.Stdout(), "Applied patches to project %q\n", pp.project.Name)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri/gitutil"
+	"fuchsia.googlesource.com/jiri/jiritest"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+// TestPatches checks that changes on a branch that spans projects can be
+// diffed, exported with format-patch and applied again with am.
+func TestPatches(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	projects := addProjects(t, fake)
+	a, c := projects[0], projects[2]
+	// Git quotes unusual file names in the headers of patches.
+	unusual := "naïve feature.txt"
+	for _, p := range []string{a.Path, c.Path} {
+		git := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(p))
+		if err := git.CreateAndCheckoutBranch("feature"); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{"feature.txt", unusual} {
+			if err := ioutil.WriteFile(filepath.Join(p, file), []byte("feature in "+filepath.Base(p)+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := git.CommitFile(file, "add "+file+" to "+filepath.Base(p)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := git.CheckoutBranch("master"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &stdout})
+	defer func() { formatPatchOutputFlag = "" }()
+
+	if err := runDiff(fake.X, []string{"feature"}); err != nil {
+		t.Fatal(err)
+	}
+	diff := stdout.String()
+	for _, want := range []string{
+		"diff --git a/r.a/feature.txt b/r.a/feature.txt\n",
+		"+++ b/r.c/feature.txt\n",
+		"+feature in r.c\n",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff does not contain %q:\n%s", want, diff)
+		}
+	}
+	if strings.Index(diff, "r.a/") > strings.Index(diff, "r.c/") {
+		t.Errorf("diff is not sorted by project path:\n%s", diff)
+	}
+
+	// Export the branch, and apply it to the master branches.
+	mbox := filepath.Join(fake.X.Root, "feature.mbox")
+	formatPatchOutputFlag = mbox
+	if err := runFormatPatch(fake.X, []string{"feature"}); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	if err := runAm(fake.X, []string{mbox}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "Applied patches to project \"r.a\"\nApplied patches to project \"r.c\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, p := range []string{a.Path, c.Path} {
+		for _, file := range []string{"feature.txt", unusual} {
+			data, err := ioutil.ReadFile(filepath.Join(p, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(data), "feature in "+filepath.Base(p)+"\n"; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		}
+	}
+
+	// The patches were committed.
+	stdout.Reset()
+	if err := runDiff(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); got != "" {
+		t.Errorf("got diff %q, want none", got)
+	}
+
+	// Applying the patches again fails, and leaves no am in progress.
+	if err := runAm(fake.X, []string{mbox}); err == nil {
+		t.Errorf("applying patches twice succeeded")
+	}
+	if _, err := ioutil.ReadDir(filepath.Join(a.Path, ".git", "rebase-apply")); err == nil {
+		t.Errorf("git am was not aborted")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/project"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+// cmdPlugins represents the "jiri plugins" command.
+var cmdPlugins = &cmdline.Command{
+	Name:  "plugins",
+	Short: "Manage jiri plugins",
+	Long: `
+Plugins are binaries on PATH whose names start with "jiri-"; "jiri foo" runs
+the jiri-foo plugin with the remaining args.  Plugins describe themselves by
+printing JSON like the following when run with --jiri-plugin-info:
+
+  {
+    "name": "foo",
+    "short": "Do foo",
+    "min_jiri_version": "1.2",
+    "needs_root": true
+  }
+
+The "name" is the plugin name without the "jiri-" prefix, and "short" is the
+description shown by "jiri help".  The optional "min_jiri_version" is the
+oldest version of jiri the plugin works with, and "needs_root" indicates
+whether the plugin must be run in a jiri root.  The latter is advisory: it is
+reported by "jiri plugins", but not checked when jiri runs the plugin.
+
+The info is cached in $XDG_CACHE_HOME/jiri/plugins.json, and refreshed when a
+plugin binary changes; failures to write the cache are only reported.  Plugins without info are still run, and described by
+running them with -help.
+
+Manifests can declare the tools they build as plugins; see "jiri help
+manifest".
+`,
+	Children: []*cmdline.Command{cmdPluginsList, cmdPluginsDoctor},
+}
+
+// cmdPluginsList represents the "jiri plugins list" command.
+var cmdPluginsList = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runPluginsList),
+	Name:   "list",
+	Short:  "List the plugins on PATH",
+	Long: `
+Lists the plugins that "jiri" runs, with their descriptions, and whether they
+need a jiri root.  Plugins shadowed by others of the same name earlier on PATH
+aren't listed.
+`,
+}
+
+func runPluginsList(env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return env.UsageErrorf("unexpected arguments")
+	}
+	cache := loadPluginCache()
+	w := tabwriter.NewWriter(env.Stdout, 0, 0, 2, ' ', 0)
+	for _, p := range jiri.FindPlugins(env.Vars["PATH"]) {
+		if p.ShadowedBy != "" {
+			continue
+		}
+		short := "(no plugin info)"
+		if info, err := cache.Info(p.Path); err == nil {
+			short = info.Short
+			if info.NeedsRoot {
+				short += " (needs a jiri root)"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\n", p.Name, short)
+	}
+	return w.Flush()
+}
+
+// cmdPluginsDoctor represents the "jiri plugins doctor" command.
+var cmdPluginsDoctor = &cmdline.Command{
+	Runner: cmdline.RunnerFunc(runPluginsDoctor),
+	Name:   "doctor",
+	Short:  "Report broken or incompatible plugins",
+	Long: `
+Checks the plugins on PATH, and reports the problems it finds:
+
+* Plugins that fail to print valid info when run with --jiri-plugin-info.
+
+* Plugins whose info names a different plugin.
+
+* Plugins that need a more recent version of jiri.
+
+* Plugins that need a jiri root, when run outside of one.
+
+* Plugins that are shadowed by a jiri command, or by another plugin of the
+  same name earlier on PATH.
+
+* Plugins declared by the manifest that aren't installed in
+  $JIRI_ROOT/.jiri_root/bin, when run in a jiri root.
+
+Fails if there are any problems.
+`,
+}
+
+func runPluginsDoctor(env *cmdline.Env, args []string) error {
+	if len(args) != 0 {
+		return env.UsageErrorf("unexpected arguments")
+	}
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	builtin := map[string]bool{"help": true}
+	for _, child := range cmdRoot.Children {
+		builtin[child.Name] = true
+	}
+	root := jiri.FindRoot()
+	cache := loadPluginCache()
+	plugins := jiri.FindPlugins(env.Vars["PATH"])
+	for _, p := range plugins {
+		switch {
+		case builtin[p.Name]:
+			report("%s: shadowed by the jiri command %q", p.Path, p.Name)
+			continue
+		case p.ShadowedBy != "":
+			report("%s: shadowed by %s", p.Path, p.ShadowedBy)
+			continue
+		}
+		info, err := cache.Info(p.Path)
+		if err != nil {
+			report("%s: %v", p.Path, strings.TrimSpace(err.Error()))
+			continue
+		}
+		if info.Name != p.Name {
+			report("%s: plugin info has name %q, want %q", p.Path, info.Name, p.Name)
+		}
+		if err := info.CheckJiriVersion(tool.Version); err != nil {
+			report("%s: %v", p.Path, err)
+		}
+		if info.NeedsRoot && root == "" {
+			report("%s: needs a jiri root, and %s is not set", p.Path, jiri.RootEnv)
+		}
+	}
+	if root != "" {
+		missing, err := missingManifestPlugins(env, root)
+		if err != nil {
+			return err
+		}
+		for _, file := range missing {
+			report("%s: declared as a plugin by the manifest, but not installed", file)
+		}
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(env.Stdout, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d plugin problem(s)", len(problems))
+	}
+	fmt.Fprintf(env.Stdout, "%d plugin(s) OK\n", len(plugins))
+	return nil
+}
+
+// missingManifestPlugins returns the paths of the plugins declared by the
+// manifest of the given jiri root that aren't installed.
+func missingManifestPlugins(env *cmdline.Env, root string) ([]string, error) {
+	jirix, err := jiri.NewXForRoot(env, root)
+	if err != nil {
+		return nil, err
+	}
+	_, tools, err := project.LoadManifest(jirix)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, t := range tools {
+		if !t.Plugin {
+			continue
+		}
+		file := filepath.Join(jirix.BinDir(), t.Name)
+		if _, err := jirix.NewSeq().Stat(file); err != nil {
+			missing = append(missing, file)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// pluginCache is the plugin cache of the running command, loaded by
+// loadPluginCache when first needed, and saved by savePluginCache.
+var pluginCache *jiri.PluginCache
+
+func loadPluginCache() *jiri.PluginCache {
+	if pluginCache == nil {
+		pluginCache = jiri.LoadPluginCache(jiri.PluginCacheFile())
+	}
+	return pluginCache
+}
+
+// savePluginCache writes the plugin cache, if it was loaded and changed.  The
+// cache only saves running plugins, so failures are just reported.
+func savePluginCache() {
+	if pluginCache == nil {
+		return
+	}
+	if err := pluginCache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: couldn't save the plugin cache: %v\n", err)
+	}
+}
+
+// pluginShort returns the short description of the plugin with the given
+// binary from its plugin info, for help.
+func pluginShort(binary string) (string, bool) {
+	info, err := loadPluginCache().Info(binary)
+	if err != nil || info.Short == "" {
+		return "", false
+	}
+	return info.Short + "\n", true
+}
@@ -0,0 +1,135 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/jiritest"
+	"fuchsia.googlesource.com/jiri/project"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+func TestPlugins(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	if err := fake.CreateRemoteProject("tools"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.AddProject(project.Project{
+		Name:   "tools",
+		Path:   filepath.Join(fake.X.Root, "tools"),
+		Remote: fake.Projects["tools"],
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.AddTool(project.Tool{
+		Name:    "jiri-declared",
+		Build:   "touch declared",
+		Output:  "declared",
+		Project: "tools",
+		Plugin:  true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	// The declared plugin goes missing.
+	if err := os.Remove(filepath.Join(fake.X.BinDir(), "jiri-declared")); err != nil {
+		t.Fatal(err)
+	}
+
+	pluginDir := filepath.Join(fake.X.Root, "plugins")
+	if err := os.Mkdir(pluginDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, info := range map[string]string{
+		"jiri-good":   `{"name": "good", "short": "Good plugin", "needs_root": true}`,
+		"jiri-bad":    `not json`,
+		"jiri-new":    `{"name": "new", "short": "New plugin", "min_jiri_version": "2.0"}`,
+		"jiri-other":  `{"name": "renamed", "short": "Renamed plugin"}`,
+		"jiri-update": `{"name": "update", "short": "Shadowed plugin"}`,
+	} {
+		script := "#!/bin/sh\necho '" + info + "'\n"
+		if err := ioutil.WriteFile(filepath.Join(pluginDir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldRoot, oldCache, oldVersion := os.Getenv(jiri.RootEnv), os.Getenv("XDG_CACHE_HOME"), tool.Version
+	defer func() {
+		os.Setenv(jiri.RootEnv, oldRoot)
+		os.Setenv("XDG_CACHE_HOME", oldCache)
+		tool.Version = oldVersion
+		pluginCache = nil
+	}()
+	pluginCache = nil
+	os.Setenv(jiri.RootEnv, fake.X.Root)
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(fake.X.Root, "cache"))
+	tool.Version = "1.5"
+
+	var stdout bytes.Buffer
+	env := &cmdline.Env{Stdout: &stdout, Stderr: &stdout, Vars: map[string]string{
+		"PATH": pluginDir + string(os.PathListSeparator) + os.Getenv("PATH"),
+	}}
+	if err := runPluginsList(env, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"bad     (no plugin info)\n",
+		"good    Good plugin (needs a jiri root)\n",
+		"new     New plugin\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("list output does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if err := runPluginsDoctor(env, nil); err == nil || !strings.Contains(err.Error(), "plugin problem") {
+		t.Errorf("got error %v from doctor, want one about plugin problems", err)
+	}
+	got := stdout.String()
+	for _, want := range []string{
+		filepath.Join(pluginDir, "jiri-bad") + ": invalid output",
+		filepath.Join(pluginDir, "jiri-new") + ": needs jiri version 2.0 or later, have 1.5\n",
+		filepath.Join(pluginDir, "jiri-other") + `: plugin info has name "renamed", want "other"` + "\n",
+		filepath.Join(pluginDir, "jiri-update") + `: shadowed by the jiri command "update"` + "\n",
+		filepath.Join(fake.X.BinDir(), "jiri-declared") + ": declared as a plugin by the manifest, but not installed\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("doctor output does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "jiri-good") {
+		t.Errorf("doctor reported the good plugin:\n%s", got)
+	}
+
+	// The cache is written once, when the command is done.
+	cacheFile := filepath.Join(fake.X.Root, "cache", "jiri", "plugins.json")
+	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
+		t.Errorf("plugin cache was written before the command was done: %v", err)
+	}
+	savePluginCache()
+	if _, err := os.Stat(cacheFile); err != nil {
+		t.Errorf("plugin cache wasn't written: %v", err)
+	}
+
+	// Outside of a jiri root, plugins that need one are reported.
+	os.Setenv(jiri.RootEnv, "")
+	stdout.Reset()
+	runPluginsDoctor(env, nil)
+	if want := filepath.Join(pluginDir, "jiri-good") + ": needs a jiri root"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("doctor output does not contain %q:\n%s", want, stdout.String())
+	}
+}
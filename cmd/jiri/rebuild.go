@@ -9,10 +9,15 @@ import (
 
 	"fuchsia.googlesource.com/jiri"
 	"fuchsia.googlesource.com/jiri/cmdline"
-	"fuchsia.googlesource.com/jiri/collect"
 	"fuchsia.googlesource.com/jiri/project"
 )
 
+var rebuildForceFlag bool
+
+func init() {
+	cmdRebuild.Flags.BoolVar(&rebuildForceFlag, "force", false, "Rebuild all tools, even those that are up to date.")
+}
+
 // cmdRebuild represents the "jiri rebuild" command.
 var cmdRebuild = &cmdline.Command{
 	Runner: jiri.RunnerFunc(runRebuild),
@@ -24,33 +29,26 @@ $JIRI_ROOT/.jiri_root/bin. This is similar to "jiri update", but does not update
 any projects before building the tools. The set of tools to rebuild is described
 in the manifest.
 
+Tools are built from the current checkout of their projects.  Tools whose
+project revision, Go version and manifest definition haven't changed since they
+were last built are skipped, unless -force is specified.  Tools in projects with
+uncommitted changes or untracked files are always rebuilt.
+
 Run "jiri help manifest" for details on manifests.
 `,
 }
 
-func runRebuild(jirix *jiri.X, args []string) (e error) {
+func runRebuild(jirix *jiri.X, args []string) error {
 	projects, tools, err := project.LoadManifest(jirix)
 	if err != nil {
 		return err
 	}
 
-	// Create a temporary directory in which tools will be built.
-	tmpDir, err := jirix.NewSeq().TempDir("", "tmp-jiri-rebuild")
-	if err != nil {
-		return fmt.Errorf("TempDir() failed: %v", err)
-	}
-
-	// Make sure we cleanup the temp directory.
-	defer collect.Error(func() error { return jirix.NewSeq().RemoveAll(tmpDir).Done() }, &e)
-
 	// Paranoid sanity checking.
 	if _, ok := tools[project.JiriName]; !ok {
 		return fmt.Errorf("tool %q not found", project.JiriName)
 	}
 
 	// Build and install tools.
-	if err := project.BuildTools(jirix, projects, tools, tmpDir); err != nil {
-		return err
-	}
-	return project.InstallTools(jirix, tmpDir)
+	return project.RebuildTools(jirix, projects, tools, rebuildForceFlag)
 }
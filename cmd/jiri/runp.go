@@ -87,6 +87,10 @@ func init() {
 	cmdRunP = newRunP()
 	cmdRoot.Children = append(cmdRoot.Children, cmdRunP)
 	registerCommonFlags(&cmdRunP.Flags, &runpFlags)
+	cmdRunP.CompleteFlags = map[string]cmdline.CompleteFunc{
+		"has-branch": completeBranches,
+		"projects":   completeProjects,
+	}
 }
 
 type mapInput struct {
@@ -173,6 +177,9 @@ func (r *runner) Map(mr *simplemr.MR, key string, val interface{}) error {
 		key: key,
 		mi:  mi}
 	jirix := mi.jirix
+	if timer := jirix.TimerFork(mi.ProjectState.Project.Name); timer != nil {
+		defer timer.Finish()
+	}
 	path := os.Getenv("SHELL")
 	if path == "" {
 		path = "sh"
@@ -425,6 +432,8 @@ func runp(jirix *jiri.X, cmd *cmdline.Command, args []string) error {
 		mr.NumMappers = 1
 		sort.Sort(keys)
 	}
+	jirix.TimerPush("run commands")
+	defer jirix.TimerPop()
 	in, out := make(chan *simplemr.Record, len(mapInputs)), make(chan *simplemr.Record, len(mapInputs))
 	sigch := make(chan os.Signal)
 	signal.Notify(sigch, os.Interrupt)
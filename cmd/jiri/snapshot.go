@@ -37,6 +37,8 @@ func init() {
 	cmdSnapshotCheckout.Flags.BoolVar(&snapshotGcFlag, "gc", false, "Garbage collect obsolete repositories.")
 	cmdSnapshotCreate.Flags.BoolVar(&pushRemoteFlag, "push-remote", false, "Commit and push snapshot upstream.")
 	cmdSnapshotCreate.Flags.StringVar(&timeFormatFlag, "time-format", time.RFC3339, "Time format for snapshot file name.")
+	cmdSnapshotCheckout.CompleteArgs = completeSnapshotFiles
+	cmdSnapshotList.CompleteArgs = completeSnapshotLabels
 }
 
 var cmdSnapshot = &cmdline.Command{
@@ -101,6 +103,10 @@ func runSnapshotCreate(jirix *jiri.X, args []string) error {
 		return createSnapshot(jirix, snapshotDir, snapshotFile, label)
 	}
 
+	if err := jirix.RequireOnline("pushing snapshots"); err != nil {
+		return err
+	}
+
 	// Attempt to create a snapshot on a clean master branch.  If snapshot
 	// creation fails, return to the state we were in before.
 	createFn := func() error {
@@ -126,6 +132,30 @@ func runSnapshotCreate(jirix *jiri.X, args []string) error {
 	return project.ApplyToLocalMaster(jirix, project.Projects{p.Key(): p}, createFn)
 }
 
+// snapshotLabels returns all known snapshot labels, using a heuristic that
+// looks for all symbolic links <foo> in the snapshot directory that point to a
+// file in the "labels/<foo>" subdirectory of the snapshot directory.
+func snapshotLabels(snapshotDir string) ([]string, error) {
+	fileInfoList, err := ioutil.ReadDir(snapshotDir)
+	if err != nil {
+		return nil, fmt.Errorf("ReadDir(%v) failed: %v", snapshotDir, err)
+	}
+	var labels []string
+	for _, fileInfo := range fileInfoList {
+		if fileInfo.Mode()&os.ModeSymlink != 0 {
+			path := filepath.Join(snapshotDir, fileInfo.Name())
+			dst, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				return nil, fmt.Errorf("EvalSymlinks(%v) failed: %v", path, err)
+			}
+			if strings.HasSuffix(filepath.Dir(dst), filepath.Join("labels", fileInfo.Name())) {
+				labels = append(labels, fileInfo.Name())
+			}
+		}
+	}
+	return labels, nil
+}
+
 // getSnapshotDir returns the path to the snapshot directory, creating it if
 // necessary.
 func getSnapshotDir(jirix *jiri.X) (string, error) {
@@ -241,26 +271,8 @@ func runSnapshotList(jirix *jiri.X, args []string) error {
 		return err
 	}
 	if len(args) == 0 {
-		// Identify all known snapshot labels, using a
-		// heuristic that looks for all symbolic links <foo>
-		// in the snapshot directory that point to a file in
-		// the "labels/<foo>" subdirectory of the snapshot
-		// directory.
-		fileInfoList, err := ioutil.ReadDir(snapshotDir)
-		if err != nil {
-			return fmt.Errorf("ReadDir(%v) failed: %v", snapshotDir, err)
-		}
-		for _, fileInfo := range fileInfoList {
-			if fileInfo.Mode()&os.ModeSymlink != 0 {
-				path := filepath.Join(snapshotDir, fileInfo.Name())
-				dst, err := filepath.EvalSymlinks(path)
-				if err != nil {
-					return fmt.Errorf("EvalSymlinks(%v) failed: %v", path, err)
-				}
-				if strings.HasSuffix(filepath.Dir(dst), filepath.Join("labels", fileInfo.Name())) {
-					args = append(args, fileInfo.Name())
-				}
-			}
+		if args, err = snapshotLabels(snapshotDir); err != nil {
+			return err
 		}
 	}
 
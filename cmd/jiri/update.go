@@ -5,6 +5,8 @@
 package main
 
 import (
+	"fmt"
+
 	"fuchsia.googlesource.com/jiri"
 	"fuchsia.googlesource.com/jiri/cmdline"
 	"fuchsia.googlesource.com/jiri/project"
@@ -13,8 +15,11 @@ import (
 )
 
 var (
-	gcFlag       bool
-	attemptsFlag int
+	gcFlag            bool
+	attemptsFlag      int
+	updateLocalFlag   bool
+	rebaseTrackedFlag bool
+	rebaseAllFlag     bool
 )
 
 func init() {
@@ -22,6 +27,9 @@ func init() {
 
 	cmdUpdate.Flags.BoolVar(&gcFlag, "gc", false, "Garbage collect obsolete repositories.")
 	cmdUpdate.Flags.IntVar(&attemptsFlag, "attempts", 1, "Number of attempts before failing.")
+	cmdUpdate.Flags.BoolVar(&updateLocalFlag, "local", false, "Update projects without fetching from their remotes.")
+	cmdUpdate.Flags.BoolVar(&rebaseTrackedFlag, "rebase-tracked", false, "Rebase local branches that track master onto the updated master.")
+	cmdUpdate.Flags.BoolVar(&rebaseAllFlag, "rebase-all", false, "Rebase all local branches onto the updated master.")
 }
 
 // cmdUpdate represents the "jiri update" command.
@@ -36,16 +44,89 @@ individual updates happen guarantees that we end up with a consistent set of
 tools and source code. The set of projects and tools to update is described in
 the manifest.
 
+Tools are only rebuilt if their project revision, the Go version or their
+manifest definition changed since they were last built.  Use "jiri rebuild
+-force" to rebuild all tools unconditionally.
+
+If projects are given, only those projects are updated, along with the manifest
+projects they are imported through, and only the tools built from them are
+rebuilt.  Projects may be given by name, by a path inside the project, or by a
+regular expression matching project keys.  Packages are not updated, and -gc
+is not supported in this mode.
+
+With -local, nothing is fetched: the projects are reset to the revisions the
+manifest specifies, as known from their last fetch.  This is useful to
+re-apply the manifest while offline.  Projects that don't exist locally can't
+be created with -local.
+
+Update only advances the master branch of each project.  With -rebase-tracked,
+local branches that track master or origin/master are then rebased onto the
+updated master; with -rebase-all, all local branches are.  Projects with
+uncommitted changes are skipped, and rebases that run into conflicts are
+aborted, leaving the branch as it was.  A report of what was rebased, skipped
+or conflicted is printed for each project.
+
 Run "jiri help manifest" for details on manifests.
 `,
+	ArgsName: "<projects>",
+	ArgsLong: "<projects> is a list of projects to update; if omitted, all projects are updated.",
 }
 
-func runUpdate(jirix *jiri.X, _ []string) error {
-	// Update all projects to their latest version.
+func runUpdate(jirix *jiri.X, args []string) error {
+	selective := len(args) > 0 || updateLocalFlag
+	if selective && gcFlag {
+		return jirix.UsageErrorf("-gc can't be used with -local or a list of projects")
+	}
+	// Update the projects to their latest version.
 	// Attempt <attemptsFlag> times before failing.
-	updateFn := func() error { return project.UpdateUniverse(jirix, gcFlag) }
+	updateFn := func() error {
+		if selective {
+			return project.UpdateProjects(jirix, args, updateLocalFlag)
+		}
+		return project.UpdateUniverse(jirix, gcFlag)
+	}
 	if err := retry.Function(jirix.Context, updateFn, retry.AttemptsOpt(attemptsFlag)); err != nil {
+		if jirix.Ctx().Err() != nil {
+			fmt.Fprintln(jirix.Stderr(), `The update was interrupted; run "jiri update" again to finish it.`)
+		}
+		return err
+	}
+	if err := project.WriteUpdateHistorySnapshot(jirix, ""); err != nil {
 		return err
 	}
-	return project.WriteUpdateHistorySnapshot(jirix, "")
+	if rebaseTrackedFlag || rebaseAllFlag {
+		return rebaseBranches(jirix, args)
+	}
+	return nil
+}
+
+// rebaseBranches rebases the local branches of the updated projects onto
+// master, and prints a report for each project.
+func rebaseBranches(jirix *jiri.X, args []string) error {
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		if projects, err = project.MatchProjects(projects, args); err != nil {
+			return err
+		}
+	}
+	results, err := project.RebaseBranches(jirix, projects, rebaseAllFlag)
+	if err != nil {
+		return err
+	}
+	lastProject := ""
+	for _, r := range results {
+		if r.Project != lastProject {
+			fmt.Fprintf(jirix.Stdout(), "project %q:\n", r.Project)
+			lastProject = r.Project
+		}
+		if r.Reason != "" {
+			fmt.Fprintf(jirix.Stdout(), "  %v: %v (%v)\n", r.Branch, r.Status, r.Reason)
+		} else {
+			fmt.Fprintf(jirix.Stdout(), "  %v: %v\n", r.Branch, r.Status)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,108 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/metadata"
+	"fuchsia.googlesource.com/jiri/project"
+	"fuchsia.googlesource.com/jiri/runutil"
+)
+
+var versionJSONFlag bool
+
+func init() {
+	cmdVersion.Flags.BoolVar(&versionJSONFlag, "json", false, "Print the versions in JSON format.")
+}
+
+// cmdVersion represents the "jiri version" command.
+var cmdVersion = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runVersion),
+	Name:   "version",
+	Short:  "Print version information for jiri and its tools",
+	Long: `
+Prints the metadata built into the running jiri binary, followed by the metadata
+of every tool installed in $JIRI_ROOT/.jiri_root/bin.
+
+Tools built by jiri record the project and revision they were built from, the
+manifest or snapshot file that specified them, the build time and, for Go
+tools, the Go version.  Tools that weren't built by jiri have no metadata.
+`,
+}
+
+// toolVersion describes the version of a single binary.
+type toolVersion struct {
+	Name     string            `json:"name"`
+	Path     string            `json:"path"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+func runVersion(jirix *jiri.X, args []string) error {
+	if len(args) != 0 {
+		return jirix.UsageErrorf("unexpected arguments")
+	}
+	path, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return err
+	}
+	self, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	versions := []toolVersion{{Name: "jiri", Path: self, Metadata: metadata.ToMap()}}
+
+	infos, err := ioutil.ReadDir(jirix.BinDir())
+	if err != nil && !runutil.IsNotExist(err) {
+		return err
+	}
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		v := toolVersion{Name: info.Name(), Path: filepath.Join(jirix.BinDir(), info.Name())}
+		md, err := project.InstalledToolMetadata(jirix, info.Name())
+		if err != nil {
+			return err
+		}
+		if md != nil {
+			v.Metadata = md.ToMap()
+		}
+		versions = append(versions, v)
+	}
+
+	if versionJSONFlag {
+		data, err := json.MarshalIndent(versions, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(jirix.Stdout(), "%s\n", data)
+		return nil
+	}
+	for _, v := range versions {
+		fmt.Fprintf(jirix.Stdout(), "%s (%s):\n", v.Name, v.Path)
+		if len(v.Metadata) == 0 {
+			fmt.Fprintln(jirix.Stdout(), "  no metadata")
+			continue
+		}
+		var keys []string
+		for key := range v.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(jirix.Stdout(), "  %s: %s\n", key, v.Metadata[key])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri/jiritest"
+	"fuchsia.googlesource.com/jiri/project"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+func TestVersion(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	if err := fake.CreateRemoteProject("tools"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.AddProject(project.Project{
+		Name:   "tools",
+		Path:   filepath.Join(fake.X.Root, "tools"),
+		Remote: fake.Projects["tools"],
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.AddTool(project.Tool{
+		Name:    "hello",
+		Build:   "echo hello > hello",
+		Output:  "hello",
+		Project: "tools",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	// A binary that wasn't installed by jiri.
+	if err := ioutil.WriteFile(filepath.Join(fake.X.BinDir(), "other"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &stdout})
+	defer func() { versionJSONFlag = false }()
+
+	if err := runVersion(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := stdout.String()
+	for _, want := range []string{
+		fmt.Sprintf("hello (%s):\n", filepath.Join(fake.X.BinDir(), "hello")),
+		"  jiri.Project: tools\n",
+		"  jiri.SnapshotPath: .jiri_manifest\n",
+		fmt.Sprintf("other (%s):\n  no metadata\n", filepath.Join(fake.X.BinDir(), "other")),
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+	if !strings.HasPrefix(got, "jiri (") {
+		t.Errorf("output does not start with the running jiri:\n%s", got)
+	}
+
+	stdout.Reset()
+	versionJSONFlag = true
+	if err := runVersion(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	var versions []toolVersion
+	if err := json.Unmarshal(stdout.Bytes(), &versions); err != nil {
+		t.Fatalf("Unmarshal(%q) failed: %v", stdout.String(), err)
+	}
+	var names []string
+	for _, v := range versions {
+		names = append(names, v.Name)
+	}
+	if got, want := strings.Join(names, " "), "jiri hello other"; got != want {
+		t.Errorf("got versions for %q, want %q", got, want)
+	}
+	if got, want := versions[1].Metadata["jiri.Project"], "tools"; got != want {
+		t.Errorf("got jiri.Project %q, want %q", got, want)
+	}
+}
@@ -25,6 +25,22 @@
 // arguments "help ..."; this behavior is relied on when generating recursive
 // help to distinguish between external subcommands with and without children.
 //
+// Shell completion
+//
+// Completion of command names, flags and args is provided by a hidden
+// "__complete" command of the root command, which prints the completions of its
+// last arg, given the preceding args.  CompletionScript writes scripts that set
+// up bash, fish and zsh to call it.  Candidate values for flags and args can be
+// provided via the CompleteFlags and CompleteArgs fields of Command.
+//
+// Aliases
+//
+// Commands may have aliases, alternative names that are shown in help, e.g.
+// "st" for "status".  The ExpandAlias function of the root command may define
+// more aliases, e.g. from config files, that expand to a command followed by
+// flags and args.  Unknown command names are reported along with the known
+// names they are close to.
+//
 // Pitfalls
 //
 // The cmdline package must be in full control of flag parsing.  Typically you
@@ -67,6 +83,9 @@ type Command struct {
 	ArgsName string // Name of the args, shown in usage line.
 	ArgsLong string // Long description of the args, shown in help.
 
+	// Aliases are alternative names of the command, e.g. "st" for "status".
+	Aliases []string
+
 	// Flags defined for this command.  When a flag F is defined on a command C,
 	// we allow F to be specified on the command line immediately after C, or
 	// after any descendant of C. This FlagSet is only used to specify the
@@ -112,6 +131,53 @@ type Command struct {
 
 	// Topics that provide additional info via the default help command.
 	Topics []Topic
+
+	// CompleteFlags maps flag names to functions that return candidate values
+	// for the flags, for shell completion.  They apply to the flags of this
+	// command and its descendants.
+	CompleteFlags map[string]CompleteFunc
+	// CompleteArgs returns candidate values for the args of the command, for
+	// shell completion.
+	CompleteArgs CompleteFunc
+
+	// FlagDefaults, if set on the root command, returns default values for
+	// the flags of the command with the given path of names, starting with the
+	// root, keyed by flag name.  It is called once the command to run is known,
+	// and overrides the defaults the flags of the command and its ancestors
+	// were defined with.  Flags set on the command line or by environment
+	// variables take precedence.
+	FlagDefaults func(path []string) (map[string]string, error)
+
+	// EnvFlags, if set on the root command, lets each flag that isn't set on
+	// the command line be set by an environment variable.  The name of the
+	// variable is the path of the command that defines the flag, followed by
+	// the flag name, in upper case with other characters than letters and
+	// digits replaced by underscores, e.g. JIRI_UPDATE_GC for the -gc flag of
+	// "jiri update".  Help shows the variables.  External children that use
+	// this package get the same behavior, via the CMDLINE_ENV_FLAGS variable.
+	// Global flags aren't bound to variables.
+	EnvFlags bool
+
+	// NoEnvFlags lists the flags of the command that EnvFlags doesn't bind to
+	// environment variables, e.g. because the program already reads the
+	// variable the flag would be bound to, with a meaning of its own.
+	NoEnvFlags []string
+
+	// ExpandAlias, if set on the root command, returns the args that the given
+	// name expands to when it is used as a subcommand of the command with the
+	// given path of names, starting with the root, or nil if the name isn't an
+	// alias.  The first arg of the expansion must name a subcommand of the
+	// command.  It is only called for names that don't match a compiled-in or
+	// external child.
+	ExpandAlias func(path []string, name string) ([]string, error)
+
+	// ExternalShort, if set on the root command, returns the short description
+	// of the external child with the given binary, for help.  If it returns
+	// false, the description is obtained by running the binary with -help.
+	ExternalShort func(binary string) (string, bool)
+
+	// flagSources holds the sources of the values of ParsedFlags.
+	flagSources map[string]FlagSource
 }
 
 // Runner is the interface for running commands.  Return ErrExitCode to indicate
@@ -149,26 +215,58 @@ type Topic struct {
 //     cmdline.Main(root)
 //   }
 func Main(root *Command) {
+	os.Exit(Run(root))
+}
+
+// Run is like Main, but returns the exit code instead of calling os.Exit, for
+// main functions that need to clean up before exiting.
+func Run(root *Command) int {
 	env := EnvFromOS()
 	if env.Timer != nil && len(env.Timer.Intervals) > 0 {
 		env.Timer.Intervals[0].Name = pathName(env.prefix(), []*Command{root})
 	}
 	err := ParseAndRun(root, env, os.Args[1:])
 	code := ExitCode(err, env.Stderr)
-	if *flagTime && env.Timer != nil {
+	if (*flagTime || *flagTimeTrace != "") && env.Timer != nil {
 		env.Timer.Finish()
-		p := timing.IntervalPrinter{Zero: env.Timer.Zero}
-		if err := p.Print(env.Stderr, env.Timer.Intervals, env.Timer.Now()); err != nil {
+		if err := writeTiming(env); err != nil {
 			code2 := ExitCode(err, env.Stderr)
 			if code == 0 {
 				code = code2
 			}
 		}
 	}
-	os.Exit(code)
+	return code
 }
 
-var flagTime = flag.Bool("time", false, "Dump timing information to stderr before exiting the program.")
+var (
+	flagTime      = flag.Bool("time", false, "Dump timing information to stderr before exiting the program.")
+	flagTimeTrace = flag.String("time-trace", "", "Write timing information to the given file before exiting the program, in the Chrome Trace Event format; load it in chrome://tracing or Perfetto.")
+)
+
+// writeTiming writes the timing information of env as requested by the -time
+// and -time-trace flags.
+func writeTiming(env *Env) error {
+	if *flagTime {
+		p := timing.IntervalPrinter{Zero: env.Timer.Zero}
+		if err := p.Print(env.Stderr, env.Timer.Intervals, env.Timer.Now()); err != nil {
+			return err
+		}
+	}
+	if *flagTimeTrace != "" {
+		f, err := os.Create(*flagTimeTrace)
+		if err != nil {
+			return err
+		}
+		p := timing.ChromeTracePrinter{Zero: env.Timer.Zero}
+		if err := p.Print(f, env.Timer.Intervals, env.Timer.Now()); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return nil
+}
 
 // Parse parses args against the command tree rooted at root down to a leaf
 // command.  A single path through the command tree is traversed, based on the
@@ -204,13 +302,7 @@ var flagTime = flag.Bool("time", false, "Dump timing information to stderr befor
 func Parse(root *Command, env *Env, args []string) (Runner, []string, error) {
 	env.TimerPush("cmdline parse")
 	defer env.TimerPop()
-	if globalFlags == nil {
-		// Initialize our global flags to a cleaned copy.  We don't want the merging
-		// in parseFlags to contaminate the global flags, even if Parse is called
-		// multiple times, so we keep a single package-level copy.
-		cleanFlags(flag.CommandLine)
-		globalFlags = copyFlags(flag.CommandLine)
-	}
+	initGlobalFlags()
 	// Set env.Usage to the usage of the root command, in case the parse fails.
 	path := []*Command{root}
 	env.Usage = makeHelpRunner(path, env).usageFunc
@@ -218,6 +310,13 @@ func Parse(root *Command, env *Env, args []string) (Runner, []string, error) {
 	if err := checkTreeInvariants(path, env); err != nil {
 		return nil, nil, err
 	}
+	if len(args) > 0 && args[0] == completeName {
+		return completeRunner{root}, args[1:], nil
+	}
+	if root.EnvFlags && env.Vars != nil {
+		// Pass EnvFlags on to external children.
+		env.Vars[envFlagsVar] = "true"
+	}
 	runner, args, err := root.parse(nil, env, args, make(map[string]string))
 	if err != nil {
 		return nil, nil, err
@@ -256,6 +355,17 @@ func ParseAndRun(root *Command, env *Env, args []string) error {
 	return runner.Run(env, args)
 }
 
+// initGlobalFlags initializes our global flags to a cleaned copy of
+// flag.CommandLine.  We don't want the merging in parseFlags to contaminate the
+// global flags, even if Parse is called multiple times, so we keep a single
+// package-level copy.
+func initGlobalFlags() {
+	if globalFlags == nil {
+		cleanFlags(flag.CommandLine)
+		globalFlags = copyFlags(flag.CommandLine)
+	}
+}
+
 func trimSpace(s *string) { *s = strings.TrimSpace(*s) }
 
 func cleanTree(cmd *Command) {
@@ -310,6 +420,11 @@ Saw %q multiple times.`, cmdPath, name)
 		if err := checkName(child.Name); err != nil {
 			return err
 		}
+		for _, alias := range child.Aliases {
+			if err := checkName(alias); err != nil {
+				return err
+			}
+		}
 	}
 	for _, topic := range cmd.Topics {
 		if err := checkName(topic.Name); err != nil {
@@ -370,6 +485,9 @@ func (cmd *Command) parse(path []*Command, env *Env, args []string, setFlags map
 	// First handle the no-args case.
 	if len(args) == 0 {
 		if cmd.Runner != nil {
+			if err := setFlagDefaults(path, env, setFlags); err != nil {
+				return nil, nil, env.UsageErrorf("%s: %v", cmdPath, err)
+			}
 			return cmd.Runner, nil, nil
 		}
 		return nil, nil, env.UsageErrorf("%s: no command specified", cmdPath)
@@ -377,31 +495,30 @@ func (cmd *Command) parse(path []*Command, env *Env, args []string, setFlags map
 	// INVARIANT: len(args) > 0
 	// Look for matching children.
 	subName, subArgs := args[0], args[1:]
-	if len(cmd.Children) > 0 {
-		for _, child := range cmd.Children {
-			if child.Name == subName {
-				return child.parse(path, env, subArgs, setFlags)
-			}
-		}
-		// Every non-leaf command gets a default help command.
-		if helpName == subName {
-			return runHelp.newCommand().parse(path, env, subArgs, setFlags)
+	if runner, args, ok, err := cmd.parseChild(path, env, subName, subArgs, setFlags); ok {
+		return runner, args, err
+	}
+	// Look for a matching alias.
+	if len(cmd.Children) > 0 || cmd.LookPath {
+		expanded, err := expandAlias(path, subName)
+		if err != nil {
+			return nil, nil, env.UsageErrorf("%s: %v", cmdPath, err)
 		}
-	}
-	if cmd.LookPath {
-		// Look for a matching executable in PATH.
-		if subCmd, _ := env.LookPath(cmd.Name + "-" + subName); subCmd != "" {
-			extArgs := append(flagsAsArgs(setFlags), subArgs...)
-			return binaryRunner{subCmd, cmdPath}, extArgs, nil
+		if len(expanded) > 0 {
+			aliasArgs := append(append([]string(nil), expanded[1:]...), subArgs...)
+			if runner, args, ok, err := cmd.parseChild(path, env, expanded[0], aliasArgs, setFlags); ok {
+				return runner, args, err
+			}
+			return nil, nil, env.UsageErrorf("%s: alias %q expands to unknown command %q", cmdPath, subName, expanded[0])
 		}
 	}
 	// No matching subcommands, check various error cases.
 	switch {
 	case cmd.Runner == nil:
-		return nil, nil, env.UsageErrorf("%s: unknown command %q", cmdPath, subName)
+		return nil, nil, env.UsageErrorf("%s: unknown command %q%s", cmdPath, subName, didYouMean(subName, subcommandNames(env, cmd, false)))
 	case cmd.ArgsName == "":
 		if len(cmd.Children) > 0 {
-			return nil, nil, env.UsageErrorf("%s: unknown command %q", cmdPath, subName)
+			return nil, nil, env.UsageErrorf("%s: unknown command %q%s", cmdPath, subName, didYouMean(subName, subcommandNames(env, cmd, false)))
 		}
 		return nil, nil, env.UsageErrorf("%s: doesn't take arguments", cmdPath)
 	case reflect.DeepEqual(args, []string{helpName, "..."}):
@@ -410,9 +527,72 @@ func (cmd *Command) parse(path []*Command, env *Env, args []string, setFlags map
 	// INVARIANT:
 	// cmd.Runner != nil && len(args) > 0 &&
 	// cmd.ArgsName != "" && args != []string{"help", "..."}
+	if err := setFlagDefaults(path, env, setFlags); err != nil {
+		return nil, nil, env.UsageErrorf("%s: %v", cmdPath, err)
+	}
 	return cmd.Runner, args, nil
 }
 
+// parseChild parses the args of the compiled-in or external child of the last
+// command in path with the given name.  Returns false if there is no such
+// child.
+func (cmd *Command) parseChild(path []*Command, env *Env, subName string, subArgs []string, setFlags map[string]string) (Runner, []string, bool, error) {
+	if len(cmd.Children) > 0 {
+		if child := findChild(cmd, subName); child != nil {
+			runner, args, err := child.parse(path, env, subArgs, setFlags)
+			return runner, args, true, err
+		}
+		// Every non-leaf command gets a default help command.
+		if helpName == subName {
+			runner, args, err := makeHelpRunner(path, env).newCommand().parse(path, env, subArgs, setFlags)
+			return runner, args, true, err
+		}
+	}
+	if cmd.LookPath {
+		// Look for a matching executable in PATH.
+		if subCmd, _ := env.LookPath(cmd.Name + "-" + subName); subCmd != "" {
+			extArgs := append(flagsAsArgs(setFlags), subArgs...)
+			return binaryRunner{subCmd, pathName(env.prefix(), path)}, extArgs, true, nil
+		}
+	}
+	return nil, nil, false, nil
+}
+
+// findChild returns the compiled-in child of cmd with the given name or alias,
+// or nil if there is no such child.
+func findChild(cmd *Command, name string) *Command {
+	for _, child := range cmd.Children {
+		if child.Name == name {
+			return child
+		}
+		for _, alias := range child.Aliases {
+			if alias == name {
+				return child
+			}
+		}
+	}
+	return nil
+}
+
+// expandAlias returns the expansion of the given name by the ExpandAlias
+// function of the root command, if any, when it is used as a subcommand of the
+// last command in path.
+func expandAlias(path []*Command, name string) ([]string, error) {
+	if path[0].ExpandAlias == nil {
+		return nil, nil
+	}
+	return path[0].ExpandAlias(pathNames(path), name)
+}
+
+// pathNames returns the names of the commands in path.
+func pathNames(path []*Command) []string {
+	var names []string
+	for _, cmd := range path {
+		names = append(names, cmd.Name)
+	}
+	return names
+}
+
 // parseFlags parses the flags from args for the command with the given path and
 // env.  Returns the remaining non-flag args and the flags that were set.
 func parseFlags(path []*Command, env *Env, args []string) ([]string, map[string]string, error) {
@@ -457,6 +637,67 @@ func parseFlags(path []*Command, env *Env, args []string) ([]string, map[string]
 	return flags.Args(), extractSetFlags(flags), nil
 }
 
+// setFlagDefaults sets the parsed flags of the commands in path that aren't in
+// setFlags to the values of their environment variables, if EnvFlags is
+// enabled, or otherwise to the defaults returned by the FlagDefaults function
+// of the root command, if any.  The flags are not marked as set.  It also
+// records the source of the value of each flag.
+func setFlagDefaults(path []*Command, env *Env, setFlags map[string]string) error {
+	sources := map[string]FlagSource{}
+	for name := range setFlags {
+		sources[name] = FlagSourceCommandLine
+	}
+	defer func() {
+		for _, cmd := range path {
+			cmd.flagSources = sources
+		}
+	}()
+	if path[0].FlagDefaults != nil {
+		defaults, err := path[0].FlagDefaults(pathNames(path))
+		if err != nil {
+			return err
+		}
+		for name, value := range defaults {
+			if _, ok := setFlags[name]; ok {
+				continue
+			}
+			if ok, err := setParsedFlag(path, name, value); err != nil {
+				return fmt.Errorf("invalid default value %q for flag -%s: %v", value, name, err)
+			} else if ok {
+				sources[name] = FlagSourceConfig
+			}
+		}
+	}
+	for name, envName := range envFlagNames(env, env.prefix(), path) {
+		value, ok := env.Vars[envName]
+		if _, set := setFlags[name]; set || !ok || value == "" {
+			continue
+		}
+		if ok, err := setParsedFlag(path, name, value); err != nil {
+			return fmt.Errorf("invalid value %q of $%s for flag -%s: %v", value, envName, name, err)
+		} else if ok {
+			sources[name] = FlagSourceEnv
+		}
+	}
+	return nil
+}
+
+// setParsedFlag sets the parsed flag of the commands in path with the given
+// name to value, and returns whether there is such a flag.
+func setParsedFlag(path []*Command, name, value string) (bool, error) {
+	// Flags are shared between the flag sets of the commands, so setting the
+	// first match is enough.
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].ParsedFlags == nil {
+			continue
+		}
+		if f := path[i].ParsedFlags.Lookup(name); f != nil {
+			return true, f.Value.Set(value)
+		}
+	}
+	return false, nil
+}
+
 func mergeFlags(dst, src *flag.FlagSet) {
 	src.VisitAll(func(f *flag.Flag) {
 		// If there is a collision in flag names, the existing flag in dst wins.
@@ -462,12 +462,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The cmdrun help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -641,12 +646,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The onecmd help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -701,12 +711,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The onecmd help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -915,12 +930,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The multi help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -1316,12 +1336,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The toplevelprog help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -1446,12 +1471,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The toplevelprog echoprog help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -1883,12 +1913,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The prog1 help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -1977,12 +2012,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The prog1 prog2 help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -2041,12 +2081,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The prog1 prog2 prog3 help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -2105,12 +2150,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The prog1 prog2 prog3 help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -2228,12 +2278,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The prog1 help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=<terminal width>
    Format output to this target width in runes, or unlimited if width < 0.
@@ -2665,12 +2720,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The unlikely help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=80
    Format output to this target width in runes, or unlimited if width < 0.
@@ -2799,12 +2859,17 @@ Usage:
 [command/topic ...] optionally identifies a specific sub-command or help topic.
 
 The unlikely help flags are:
+ -dir=
+   With -style=markdown or -style=man, write each page to its own file in this
+   directory, rather than to stdout.
  -style=compact
    The formatting style for help output:
       compact   - Good for compact cmdline output.
       full      - Good for cmdline output, shows all global flags.
       godoc     - Good for godoc processing.
       shortonly - Only output short description.
+      markdown  - Markdown pages, e.g. for a documentation site.
+      man       - Roff man pages.
    Override the default by setting the CMDLINE_STYLE environment variable.
  -width=<terminal width>
    Format output to this target width in runes, or unlimited if width < 0.
@@ -2886,6 +2951,9 @@ The global flags are:
    Displays metadata for the program and exits.
  -time=false
    Dump timing information to stderr before exiting the program.
+ -time-trace=
+   Write timing information to the given file before exiting the program, in the
+   Chrome Trace Event format; load it in chrome://tracing or Perfetto.
 `,
 		},
 		{
@@ -2906,6 +2974,9 @@ The global flags are:
    Displays metadata for the program and exits.
  -time=false
    Dump timing information to stderr before exiting the program.
+ -time-trace=
+   Write timing information to the given file before exiting the program, in the
+   Chrome Trace Event format; load it in chrome://tracing or Perfetto.
 `,
 		},
 		{
@@ -3033,6 +3104,184 @@ func TestParsedFlags(t *testing.T) {
 	}
 }
 
+func TestFlagDefaults(t *testing.T) {
+	var v, b bool
+	var n string
+	var paths [][]string
+	child := &Command{
+		Name:   "child",
+		Short:  "short",
+		Long:   "long.",
+		Runner: RunnerFunc(runHello),
+	}
+	child.Flags.StringVar(&n, "n", "flag", "string")
+	child.Flags.BoolVar(&b, "b", false, "bool")
+	root := &Command{
+		Name:     "root",
+		Short:    "short",
+		Long:     "long.",
+		Children: []*Command{child},
+		FlagDefaults: func(path []string) (map[string]string, error) {
+			paths = append(paths, path)
+			return map[string]string{"v": "true", "n": "default"}, nil
+		},
+	}
+	root.Flags.BoolVar(&v, "v", false, "bool")
+	env := EnvFromOS()
+
+	tests := []struct {
+		args  []string
+		wantV bool
+		wantN string
+		set   []string
+	}{
+		{[]string{"child"}, true, "default", nil},
+		{[]string{"child", "-v=false"}, false, "default", []string{"v"}},
+		{[]string{"child", "-n", "arg"}, true, "arg", []string{"n"}},
+		// Flags set for the root remain set in flag.CommandLine, so this case
+		// must come last.
+		{[]string{"-v=false", "child"}, false, "default", nil},
+	}
+	for _, test := range tests {
+		v, n, paths = false, "flag", nil
+		if _, _, err := Parse(root, env, test.args); err != nil {
+			t.Fatal(err)
+		}
+		// The defaults are looked up once, for the command that runs.
+		if got, want := paths, [][]string{{"root", "child"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got paths %v, want %v", test.args, got, want)
+		}
+		if got, want := v, test.wantV; got != want {
+			t.Errorf("%v: got -v=%v, want %v", test.args, got, want)
+		}
+		if got, want := n, test.wantN; got != want {
+			t.Errorf("%v: got -n=%v, want %v", test.args, got, want)
+		}
+		// Defaults are not reported as set.
+		var set []string
+		child.ParsedFlags.Visit(func(f *flag.Flag) { set = append(set, f.Name) })
+		if got, want := set, test.set; !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got set flags %v, want %v", test.args, got, want)
+		}
+	}
+
+	root.FlagDefaults = func([]string) (map[string]string, error) {
+		return map[string]string{"b": "nope"}, nil
+	}
+	var stderr bytes.Buffer
+	env = &Env{Stdout: ioutil.Discard, Stderr: &stderr}
+	if _, _, err := Parse(root, env, []string{"child"}); err == nil {
+		t.Errorf("expected an error for an invalid default")
+	} else if got, want := stderr.String(), `invalid default value "nope" for flag -b`; !strings.Contains(got, want) {
+		t.Errorf("got stderr %q, want it to contain %q", got, want)
+	}
+}
+
+func TestEnvFlags(t *testing.T) {
+	var n, m string
+	var b bool
+	child := &Command{
+		Name:   "child-cmd",
+		Short:  "short",
+		Long:   "long.",
+		Runner: RunnerFunc(runHello),
+	}
+	child.Flags.StringVar(&n, "n", "flag", "string")
+	child.Flags.StringVar(&m, "dry-run", "flag", "string")
+	child.Flags.BoolVar(&b, "b", false, "bool")
+	root := &Command{
+		Name:     "tool",
+		Short:    "short",
+		Long:     "long.",
+		Children: []*Command{child},
+		EnvFlags: true,
+		FlagDefaults: func([]string) (map[string]string, error) {
+			return map[string]string{"n": "config", "dry-run": "config"}, nil
+		},
+	}
+
+	tests := []struct {
+		args         []string
+		vars         map[string]string
+		wantN, wantM string
+		sourceN      FlagSource
+	}{
+		{[]string{"child-cmd"}, nil, "config", "config", FlagSourceConfig},
+		{[]string{"child-cmd"}, map[string]string{"TOOL_CHILD_CMD_N": "env", "TOOL_CHILD_CMD_DRY_RUN": "env"}, "env", "env", FlagSourceEnv},
+		{[]string{"child-cmd", "-n=arg"}, map[string]string{"TOOL_CHILD_CMD_N": "env"}, "arg", "config", FlagSourceCommandLine},
+		// Empty variables are ignored.
+		{[]string{"child-cmd"}, map[string]string{"TOOL_CHILD_CMD_N": ""}, "config", "config", FlagSourceConfig},
+	}
+	for _, test := range tests {
+		n, m = "flag", "flag"
+		env := &Env{Stdout: ioutil.Discard, Stderr: ioutil.Discard, Vars: map[string]string{}}
+		for k, v := range test.vars {
+			env.Vars[k] = v
+		}
+		if _, _, err := Parse(root, env, test.args); err != nil {
+			t.Fatal(err)
+		}
+		if n != test.wantN || m != test.wantM {
+			t.Errorf("%v %v: got -n=%v -dry-run=%v, want %v and %v", test.args, test.vars, n, m, test.wantN, test.wantM)
+		}
+		if got, want := child.FlagSource("n"), test.sourceN; got != want {
+			t.Errorf("%v %v: got source %v, want %v", test.args, test.vars, got, want)
+		}
+	}
+
+	// Help shows the variables.
+	var stdout bytes.Buffer
+	env := &Env{Stdout: &stdout, Stderr: &stdout, Vars: map[string]string{}}
+	if err := ParseAndRun(root, env, []string{"help", "child-cmd"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := " -dry-run=config ($TOOL_CHILD_CMD_DRY_RUN)\n"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("help does not contain %q:\n%s", want, stdout.String())
+	}
+
+	// External children get the variables prefixed with the path of their
+	// parent.
+	root.EnvFlags = false
+	env = &Env{Stdout: ioutil.Discard, Stderr: ioutil.Discard, Vars: map[string]string{
+		"CMDLINE_PREFIX":          "parent",
+		envFlagsVar:               "true",
+		"PARENT_TOOL_CHILD_CMD_N": "env",
+	}}
+	if _, _, err := Parse(root, env, []string{"child-cmd"}); err != nil {
+		t.Fatal(err)
+	}
+	if n != "env" {
+		t.Errorf("got -n=%v for an external child, want env", n)
+	}
+
+	var stderr bytes.Buffer
+	env = &Env{Stdout: ioutil.Discard, Stderr: &stderr, Vars: map[string]string{envFlagsVar: "true", "TOOL_CHILD_CMD_B": "x"}}
+	if _, _, err := Parse(root, env, []string{"child-cmd"}); err == nil {
+		t.Errorf("expected an error for an invalid variable")
+	} else if want := `invalid value "x" of $TOOL_CHILD_CMD_B for flag -b`; !strings.Contains(stderr.String(), want) {
+		t.Errorf("got stderr %q, want it to contain %q", stderr.String(), want)
+	}
+
+	// NoEnvFlags aren't bound to variables, nor shown by help.
+	child.NoEnvFlags = []string{"b"}
+	defer func() { child.NoEnvFlags = nil }()
+	stderr.Reset()
+	if _, _, err := Parse(root, env, []string{"child-cmd"}); err != nil {
+		t.Errorf("got error %v for a variable of a NoEnvFlags flag: %s", err, stderr.String())
+	}
+	if got := child.FlagSource("b"); got != FlagSourceDefault {
+		t.Errorf("got source %v for a NoEnvFlags flag, want %v", got, FlagSourceDefault)
+	}
+	stdout.Reset()
+	env = &Env{Stdout: &stdout, Stderr: &stdout, Vars: map[string]string{envFlagsVar: "true"}}
+	if err := ParseAndRun(root, env, []string{"help", "child-cmd"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); strings.Contains(got, "$TOOL_CHILD_CMD_B") || !strings.Contains(got, "$TOOL_CHILD_CMD_N") {
+		t.Errorf("help shows the wrong variables:\n%s", got)
+	}
+}
+
 type fc struct {
 	DontPropagateFlags bool
 	DontInheritFlags   bool
@@ -3134,3 +3383,43 @@ func createCommandTree(flagConfigs []fc) []*Command {
 
 	return result
 }
+
+func TestExternalShort(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cmdline-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	for _, name := range []string{"hooked", "unhooked"} {
+		script := "#!/bin/sh\necho Short description of " + name + " from -help\n"
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, "root-"+name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := &Command{
+		Name:     "root",
+		Short:    "short",
+		Long:     "long.",
+		LookPath: true,
+		Runner:   RunnerFunc(runHello),
+		ExternalShort: func(binary string) (string, bool) {
+			if filepath.Base(binary) == "root-hooked" {
+				return "Short description of hooked from the hook\n", true
+			}
+			return "", false
+		},
+	}
+	var stdout bytes.Buffer
+	env := &Env{Stdout: &stdout, Stderr: &stdout, Vars: map[string]string{"PATH": tmpDir}}
+	if err := ParseAndRun(cmd, env, []string{"-help"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"   hooked      Short description of hooked from the hook\n",
+		"   unhooked    Short description of unhooked from -help\n",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("help does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+}
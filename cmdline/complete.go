@@ -0,0 +1,269 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// completeName is the name of the hidden command that prints shell
+// completions.  It is only recognized as the first arg of the root command.
+const completeName = "__complete"
+
+// CompleteFunc returns candidate values for a flag or an arg of a command, for
+// shell completion.  The args are the args of the command that precede the
+// word being completed.  The candidates need not match the word; they are
+// filtered by the caller.
+type CompleteFunc func(env *Env, args []string) []string
+
+// completeRunner is a Runner that prints the completions of the last of its
+// args, given the preceding args, one per line.  The args are the words of the
+// command line that follow the name of the root command.
+type completeRunner struct {
+	root *Command
+}
+
+// Run implements the Runner interface method.
+func (c completeRunner) Run(env *Env, args []string) error {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	for _, candidate := range complete(env, c.root, args[:len(args)-1], args[len(args)-1]) {
+		fmt.Fprintln(env.Stdout, candidate)
+	}
+	return nil
+}
+
+// complete returns the sorted completions of cur, where words are the
+// preceding words of the command line, after the name of root.
+func complete(env *Env, root *Command, words []string, cur string) []string {
+	path := []*Command{root}
+	var args []string
+	var helpTopics bool // True if completing the args of a help command.
+	var valueFlag string
+	for i := 0; i < len(words); i++ {
+		word, cmd := words[i], path[len(path)-1]
+		switch {
+		case valueFlag != "":
+			valueFlag = ""
+		case word == "--":
+			// There are no completions after "--".
+			return nil
+		case strings.HasPrefix(word, "-") && word != "-":
+			name := strings.TrimLeft(word, "-")
+			if strings.Contains(name, "=") {
+				continue
+			}
+			if f := completionFlags(path).Lookup(name); f != nil && !isBoolFlag(f) {
+				valueFlag = name
+			}
+		case helpTopics || len(args) > 0:
+			args = append(args, word)
+		case word == helpName && needsHelpChild(cmd):
+			helpTopics = true
+		default:
+			if child := findChild(cmd, word); child != nil {
+				path = append(path, child)
+				continue
+			}
+			if cmd.LookPath {
+				if subCmd, _ := env.LookPath(cmd.Name + "-" + word); subCmd != "" {
+					// External children do their own completion, if any.
+					return nil
+				}
+			}
+			if len(cmd.Children) > 0 || cmd.LookPath {
+				// Replace an alias by its expansion, and complete that instead.
+				// Like in Parse, the expansion must start with a subcommand.
+				if expanded, _ := expandAlias(path, word); len(expanded) > 0 && findChild(cmd, expanded[0]) != nil {
+					words = append(append(append([]string(nil), words[:i]...), expanded...), words[i+1:]...)
+					i--
+					continue
+				}
+			}
+			args = append(args, word)
+		}
+	}
+	cmd := path[len(path)-1]
+	var candidates []string
+	switch {
+	case valueFlag != "":
+		candidates = completeFlagValue(env, path, args, valueFlag)
+	case strings.HasPrefix(cur, "-") && strings.Contains(cur, "="):
+		i := strings.Index(cur, "=")
+		for _, value := range completeFlagValue(env, path, args, strings.TrimLeft(cur[:i], "-")) {
+			candidates = append(candidates, cur[:i+1]+value)
+		}
+	case strings.HasPrefix(cur, "-"):
+		completionFlags(path).VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "-"+f.Name)
+		})
+	case helpTopics:
+		if len(args) == 0 {
+			for _, child := range cmd.Children {
+				candidates = append(candidates, child.Name)
+			}
+			for _, topic := range cmd.Topics {
+				candidates = append(candidates, topic.Name)
+			}
+		}
+	default:
+		if len(args) == 0 {
+			candidates = append(candidates, childNames(env, cmd)...)
+			// Aliases are only completed once a prefix is typed, so that the
+			// listing of all children isn't cluttered.
+			if cur != "" {
+				for _, child := range cmd.Children {
+					candidates = append(candidates, child.Aliases...)
+				}
+			}
+		}
+		if cmd.Runner != nil && cmd.CompleteArgs != nil {
+			candidates = append(candidates, cmd.CompleteArgs(env, args)...)
+		}
+	}
+	return filterCompletions(candidates, cur)
+}
+
+// completionFlags returns the flags that may be given after the last command
+// in path.
+func completionFlags(path []*Command) *flag.FlagSet {
+	flags := pathFlags(path)
+	if globalFlags != nil {
+		mergeFlags(flags, globalFlags)
+	} else {
+		mergeFlags(flags, flag.CommandLine)
+	}
+	return flags
+}
+
+// completeFlagValue returns the candidate values of the flag with the given
+// name, from the CompleteFlags of the closest command in path that has them.
+func completeFlagValue(env *Env, path []*Command, args []string, name string) []string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if fn := path[i].CompleteFlags[name]; fn != nil {
+			return fn(env, args)
+		}
+	}
+	if f := completionFlags(path).Lookup(name); f != nil && isBoolFlag(f) {
+		return []string{"false", "true"}
+	}
+	return nil
+}
+
+// childNames returns the names of the children of cmd, including the default
+// help command and external children.
+func childNames(env *Env, cmd *Command) []string {
+	var names []string
+	for _, child := range cmd.Children {
+		names = append(names, child.Name)
+	}
+	if needsHelpChild(cmd) {
+		names = append(names, helpName)
+	}
+	if cmd.LookPath {
+		cmdPrefix := cmd.Name + "-"
+		subCmds, _ := env.LookPathPrefix(cmdPrefix, cmd.subNames(cmdPrefix))
+		for _, subCmd := range subCmds {
+			names = append(names, strings.TrimPrefix(filepath.Base(subCmd), cmdPrefix))
+		}
+	}
+	return names
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface {
+		IsBoolFlag() bool
+	})
+	return ok && b.IsBoolFlag()
+}
+
+// filterCompletions returns the sorted unique candidates that start with
+// prefix.
+func filterCompletions(candidates []string, prefix string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) && !seen[c] {
+			seen[c] = true
+			result = append(result, c)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// CompletionScript writes a script to w that sets up completion of the root
+// command for the given shell, one of "bash", "fish" or "zsh".  The script
+// calls the hidden "__complete" command of root, so completions are always
+// in sync with the command tree.
+func CompletionScript(w io.Writer, root *Command, shell string) error {
+	name := root.Name
+	fn := "_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name) + "_complete"
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion
+	case "fish":
+		script = fishCompletion
+	case "zsh":
+		script = zshCompletion
+	default:
+		return fmt.Errorf("unsupported shell %q: must be one of bash, fish or zsh", shell)
+	}
+	r := strings.NewReplacer("{{name}}", name, "{{fn}}", fn, "{{complete}}", completeName)
+	_, err := io.WriteString(w, r.Replace(script))
+	return err
+}
+
+const bashCompletion = `# bash completion for {{name}}.
+{{fn}}() {
+  local line="${COMP_LINE:0:COMP_POINT}"
+  local -a words
+  read -ra words <<< "${line}"
+  if [[ "${line}" =~ [[:space:]]$ ]]; then
+    words+=("")
+  fi
+  local cur="${words[${#words[@]}-1]}"
+  local IFS=$'\n'
+  COMPREPLY=($({{name}} {{complete}} "${words[@]:1}" 2>/dev/null))
+  # Bash treats "=" as a word break, so only the value of -flag=value is
+  # replaced.
+  if [[ "${cur}" == *=* && "${COMP_WORDBREAKS}" == *=* ]]; then
+    local prefix="${cur%"${cur##*=}"}"
+    COMPREPLY=("${COMPREPLY[@]#"${prefix}"}")
+  fi
+}
+complete -o default -F {{fn}} {{name}}
+`
+
+const fishCompletion = `# fish completion for {{name}}.
+function {{fn}}
+    set -l words (commandline -opc)
+    set -l cur (commandline -ct)
+    {{name}} {{complete}} $words[2..-1] "$cur" 2>/dev/null
+end
+complete -c {{name}} -f -a '({{fn}})'
+`
+
+const zshCompletion = `#compdef {{name}}
+# zsh completion for {{name}}.
+{{fn}}() {
+  local -a completions
+  completions=(${(f)"$({{name}} {{complete}} "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+  compadd -a completions
+}
+compdef {{fn}} {{name}}
+`
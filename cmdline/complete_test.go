@@ -0,0 +1,122 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newCompleteTree() *Command {
+	var flagS string
+	var flagB bool
+	leaf := &Command{
+		Runner:   RunnerFunc(runHello),
+		Name:     "leaf",
+		Short:    "short",
+		Long:     "long.",
+		ArgsName: "<arg>",
+		CompleteArgs: func(env *Env, args []string) []string {
+			if len(args) > 0 {
+				return []string{"second"}
+			}
+			return []string{"first", "fixed"}
+		},
+	}
+	leaf.Flags.StringVar(&flagS, "fruit", "", "string")
+	mid := &Command{
+		Name:          "mid",
+		Short:         "short",
+		Long:          "long.",
+		Children:      []*Command{leaf},
+		CompleteFlags: map[string]CompleteFunc{"fruit": func(*Env, []string) []string { return []string{"apple", "fig"} }},
+	}
+	mid.Flags.BoolVar(&flagB, "flat", false, "bool")
+	root := &Command{
+		Name:     "root",
+		Short:    "short",
+		Long:     "long.",
+		LookPath: true,
+		Children: []*Command{mid},
+		Topics:   []Topic{{Name: "topic", Short: "short", Long: "long."}},
+	}
+	return root
+}
+
+func TestComplete(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "root-ext"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	env := &Env{Vars: map[string]string{"PATH": tmpDir}}
+	root := newCompleteTree()
+
+	tests := []struct {
+		words []string
+		cur   string
+		want  []string
+	}{
+		{nil, "", []string{"ext", "help", "mid"}},
+		{nil, "m", []string{"mid"}},
+		{[]string{"mid"}, "", []string{"help", "leaf"}},
+		{[]string{"help"}, "", []string{"mid", "topic"}},
+		{[]string{"mid", "-fl"}, "", []string{"help", "leaf"}},
+		{[]string{"mid"}, "-f", []string{"-flat"}},
+		{[]string{"mid", "leaf"}, "-f", []string{"-flat", "-fruit"}},
+		{[]string{"mid", "leaf"}, "", []string{"first", "fixed"}},
+		{[]string{"mid", "leaf"}, "fi", []string{"first", "fixed"}},
+		{[]string{"mid", "leaf", "first"}, "", []string{"second"}},
+		{[]string{"mid", "leaf", "-fruit"}, "", []string{"apple", "fig"}},
+		{[]string{"mid", "leaf", "-fruit", "fig"}, "", []string{"first", "fixed"}},
+		{[]string{"mid", "leaf"}, "--fruit=a", []string{"--fruit=apple"}},
+		{[]string{"mid"}, "-flat=", []string{"-flat=false", "-flat=true"}},
+		{[]string{"mid", "leaf", "--"}, "", nil},
+		{[]string{"ext"}, "", nil},
+	}
+	for _, test := range tests {
+		if got := complete(env, root, test.words, test.cur); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v %q: got %v, want %v", test.words, test.cur, got, test.want)
+		}
+	}
+}
+
+func TestCompleteCommand(t *testing.T) {
+	var stdout bytes.Buffer
+	env := &Env{Stdout: &stdout, Stderr: &stdout, Vars: map[string]string{}}
+	if err := ParseAndRun(newCompleteTree(), env, []string{"__complete", "mid", "l"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "leaf\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCompletionScript(t *testing.T) {
+	root := &Command{Name: "my-tool", Runner: RunnerFunc(runHello)}
+	for _, shell := range []string{"bash", "fish", "zsh"} {
+		var buf bytes.Buffer
+		if err := CompletionScript(&buf, root, shell); err != nil {
+			t.Fatal(err)
+		}
+		script := buf.String()
+		for _, want := range []string{"_my_tool_complete", "my-tool __complete"} {
+			if !strings.Contains(script, want) {
+				t.Errorf("%v script does not contain %q:\n%s", shell, want, script)
+			}
+		}
+	}
+	if err := CompletionScript(ioutil.Discard, root, "tcsh"); err == nil {
+		t.Errorf("expected an error for an unsupported shell")
+	}
+}
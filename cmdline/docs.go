@@ -0,0 +1,495 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteDocs writes the documentation of the command tree rooted at root to
+// dir, in the given style, either "markdown" or "man".  Each command and topic
+// is written to its own file, named after its path, e.g. "jiri-update.md" or
+// "jiri-update.1".  Man pages of topics are in section 7.  External children
+// are documented if they support the same styles, and otherwise get a page
+// holding their -help output.
+func WriteDocs(root *Command, env *Env, style, dir string) error {
+	config := &helpConfig{width: -1, prefix: env.prefix(), firstCall: env.firstCall(), dir: dir}
+	if err := config.style.Set(style); err != nil {
+		return err
+	}
+	if !config.style.isDoc() {
+		return fmt.Errorf("unsupported doc style %q: must be markdown or man", style)
+	}
+	initGlobalFlags()
+	cleanTree(root)
+	path := []*Command{root}
+	if err := checkTreeInvariants(path, env); err != nil {
+		return err
+	}
+	return docsAll(env, path, config)
+}
+
+// runDocs implements the help command for the markdown and man styles.  It
+// mirrors runHelp, but writes pages rather than usage messages.
+func runDocs(env *Env, args []string, path []*Command, config *helpConfig) error {
+	if len(args) == 0 {
+		return writeDoc(env, commandPage(env, path, config), config)
+	}
+	if args[0] == "..." {
+		return docsAll(env, path, config)
+	}
+	cmd, cmdPath := path[len(path)-1], pathName(config.prefix, path)
+	subName, subArgs := args[0], args[1:]
+	// Pages of children are the same as those written by docsAll.
+	childConfig := *config
+	childConfig.firstCall = false
+	if child := findChild(cmd, subName); child != nil {
+		return runDocs(env, subArgs, append(path, child), &childConfig)
+	}
+	if helpName == subName {
+		help := helpRunner{path, config}.newCommand()
+		return runDocs(env, subArgs, append(path, help), &childConfig)
+	}
+	if cmd.LookPath {
+		if subCmd, _ := env.LookPath(cmd.Name + "-" + subName); subCmd != "" {
+			return externalDocs(env, binaryRunner{subCmd, cmdPath}, cmdPath+" "+subName, subArgs, config)
+		}
+	}
+	for _, topic := range cmd.Topics {
+		if topic.Name == subName {
+			return writeDoc(env, topicPage(path, topic, config), config)
+		}
+	}
+	fn := helpRunner{path, config}.usageFunc
+	return usageErrorf(env, fn, "%s: unknown command or topic %q%s", cmdPath, subName, didYouMean(subName, subcommandNames(env, cmd, true)))
+}
+
+// docsAll writes the pages of all commands and topics via DFS from the path
+// onward.
+func docsAll(env *Env, path []*Command, config *helpConfig) error {
+	cmd, cmdPath := path[len(path)-1], pathName(config.prefix, path)
+	if err := writeDoc(env, commandPage(env, path, config), config); err != nil {
+		return err
+	}
+	firstCall := config.firstCall
+	config.firstCall = false
+	defer func() { config.firstCall = firstCall }()
+	for _, child := range cmd.Children {
+		if err := docsAll(env, append(path, child), config); err != nil {
+			return err
+		}
+	}
+	if firstCall && needsHelpChild(cmd) {
+		help := helpRunner{path, config}.newCommand()
+		if err := docsAll(env, append(path, help), config); err != nil {
+			return err
+		}
+	}
+	if cmd.LookPath {
+		cmdPrefix := cmd.Name + "-"
+		subCmds, _ := env.LookPathPrefix(cmdPrefix, cmd.subNames(cmdPrefix))
+		for _, subCmd := range subCmds {
+			name := cmdPath + " " + strings.TrimPrefix(filepath.Base(subCmd), cmdPrefix)
+			if err := externalDocs(env, binaryRunner{subCmd, cmdPath}, name, []string{"..."}, config); err != nil {
+				return err
+			}
+		}
+	}
+	for _, topic := range cmd.Topics {
+		if err := writeDoc(env, topicPage(path, topic, config), config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// externalDocs writes the pages of the external child with the given name.
+// Children that don't support the doc styles get a page holding their -help
+// output.
+func externalDocs(env *Env, runner binaryRunner, name string, args []string, config *helpConfig) error {
+	var buffer bytes.Buffer
+	envCopy := env.clone()
+	envCopy.Stdout = &buffer
+	envCopy.Stderr = &buffer
+	envCopy.Vars["CMDLINE_FIRST_CALL"] = "false"
+	envCopy.Vars["CMDLINE_STYLE"] = config.style.String()
+	helpArgs := []string{helpName, "-style=" + config.style.String()}
+	if config.dir != "" {
+		helpArgs = append(helpArgs, "-dir="+config.dir)
+	}
+	if err := runner.Run(envCopy, append(helpArgs, args...)); err == nil {
+		_, err := io.Copy(env.Stdout, &buffer)
+		return err
+	}
+	buffer.Reset()
+	envCopy.Vars["CMDLINE_STYLE"] = "compact"
+	page := &docPage{name: name, section: 1, short: missingDescription}
+	if err := runner.Run(envCopy, []string{"-help"}); err == nil {
+		page.verbatim = buffer.String()
+	}
+	return writeDoc(env, page, config)
+}
+
+// docPage holds the contents of a single page of documentation, independent of
+// the output style.
+type docPage struct {
+	name     string // Path of the command or topic, e.g. "jiri update".
+	section  int    // Man page section.
+	short    string
+	long     string
+	usage    []string
+	aliases  []string
+	argsLong string
+	verbatim string // Verbatim text, for external children.
+	commands []docEntry
+	topics   []docEntry
+	flags    []docFlags
+	envNames map[string]string // Environment variables of the flags.
+	seeAlso  []docEntry
+}
+
+// docEntry is a reference to the page of a command or topic.
+type docEntry struct {
+	name, short, page string
+}
+
+// docFlags is a titled group of flags.
+type docFlags struct {
+	title string
+	flags []*flag.Flag
+}
+
+// pageName returns the name of the page with the given path, e.g.
+// "jiri-update".
+func pageName(path string) string {
+	return strings.Replace(path, " ", "-", -1)
+}
+
+func commandPage(env *Env, path []*Command, config *helpConfig) *docPage {
+	cmd, cmdPath := path[len(path)-1], pathName(config.prefix, path)
+	page := &docPage{
+		name:     cmdPath,
+		section:  1,
+		short:    cmd.Short,
+		long:     cmd.Long,
+		aliases:  aliasPaths(config.prefix, path),
+		argsLong: cmd.ArgsLong,
+		envNames: envFlagNames(env, config.prefix, path),
+	}
+	// Usage lines.
+	usage := cmdPath
+	if countFlags(pathFlags(path), nil, true) > 0 || countFlags(globalFlags, nil, true) > 0 {
+		usage += " [flags]"
+	}
+	if cmd.Runner != nil {
+		if cmd.ArgsName != "" {
+			page.usage = append(page.usage, usage+" "+cmd.ArgsName)
+		} else {
+			page.usage = append(page.usage, usage)
+		}
+	}
+	var extChildren []string
+	cmdPrefix := cmd.Name + "-"
+	if cmd.LookPath {
+		extChildren, _ = env.LookPathPrefix(cmdPrefix, cmd.subNames(cmdPrefix))
+	}
+	if len(cmd.Children) > 0 || len(extChildren) > 0 {
+		page.usage = append(page.usage, usage+" <command>")
+	}
+	// Commands and topics.
+	for _, child := range cmd.Children {
+		page.commands = append(page.commands, docEntry{child.Name, child.Short, pageName(cmdPath + " " + child.Name)})
+	}
+	if config.firstCall && needsHelpChild(cmd) {
+		page.commands = append(page.commands, docEntry{helpName, helpShort, pageName(cmdPath + " " + helpName)})
+	}
+	for _, extCmd := range extChildren {
+		short := strings.TrimSpace(externalShort(env, path[0], extCmd, cmdPath))
+		extName := strings.TrimPrefix(filepath.Base(extCmd), cmdPrefix)
+		page.commands = append(page.commands, docEntry{extName, short, pageName(cmdPath + " " + extName)})
+	}
+	for _, topic := range cmd.Topics {
+		page.topics = append(page.topics, docEntry{topic.Name, topic.Short, pageName(cmdPath + " " + topic.Name)})
+	}
+	// Flags.
+	allFlags := pathFlags(path)
+	var own, inherited, global []*flag.Flag
+	cmd.Flags.VisitAll(func(f *flag.Flag) { own = append(own, f) })
+	allFlags.VisitAll(func(f *flag.Flag) {
+		if cmd.Flags.Lookup(f.Name) == nil {
+			inherited = append(inherited, f)
+		}
+	})
+	if config.firstCall {
+		globalFlags.VisitAll(func(f *flag.Flag) {
+			if matchRegexps(nonHiddenGlobalFlags, f.Name) {
+				global = append(global, f)
+			}
+		})
+	}
+	for _, group := range []docFlags{{"Flags", own}, {"Inherited flags", inherited}, {"Global flags", global}} {
+		if len(group.flags) > 0 {
+			page.flags = append(page.flags, group)
+		}
+	}
+	if len(path) > 1 {
+		parent := path[:len(path)-1]
+		parentPath := pathName(config.prefix, parent)
+		page.seeAlso = append(page.seeAlso, docEntry{parentPath, parent[len(parent)-1].Short, pageName(parentPath)})
+	}
+	return page
+}
+
+func topicPage(path []*Command, topic Topic, config *helpConfig) *docPage {
+	cmdPath := pathName(config.prefix, path)
+	return &docPage{
+		name:    cmdPath + " " + topic.Name,
+		section: 7,
+		short:   topic.Short,
+		long:    topic.Long,
+		seeAlso: []docEntry{{cmdPath, path[len(path)-1].Short, pageName(cmdPath)}},
+	}
+}
+
+// writeDoc writes the page to the file named after it in config.dir, or to
+// env.Stdout if config.dir is empty.
+func writeDoc(env *Env, page *docPage, config *helpConfig) error {
+	var buf bytes.Buffer
+	ext := "md"
+	switch config.style {
+	case styleMarkdown:
+		writeMarkdown(&buf, page)
+	case styleMan:
+		writeMan(&buf, page)
+		ext = fmt.Sprint(page.section)
+	}
+	if config.dir == "" {
+		_, err := env.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.MkdirAll(config.dir, 0755); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(config.dir, pageName(page.name)+"."+ext), buf.Bytes())
+}
+
+func writeFile(file string, data []byte) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// docBlock is a paragraph of text, or a block of verbatim text such as an
+// example or a table.
+type docBlock struct {
+	verbatim bool
+	lines    []string
+}
+
+// docBlocks splits text into blocks separated by empty lines.  Indented lines
+// are verbatim.  A paragraph that ends with indented lines starts a verbatim
+// block at its first indented line, e.g. "Example:" followed by commands,
+// unless it is a list item.  A paragraph that has indented lines in the middle
+// is verbatim, e.g. an XML snippet.
+func docBlocks(text string) []docBlock {
+	var blocks []docBlock
+	for _, para := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		para = strings.Trim(para, "\n")
+		if para == "" {
+			continue
+		}
+		lines := strings.Split(para, "\n")
+		first := -1
+		for i, line := range lines {
+			if isIndented(line) {
+				first = i
+				break
+			}
+		}
+		switch {
+		case first == -1, strings.HasPrefix(lines[0], "* "), strings.HasPrefix(lines[0], "- "):
+			blocks = append(blocks, docBlock{false, lines})
+		case first == 0, !isIndented(lines[len(lines)-1]):
+			blocks = append(blocks, docBlock{true, lines})
+		default:
+			blocks = append(blocks, docBlock{false, lines[:first]}, docBlock{true, lines[first:]})
+		}
+	}
+	return blocks
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+// writeMarkdown writes the page as Markdown.
+func writeMarkdown(w io.Writer, page *docPage) {
+	fmt.Fprintf(w, "# %s\n\n%s\n", page.name, markdownEscape(page.short))
+	writeBlocks := func(text string) {
+		for _, block := range docBlocks(text) {
+			fmt.Fprintln(w)
+			if block.verbatim {
+				fmt.Fprintf(w, "```\n%s\n```\n", strings.Join(block.lines, "\n"))
+				continue
+			}
+			for _, line := range block.lines {
+				fmt.Fprintln(w, markdownEscape(line))
+			}
+		}
+	}
+	writeBlocks(page.long)
+	if page.verbatim != "" {
+		fmt.Fprintf(w, "\n```\n%s\n```\n", strings.TrimRight(page.verbatim, "\n"))
+	}
+	if len(page.usage) > 0 {
+		fmt.Fprintf(w, "\n## Usage\n\n```\n%s\n```\n", strings.Join(page.usage, "\n"))
+		if len(page.aliases) > 0 {
+			fmt.Fprintf(w, "\nAliases: `%s`\n", strings.Join(page.aliases, "`, `"))
+		}
+		writeBlocks(page.argsLong)
+	}
+	writeEntries := func(title string, entries []docEntry) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n## %s\n\n", title)
+		for _, e := range entries {
+			fmt.Fprintf(w, "* [%s](%s.md) - %s\n", e.name, e.page, markdownEscape(e.short))
+		}
+	}
+	writeEntries("Commands", page.commands)
+	writeEntries("Topics", page.topics)
+	for _, group := range page.flags {
+		fmt.Fprintf(w, "\n## %s\n", group.title)
+		for _, f := range group.flags {
+			fmt.Fprintf(w, "\n* `-%s=%s`", f.Name, f.DefValue)
+			if envName, ok := page.envNames[f.Name]; ok {
+				fmt.Fprintf(w, " (`$%s`)", envName)
+			}
+			fmt.Fprint(w, "\n\n")
+			for _, line := range strings.Split(strings.TrimSpace(f.Usage), "\n") {
+				fmt.Fprintf(w, "  %s\n", markdownEscape(line))
+			}
+		}
+	}
+	writeEntries("See also", page.seeAlso)
+}
+
+func markdownEscape(s string) string {
+	s = strings.Replace(s, "<", `\<`, -1)
+	if strings.HasPrefix(s, "#") {
+		s = `\` + s
+	}
+	return s
+}
+
+// writeMan writes the page as a roff man page.
+func writeMan(w io.Writer, page *docPage) {
+	title := strings.ToUpper(pageName(page.name))
+	fmt.Fprintf(w, ".TH \"%s\" \"%d\"\n", roffEscape(title), page.section)
+	fmt.Fprintf(w, ".SH NAME\n%s \\- %s\n", roffEscape(pageName(page.name)), roffEscape(page.short))
+	if len(page.usage) > 0 {
+		fmt.Fprintln(w, ".SH SYNOPSIS")
+		fmt.Fprintln(w, ".nf")
+		for _, line := range page.usage {
+			fmt.Fprintln(w, roffLine(line))
+		}
+		fmt.Fprintln(w, ".fi")
+		if len(page.aliases) > 0 {
+			fmt.Fprintln(w, ".PP")
+			fmt.Fprintln(w, roffLine("Aliases: "+strings.Join(page.aliases, ", ")))
+		}
+	}
+	writeBlocks := func(text string) {
+		for _, block := range docBlocks(text) {
+			fmt.Fprintln(w, ".PP")
+			if block.verbatim {
+				fmt.Fprintln(w, ".RS 4\n.nf")
+			}
+			for _, line := range block.lines {
+				fmt.Fprintln(w, roffLine(line))
+			}
+			if block.verbatim {
+				fmt.Fprintln(w, ".fi\n.RE")
+			}
+		}
+	}
+	if page.long != "" || page.argsLong != "" || page.verbatim != "" {
+		fmt.Fprintln(w, ".SH DESCRIPTION")
+		writeBlocks(page.long)
+		writeBlocks(page.argsLong)
+		if page.verbatim != "" {
+			fmt.Fprintln(w, ".PP\n.nf")
+			for _, line := range strings.Split(strings.TrimRight(page.verbatim, "\n"), "\n") {
+				fmt.Fprintln(w, roffLine(line))
+			}
+			fmt.Fprintln(w, ".fi")
+		}
+	}
+	writeEntries := func(title string, entries []docEntry) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(w, ".SH %s\n", title)
+		for _, e := range entries {
+			fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roffEscape(e.name), roffLine(e.short))
+		}
+	}
+	writeEntries("COMMANDS", page.commands)
+	writeEntries("TOPICS", page.topics)
+	for _, group := range page.flags {
+		fmt.Fprintf(w, ".SH %s\n", strings.Replace(strings.ToUpper(group.title), "FLAGS", "OPTIONS", 1))
+		for _, f := range group.flags {
+			if envName, ok := page.envNames[f.Name]; ok {
+				fmt.Fprintf(w, ".TP\n.BR \"%s\" \" ($%s)\"\n", roffEscape("-"+f.Name+"="+f.DefValue), roffEscape(envName))
+			} else {
+				fmt.Fprintf(w, ".TP\n.B %s\n", roffEscape("-"+f.Name+"="+f.DefValue))
+			}
+			fmt.Fprintln(w, ".nf")
+			for _, line := range strings.Split(strings.TrimSpace(f.Usage), "\n") {
+				fmt.Fprintln(w, roffLine(line))
+			}
+			fmt.Fprintln(w, ".fi")
+		}
+	}
+	var seeAlso []string
+	for _, e := range append(page.seeAlso, page.commands...) {
+		seeAlso = append(seeAlso, fmt.Sprintf(".BR %s (1)", roffEscape(e.page)))
+	}
+	for _, e := range page.topics {
+		seeAlso = append(seeAlso, fmt.Sprintf(".BR %s (7)", roffEscape(e.page)))
+	}
+	if len(seeAlso) > 0 {
+		fmt.Fprintln(w, ".SH SEE ALSO")
+		fmt.Fprintln(w, strings.Join(seeAlso, ",\n"))
+	}
+}
+
+// roffEscape escapes backslashes and hyphens for roff.
+func roffEscape(s string) string {
+	return strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+}
+
+// roffLine escapes a line of text for roff, so that lines starting with a
+// control character are not interpreted as requests.
+func roffLine(s string) string {
+	s = roffEscape(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWriteDocs(t *testing.T) {
+	tests := []struct {
+		style string
+		files map[string][]string
+	}{
+		{"markdown", map[string][]string{
+			"root.md":          {"# root", "* [mid](root-mid.md) - short", "* [topic](root-topic.md) - short", "## Global flags"},
+			"root-help.md":     {"# root help", "* [root](root.md) - short"},
+			"root-mid.md":      {"# root mid", "## Usage", "root mid [flags] <command>", "* `-flat=false`", "* [leaf](root-mid-leaf.md) - short"},
+			"root-mid-leaf.md": {"# root mid leaf", "root mid leaf [flags] <arg>", "## Flags", "* `-fruit=`", "## Inherited flags", "* `-flat=false`", "* [root mid](root-mid.md) - short"},
+			"root-topic.md":    {"# root topic", "long.", "* [root](root.md) - short"},
+		}},
+		{"man", map[string][]string{
+			"root.1":          {`.TH "ROOT" "1"`, `.BR root\-mid (1)`, `.BR root\-topic (7)`, ".SH GLOBAL OPTIONS"},
+			"root-help.1":     {`.TH "ROOT\-HELP" "1"`},
+			"root-mid.1":      {".SH NAME\nroot\\-mid \\- short\n", ".SH SYNOPSIS", ".SH OPTIONS", `.B \-flat=false`},
+			"root-mid-leaf.1": {".SH OPTIONS", `.B \-fruit=`, ".SH INHERITED OPTIONS", `.BR root\-mid (1)`},
+			"root-topic.7":    {`.TH "ROOT\-TOPIC" "7"`, ".SH DESCRIPTION\n.PP\nlong.\n"},
+		}},
+	}
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		env := &Env{Stdout: ioutil.Discard, Stderr: ioutil.Discard, Vars: map[string]string{}}
+		if err := WriteDocs(newCompleteTree(), env, test.style, dir); err != nil {
+			t.Fatalf("%v: %v", test.style, err)
+		}
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got, want []string
+		for _, info := range infos {
+			got = append(got, info.Name())
+		}
+		for name := range test.files {
+			want = append(want, name)
+		}
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got files %v, want %v", test.style, got, want)
+		}
+		for name, contents := range test.files {
+			data, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Errorf("%v: %v", test.style, err)
+				continue
+			}
+			for _, content := range contents {
+				if !strings.Contains(string(data), content) {
+					t.Errorf("%v: %v does not contain %q:\n%s", test.style, name, content, data)
+				}
+			}
+		}
+	}
+	if err := WriteDocs(newCompleteTree(), &Env{Vars: map[string]string{}}, "godoc", ""); err == nil {
+		t.Errorf("expected an error for the godoc style")
+	}
+}
+
+func TestHelpDocs(t *testing.T) {
+	var stdout bytes.Buffer
+	env := &Env{Stdout: &stdout, Stderr: &stdout, Vars: map[string]string{}}
+	if err := ParseAndRun(newCompleteTree(), env, []string{"help", "-style=markdown", "mid", "leaf"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); !strings.HasPrefix(got, "# root mid leaf\n\nshort\n\nlong.\n") || strings.Contains(got, "# root mid\n") {
+		t.Errorf("unexpected page:\n%s", got)
+	}
+}
+
+func TestDocBlocks(t *testing.T) {
+	text := `
+First paragraph
+continues.
+
+Example:
+  $ run it
+
+* A list item
+  that continues.
+
+<xml>
+  <child/>
+</xml>
+`
+	want := []docBlock{
+		{false, []string{"First paragraph", "continues."}},
+		{false, []string{"Example:"}},
+		{true, []string{"  $ run it"}},
+		{false, []string{"* A list item", "  that continues."}},
+		{true, []string{"<xml>", "  <child/>", "</xml>"}},
+	}
+	if got := docBlocks(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
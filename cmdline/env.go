@@ -137,8 +137,16 @@ const (
 	styleFull                   // Similar to compact but shows all global flags.
 	styleGoDoc                  // Good for godoc processing.
 	styleShortOnly              // Only output short description.
+	styleMarkdown               // Markdown pages.
+	styleMan                    // Roff man pages.
 )
 
+// isDoc returns true if s is a style that renders documentation pages rather
+// than usage messages.
+func (s style) isDoc() bool {
+	return s == styleMarkdown || s == styleMan
+}
+
 func (s *style) String() string {
 	switch *s {
 	case styleCompact:
@@ -149,6 +157,10 @@ func (s *style) String() string {
 		return "godoc"
 	case styleShortOnly:
 		return "shortonly"
+	case styleMarkdown:
+		return "markdown"
+	case styleMan:
+		return "man"
 	default:
 		panic(fmt.Errorf("unhandled style %d", *s))
 	}
@@ -165,6 +177,10 @@ func (s *style) Set(value string) error {
 		*s = styleGoDoc
 	case "shortonly":
 		*s = styleShortOnly
+	case "markdown":
+		*s = styleMarkdown
+	case "man":
+		*s = styleMan
 	default:
 		return fmt.Errorf("unknown style %q", value)
 	}
@@ -0,0 +1,91 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"flag"
+	"strings"
+)
+
+// FlagSource describes where the value of a parsed flag came from.
+type FlagSource int
+
+const (
+	FlagSourceDefault     FlagSource = iota // The default the flag was defined with.
+	FlagSourceConfig                        // FlagDefaults of the root command.
+	FlagSourceEnv                           // An environment variable; see EnvFlags.
+	FlagSourceCommandLine                   // The command line.
+)
+
+func (s FlagSource) String() string {
+	switch s {
+	case FlagSourceDefault:
+		return "default"
+	case FlagSourceConfig:
+		return "config"
+	case FlagSourceEnv:
+		return "env"
+	case FlagSourceCommandLine:
+		return "command line"
+	default:
+		return "unknown"
+	}
+}
+
+// FlagSource returns where the value of the flag with the given name in
+// ParsedFlags came from.  It is only meaningful for the commands of the path
+// that was last parsed.
+func (cmd *Command) FlagSource(name string) FlagSource {
+	return cmd.flagSources[name]
+}
+
+// envFlagsVar is the name of the environment variable that enables EnvFlags
+// for external children.
+const envFlagsVar = "CMDLINE_ENV_FLAGS"
+
+func (e *Env) envFlags() bool {
+	return e.Vars[envFlagsVar] != ""
+}
+
+// envFlagName returns the name of the environment variable of the flag with the
+// given name, defined by the command with the given path, e.g. JIRI_UPDATE_GC
+// for the -gc flag of "jiri update".
+func envFlagName(cmdPath, name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, cmdPath+"_"+name)
+}
+
+// envFlagNames returns the names of the environment variables of the flags of
+// the commands in path, keyed by flag name, or nil if EnvFlags isn't enabled.
+// A flag defined by several commands is bound to the variable of the last one.
+// The NoEnvFlags of each command aren't bound.
+func envFlagNames(env *Env, prefix string, path []*Command) map[string]string {
+	if !path[0].EnvFlags && !env.envFlags() {
+		return nil
+	}
+	names := map[string]string{}
+	for i, cmd := range path {
+		cmdPath := pathName(prefix, path[:i+1])
+		skip := map[string]bool{}
+		for _, name := range cmd.NoEnvFlags {
+			skip[name] = true
+		}
+		cmd.Flags.VisitAll(func(f *flag.Flag) {
+			if skip[f.Name] {
+				delete(names, f.Name)
+				return
+			}
+			names[f.Name] = envFlagName(cmdPath, f.Name)
+		})
+	}
+	return names
+}
@@ -37,17 +37,21 @@ func makeHelpRunner(path []*Command, env *Env) helpRunner {
 	}}
 }
 
-// helpConfig holds configuration data for help.  The style and width may be
-// overriden by flags if the command returned by newCommand is parsed.
+// helpConfig holds configuration data for help.  The style, width and dir may
+// be overriden by flags if the command returned by newCommand is parsed.
 type helpConfig struct {
 	style     style
 	width     int
 	prefix    string
 	firstCall bool
+	dir       string // Output directory of the markdown and man styles.
 }
 
 // Run implements the Runner interface method.
 func (h helpRunner) Run(env *Env, args []string) error {
+	if h.style.isDoc() {
+		return runDocs(env, args, h.path, h.helpConfig)
+	}
 	w := textutil.NewUTF8WrapWriter(env.Stdout, h.width)
 	defer w.Flush()
 	return runHelp(w, env, args, h.path, h.helpConfig)
@@ -89,16 +93,23 @@ The formatting style for help output:
    full      - Good for cmdline output, shows all global flags.
    godoc     - Good for godoc processing.
    shortonly - Only output short description.
+   markdown  - Markdown pages, e.g. for a documentation site.
+   man       - Roff man pages.
 Override the default by setting the CMDLINE_STYLE environment variable.
 `)
 	help.Flags.IntVar(&h.width, "width", h.width, `
 Format output to this target width in runes, or unlimited if width < 0.
 Defaults to the terminal width if available.  Override the default by setting
 the CMDLINE_WIDTH environment variable.
+`)
+	help.Flags.StringVar(&h.dir, "dir", h.dir, `
+With -style=markdown or -style=man, write each page to its own file in this
+directory, rather than to stdout.
 `)
 	// Override default values, so that the godoc style shows good defaults.
 	help.Flags.Lookup("style").DefValue = "compact"
 	help.Flags.Lookup("width").DefValue = "<terminal width>"
+	help.Flags.Lookup("dir").DefValue = ""
 	cleanTree(help)
 	return help
 }
@@ -116,10 +127,8 @@ func runHelp(w *textutil.WrapWriter, env *Env, args []string, path []*Command, c
 	// Look for matching children.
 	cmd, cmdPath := path[len(path)-1], pathName(config.prefix, path)
 	subName, subArgs := args[0], args[1:]
-	for _, child := range cmd.Children {
-		if child.Name == subName {
-			return runHelp(w, env, subArgs, append(path, child), config)
-		}
+	if child := findChild(cmd, subName); child != nil {
+		return runHelp(w, env, subArgs, append(path, child), config)
 	}
 	if helpName == subName {
 		help := helpRunner{path, config}.newCommand()
@@ -145,7 +154,21 @@ func runHelp(w *textutil.WrapWriter, env *Env, args []string, path []*Command, c
 		}
 	}
 	fn := helpRunner{path, config}.usageFunc
-	return usageErrorf(env, fn, "%s: unknown command or topic %q", cmdPath, subName)
+	return usageErrorf(env, fn, "%s: unknown command or topic %q%s", cmdPath, subName, didYouMean(subName, subcommandNames(env, cmd, true)))
+}
+
+// aliasPaths returns the paths of the aliases of the last command in path,
+// e.g. "jiri st" for "jiri status".
+func aliasPaths(prefix string, path []*Command) []string {
+	if len(path) < 2 {
+		return nil
+	}
+	parentPath := pathName(prefix, path[:len(path)-1])
+	var paths []string
+	for _, alias := range path[len(path)-1].Aliases {
+		paths = append(paths, parentPath+" "+alias)
+	}
+	return paths
 }
 
 func godocHeader(path, short string) string {
@@ -316,6 +339,15 @@ func usage(w *textutil.WrapWriter, env *Env, path []*Command, config *helpConfig
 	hasSubcommands := len(cmd.Children) > 0 || len(extChildren) > 0
 	if hasSubcommands {
 		fmt.Fprintln(w, cmdPathF, "<command>")
+	}
+	if aliases := aliasPaths(config.prefix, path); len(aliases) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Aliases:")
+		for _, alias := range aliases {
+			fmt.Fprintln(w, "   "+alias)
+		}
+	}
+	if hasSubcommands {
 		fmt.Fprintln(w)
 	}
 	printShort := func(width int, name, short string) {
@@ -356,17 +388,7 @@ func usage(w *textutil.WrapWriter, env *Env, path []*Command, config *helpConfig
 		// Print as a table with aligned columns Name and Short.
 		w.SetIndents(spaces(3), spaces(3+nameWidth+1))
 		for _, extCmd := range extChildren {
-			runner := binaryRunner{extCmd, cmdPath}
-			var buffer bytes.Buffer
-			envCopy := env.clone()
-			envCopy.Stdout = &buffer
-			envCopy.Stderr = &buffer
-			envCopy.Vars["CMDLINE_STYLE"] = "shortonly"
-			short := missingDescription
-			if err := runner.Run(envCopy, []string{"-help"}); err == nil {
-				// The external child supports "-help".
-				short = buffer.String()
-			}
+			short := externalShort(env, path[0], extCmd, cmdPath)
 			extName := strings.TrimPrefix(filepath.Base(extCmd), cmdPrefix)
 			printShort(nameWidth, extName, short)
 		}
@@ -403,7 +425,7 @@ func usage(w *textutil.WrapWriter, env *Env, path []*Command, config *helpConfig
 			fmt.Fprintf(w, "Run \"%s help [topic]\" for topic details.\n", cmdPath)
 		}
 	}
-	hidden := flagsUsage(w, path, config)
+	hidden := flagsUsage(w, env, path, config)
 	// Only show global flags on the first call.
 	if firstCall {
 		hidden = globalFlagsUsage(w, config) || hidden
@@ -423,9 +445,10 @@ func usage(w *textutil.WrapWriter, env *Env, path []*Command, config *helpConfig
 	}
 }
 
-func flagsUsage(w *textutil.WrapWriter, path []*Command, config *helpConfig) bool {
+func flagsUsage(w *textutil.WrapWriter, env *Env, path []*Command, config *helpConfig) bool {
 	cmd, cmdPath := path[len(path)-1], pathName(config.prefix, path)
 	allFlags := pathFlags(path)
+	envNames := envFlagNames(env, config.prefix, path)
 	numCompact := countFlags(&cmd.Flags, nil, true)
 	numFull := countFlags(allFlags, nil, true) - numCompact
 	if config.style == styleCompact {
@@ -433,7 +456,7 @@ func flagsUsage(w *textutil.WrapWriter, path []*Command, config *helpConfig) boo
 		if numCompact > 0 {
 			fmt.Fprintln(w)
 			fmt.Fprintln(w, "The", cmdPath, "flags are:")
-			printFlags(w, &cmd.Flags, nil, config.style, nil, true)
+			printFlags(w, &cmd.Flags, nil, config.style, nil, true, envNames)
 		}
 		return numFull > 0
 	}
@@ -441,11 +464,11 @@ func flagsUsage(w *textutil.WrapWriter, path []*Command, config *helpConfig) boo
 	if numCompact > 0 || numFull > 0 {
 		fmt.Fprintln(w)
 		fmt.Fprintln(w, "The", cmdPath, "flags are:")
-		printFlags(w, &cmd.Flags, nil, config.style, nil, true)
+		printFlags(w, &cmd.Flags, nil, config.style, nil, true, envNames)
 		if numCompact > 0 && numFull > 0 {
 			fmt.Fprintln(w)
 		}
-		printFlags(w, allFlags, &cmd.Flags, config.style, nil, true)
+		printFlags(w, allFlags, &cmd.Flags, config.style, nil, true, envNames)
 	}
 	return false
 }
@@ -458,7 +481,7 @@ func globalFlagsUsage(w *textutil.WrapWriter, config *helpConfig) bool {
 		if numCompact > 0 {
 			fmt.Fprintln(w)
 			fmt.Fprintln(w, "The global flags are:")
-			printFlags(w, globalFlags, nil, config.style, nonHiddenGlobalFlags, true)
+			printFlags(w, globalFlags, nil, config.style, nonHiddenGlobalFlags, true, nil)
 		}
 		return numFull > 0
 	}
@@ -466,11 +489,11 @@ func globalFlagsUsage(w *textutil.WrapWriter, config *helpConfig) bool {
 	if numCompact > 0 || numFull > 0 {
 		fmt.Fprintln(w)
 		fmt.Fprintln(w, "The global flags are:")
-		printFlags(w, globalFlags, nil, config.style, nonHiddenGlobalFlags, true)
+		printFlags(w, globalFlags, nil, config.style, nonHiddenGlobalFlags, true, nil)
 		if numCompact > 0 && numFull > 0 {
 			fmt.Fprintln(w)
 		}
-		printFlags(w, globalFlags, nil, config.style, nonHiddenGlobalFlags, false)
+		printFlags(w, globalFlags, nil, config.style, nonHiddenGlobalFlags, false, nil)
 	}
 	return false
 }
@@ -484,7 +507,9 @@ func countFlags(flags *flag.FlagSet, regexps []*regexp.Regexp, match bool) (num
 	return
 }
 
-func printFlags(w *textutil.WrapWriter, flags, filter *flag.FlagSet, style style, regexps []*regexp.Regexp, match bool) {
+// printFlags prints the usage of the flags.  The environment variables of the
+// flags in envNames are shown along with their values.
+func printFlags(w *textutil.WrapWriter, flags, filter *flag.FlagSet, style style, regexps []*regexp.Regexp, match bool, envNames map[string]string) {
 	flags.VisitAll(func(f *flag.Flag) {
 		if filter != nil && filter.Lookup(f.Name) != nil {
 			return
@@ -499,6 +524,9 @@ func printFlags(w *textutil.WrapWriter, flags, filter *flag.FlagSet, style style
 			value = f.DefValue
 		}
 		fmt.Fprintf(w, " -%s=%v", f.Name, value)
+		if envName, ok := envNames[f.Name]; ok {
+			fmt.Fprintf(w, " ($%s)", envName)
+		}
 		w.SetIndents(spaces(3))
 		fmt.Fprintln(w, f.Usage)
 		w.SetIndents()
@@ -539,3 +567,24 @@ func HideGlobalFlagsExcept(regexps ...*regexp.Regexp) {
 		nonHiddenGlobalFlags = []*regexp.Regexp{}
 	}
 }
+
+// externalShort returns the short description of the external child with the
+// given binary, from the ExternalShort hook of root if it knows it, or else by
+// running the binary with -help.
+func externalShort(env *Env, root *Command, extCmd, cmdPath string) string {
+	if root.ExternalShort != nil {
+		if short, ok := root.ExternalShort(extCmd); ok {
+			return short
+		}
+	}
+	var buffer bytes.Buffer
+	envCopy := env.clone()
+	envCopy.Stdout = &buffer
+	envCopy.Stderr = &buffer
+	envCopy.Vars["CMDLINE_STYLE"] = "shortonly"
+	if err := (binaryRunner{extCmd, cmdPath}).Run(envCopy, []string{"-help"}); err != nil {
+		return missingDescription
+	}
+	// The external child supports "-help".
+	return buffer.String()
+}
@@ -0,0 +1,103 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// didYouMean returns a suffix for the error about the unknown name, that
+// suggests the candidates close to it, if any, e.g. `; did you mean "update"?`.
+func didYouMean(name string, candidates []string) string {
+	suggestions := suggest(name, candidates)
+	if len(suggestions) == 0 {
+		return ""
+	}
+	var quoted []string
+	for _, s := range suggestions {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+	if len(quoted) == 1 {
+		return fmt.Sprintf("; did you mean %s?", quoted[0])
+	}
+	last := len(quoted) - 1
+	return fmt.Sprintf("; did you mean %s or %s?", strings.Join(quoted[:last], ", "), quoted[last])
+}
+
+// subcommandNames returns the names and aliases of the compiled-in and
+// external children of cmd, and the names of its topics if topics is true.
+func subcommandNames(env *Env, cmd *Command, topics bool) []string {
+	names := childNames(env, cmd)
+	for _, child := range cmd.Children {
+		names = append(names, child.Aliases...)
+	}
+	if topics {
+		for _, topic := range cmd.Topics {
+			names = append(names, topic.Name)
+		}
+	}
+	return names
+}
+
+// suggest returns the sorted unique candidates that are close to name: those
+// within a small edit distance of it, and those it is a prefix of.
+func suggest(name string, candidates []string) []string {
+	maxDist := len(name) / 3
+	if maxDist < 1 {
+		maxDist = 1
+	}
+	seen := map[string]bool{}
+	var result []string
+	for _, c := range candidates {
+		if seen[c] || c == name {
+			continue
+		}
+		if editDistance(name, c) <= maxDist || len(name) > 1 && strings.HasPrefix(c, name) {
+			seen[c] = true
+			result = append(result, c)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// editDistance returns the number of insertions, deletions, substitutions and
+// transpositions of adjacent bytes needed to turn a into b.
+func editDistance(a, b string) int {
+	// d[i][j] is the distance between a[:i] and b[:j].
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
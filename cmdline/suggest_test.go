@@ -0,0 +1,129 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmdline
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"status", "stash", "snapshot", "update", "upload", "help"}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"updat", []string{"update"}},
+		{"udpate", []string{"update"}},
+		{"stauts", []string{"status"}},
+		{"sanpshot", []string{"snapshot"}},
+		{"st", []string{"stash", "status"}},
+		{"up", []string{"update", "upload"}},
+		{"x", nil},
+		{"frobnicate", nil},
+		{"help", nil},
+	}
+	for _, test := range tests {
+		if got := suggest(test.name, candidates); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.name, got, test.want)
+		}
+	}
+	if got, want := didYouMean("st", candidates), `; did you mean "stash" or "status"?`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func newAliasTree() *Command {
+	status := &Command{
+		Runner:  RunnerFunc(runHello),
+		Name:    "status",
+		Short:   "short",
+		Long:    "long.",
+		Aliases: []string{"st"},
+	}
+	status.Flags.Bool("v", false, "bool")
+	return &Command{
+		Name:     "root",
+		Short:    "short",
+		Long:     "long.",
+		Children: []*Command{status},
+		Topics:   []Topic{{Name: "topic", Short: "short", Long: "long."}},
+		ExpandAlias: func(path []string, name string) ([]string, error) {
+			switch name {
+			case "sv":
+				return []string{"st", "-v"}, nil
+			case "bad":
+				return []string{"missing"}, nil
+			}
+			return nil, nil
+		},
+	}
+}
+
+func TestAliases(t *testing.T) {
+	tests := []struct {
+		args   []string
+		want   string
+		errMsg string
+	}{
+		{[]string{"status"}, "Hello\n", ""},
+		{[]string{"st"}, "Hello\n", ""},
+		{[]string{"sv"}, "Hello\n", ""},
+		{[]string{"stauts"}, "", `root: unknown command "stauts"; did you mean "status"?`},
+		{[]string{"bad"}, "", `root: alias "bad" expands to unknown command "missing"`},
+		{[]string{"help", "tpoic"}, "", `root: unknown command or topic "tpoic"; did you mean "topic"?`},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		env := &Env{Stdout: &stdout, Stderr: &stderr, Vars: map[string]string{}}
+		err := ParseAndRun(newAliasTree(), env, test.args)
+		if got, want := stdout.String(), test.want; got != want {
+			t.Errorf("%v: got stdout %q, want %q", test.args, got, want)
+		}
+		if test.errMsg == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", test.args, err)
+			}
+		} else if !strings.Contains(stderr.String(), test.errMsg) {
+			t.Errorf("%v: stderr %q does not contain %q", test.args, stderr.String(), test.errMsg)
+		}
+	}
+
+	// The -v flag of the expansion is parsed by the aliased command.
+	root := newAliasTree()
+	env := &Env{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}, Vars: map[string]string{}}
+	if _, _, err := Parse(root, env, []string{"sv"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := root.Children[0].ParsedFlags.Lookup("v").Value.String(); got != "true" {
+		t.Errorf("got -v=%v for the expansion of sv, want true", got)
+	}
+
+	// Help shows the aliases, and follows them.
+	var stdout bytes.Buffer
+	env = &Env{Stdout: &stdout, Stderr: &stdout, Vars: map[string]string{}}
+	if err := ParseAndRun(newAliasTree(), env, []string{"help", "st"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Usage:\n   root status [flags]\n\nAliases:\n   root st\n"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("help does not contain %q:\n%s", want, stdout.String())
+	}
+
+	// Completion follows aliases, and completes them once a prefix is typed.
+	root = newAliasTree()
+	if got, want := complete(env, root, nil, ""), []string{"help", "status"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := complete(env, root, nil, "s"), []string{"st", "status"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, words := range [][]string{{"st"}, {"sv"}} {
+		if got, want := complete(env, root, words, "-v"), []string{"-v"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %v, want %v", words, got, want)
+		}
+	}
+}
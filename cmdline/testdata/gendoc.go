@@ -13,6 +13,10 @@
 // [args] are the arguments to pass to the tool to produce usage output.  If no
 // args are given, runs "<tool> help ..."
 //
+// With -markdown-dir or -man-dir, also writes the Markdown or man pages of the
+// whole command tree to the given directory, via
+// "<tool> help -style=<style> -dir=<dir> ...".
+//
 // The reason this command is located under a testdata directory is to enforce
 // its idiomatic use via "go run".
 //
@@ -36,15 +40,19 @@ import (
 )
 
 var (
-	flagEnv     string
-	flagInstall string
-	flagOut     string
-	flagTags    string
+	flagEnv         string
+	flagInstall     string
+	flagManDir      string
+	flagMarkdownDir string
+	flagOut         string
+	flagTags        string
 )
 
 func main() {
 	flag.StringVar(&flagEnv, "env", "os", `Environment variables to set before running command.  If "os", grabs vars from the underlying OS.  If empty, doesn't set any vars.  Otherwise vars are expected to be comma-separated entries of the form KEY1=VALUE1,KEY2=VALUE2,...`)
 	flag.StringVar(&flagInstall, "install", "", "Comma separated list of packages to install before running command.  All commands that are built will be on the PATH.")
+	flag.StringVar(&flagManDir, "man-dir", "", "Directory to write the man pages of the command tree to.  If empty, no man pages are written.")
+	flag.StringVar(&flagMarkdownDir, "markdown-dir", "", "Directory to write the Markdown pages of the command tree to.  If empty, no Markdown pages are written.")
 	flag.StringVar(&flagOut, "out", "./doc.go", "Path to the output file.")
 	flag.StringVar(&flagTags, "tags", "", "Tags for go build, also added as build constraints in the generated output file.")
 	flag.Parse()
@@ -120,6 +128,21 @@ package main
 	if err := ioutil.WriteFile(path, []byte(doc), perm); err != nil {
 		return fmt.Errorf("WriteFile(%v, %v) failed: %v\n", path, perm, err)
 	}
+
+	// Write the doc pages of the whole command tree.
+	for _, pages := range []struct{ style, dir string }{{"markdown", flagMarkdownDir}, {"man", flagManDir}} {
+		if pages.dir == "" {
+			continue
+		}
+		var out bytes.Buffer
+		pagesCmd := exec.Command(filepath.Join(tmpDir, binName), "help", "-style="+pages.style, "-dir="+pages.dir, "...")
+		pagesCmd.Stdout = &out
+		pagesCmd.Stderr = &out
+		pagesCmd.Env = runEnviron(tmpDir)
+		if err := pagesCmd.Run(); err != nil {
+			return fmt.Errorf("%q failed: %v\n%v\n", strings.Join(pagesCmd.Args, " "), err, out.String())
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,208 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jiri
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// ConfigFile is the name of the root config file, in the root metadata
+	// directory.
+	ConfigFile = "config"
+
+	// CacheDirKey is the config key of the directory holding the caches of
+	// the jiri root.  Relative paths are relative to the root.
+	CacheDirKey = "cache.dir"
+
+	// AliasKeyPrefix is the prefix of the config keys of aliases; see Alias.
+	AliasKeyPrefix = "alias."
+)
+
+// Config holds the settings stored in a jiri config file, as key/value pairs.
+//
+// Besides the settings used by jiri.X, keys of the form
+// [<command>.[<subcommand>.]...]<flag> provide default values for the flags of
+// jiri commands; see FlagDefaults.  Keys of the form
+// alias.[<command>.]...<name> define aliases; see Alias.
+type Config struct {
+	Settings []ConfigSetting `xml:"setting"`
+	XMLName  struct{}        `xml:"config"`
+}
+
+// ConfigSetting is a single setting of a config file.
+type ConfigSetting struct {
+	Key     string   `xml:"key,attr"`
+	Value   string   `xml:"value,attr"`
+	XMLName struct{} `xml:"setting"`
+}
+
+// LoadConfig loads the config from the given file.  A missing file results in
+// an empty config.
+func LoadConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+	config := &Config{}
+	if err := xml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid config file %v: %v", file, err)
+	}
+	return config, nil
+}
+
+// UserConfigFile returns the path to the user config file,
+// $XDG_CONFIG_HOME/jiri/config, where XDG_CONFIG_HOME defaults to
+// $HOME/.config.
+func UserConfigFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "jiri", ConfigFile)
+}
+
+// LoadConfigs loads the user config file and, unless root is empty, the root
+// config file of the given jiri root, and returns their merged settings.
+// Settings of the root config take precedence over those of the user config.
+func LoadConfigs(root string) (*Config, error) {
+	config, err := LoadConfig(UserConfigFile())
+	if err != nil {
+		return nil, err
+	}
+	if root == "" {
+		return config, nil
+	}
+	rootConfig, err := LoadConfig(filepath.Join(root, RootMetaDir, ConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range rootConfig.Settings {
+		config.Set(s.Key, s.Value)
+	}
+	return config, nil
+}
+
+// Get returns the value of the setting with the given key, and whether it is
+// set.
+func (c *Config) Get(key string) (string, bool) {
+	for _, s := range c.Settings {
+		if s.Key == key {
+			return s.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the value of the setting with the given key.
+func (c *Config) Set(key, value string) {
+	for i := range c.Settings {
+		if c.Settings[i].Key == key {
+			c.Settings[i].Value = value
+			return
+		}
+	}
+	c.Settings = append(c.Settings, ConfigSetting{Key: key, Value: value})
+}
+
+// Unset removes the setting with the given key, and returns whether it was set.
+func (c *Config) Unset(key string) bool {
+	for i := range c.Settings {
+		if c.Settings[i].Key == key {
+			c.Settings = append(c.Settings[:i], c.Settings[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// FlagDefaults returns the default flag values that the config provides for
+// the command with the given path of names, e.g. ["snapshot", "create"] for
+// "jiri snapshot create", keyed by flag name.  The key of a setting is the
+// flag name, prefixed with the dot-separated names of the command or one of
+// its ancestors; settings of more specific commands take precedence.  For
+// example, "snapshot.create.dir", "snapshot.dir" and "dir" all set the default
+// of the -dir flag of "jiri snapshot create", in decreasing order of
+// precedence.
+func (c *Config) FlagDefaults(path []string) map[string]string {
+	defaults := map[string]string{}
+	depths := map[string]int{}
+	for _, s := range c.Settings {
+		prefix, name := "", s.Key
+		if i := strings.LastIndex(s.Key, "."); i != -1 {
+			prefix, name = s.Key[:i], s.Key[i+1:]
+		}
+		if name == "" {
+			continue
+		}
+		for depth := len(path); depth >= 0; depth-- {
+			if prefix != strings.Join(path[:depth], ".") {
+				continue
+			}
+			if d, ok := depths[name]; !ok || depth > d {
+				defaults[name], depths[name] = s.Value, depth
+			}
+			break
+		}
+	}
+	return defaults
+}
+
+// Alias returns the args that the alias with the given name expands to when it
+// is used as a subcommand of the command with the given path of names, or nil
+// if there is no such alias.  The key of the setting is the name, prefixed
+// with "alias." and the dot-separated names of the command, and its value is
+// a command followed by flags and args, separated by spaces.  For example, the
+// setting "alias.up" = "update -gc" makes "jiri up" run "jiri update -gc", and
+// "alias.snapshot.co" = "checkout" makes "jiri snapshot co" run
+// "jiri snapshot checkout".
+func (c *Config) Alias(path []string, name string) []string {
+	key := AliasKeyPrefix + strings.Join(append(append([]string(nil), path...), name), ".")
+	value, ok := c.Get(key)
+	if !ok {
+		return nil
+	}
+	return strings.Fields(value)
+}
+
+// SortedSettings returns a copy of the settings, sorted by key.
+func (c *Config) SortedSettings() []ConfigSetting {
+	settings := append(configSettings(nil), c.Settings...)
+	sort.Sort(settings)
+	return settings
+}
+
+// Write writes the config to the given file, with the settings sorted by key.
+func (c *Config) Write(file string) error {
+	sort.Sort(configSettings(c.Settings))
+	data, err := xml.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("config xml.Marshal failed: %v", err)
+	}
+	// Same hack as in project.Manifest.ToBytes, to make the output less
+	// verbose.
+	data = bytes.Replace(data, []byte("></setting>"), []byte("/>"), -1)
+	data = append(data, '\n')
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+type configSettings []ConfigSetting
+
+func (s configSettings) Len() int           { return len(s) }
+func (s configSettings) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s configSettings) Less(i, j int) bool { return s[i].Key < s[j].Key }
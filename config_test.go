@@ -0,0 +1,179 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jiri
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestConfig checks that configs round-trip through files, and that missing
+// files result in empty configs.
+func TestConfig(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	file := filepath.Join(tmpDir, RootMetaDir, ConfigFile)
+
+	config, err := LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Settings) != 0 {
+		t.Errorf("got settings %v for missing file, want none", config.Settings)
+	}
+	config.Set("update.gc", "true")
+	config.Set(CacheDirKey, "/tmp/cache")
+	config.Set("update.gc", "false")
+	if err := config.Write(file); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<config>
+  <setting key="cache.dir" value="/tmp/cache"/>
+  <setting key="update.gc" value="false"/>
+</config>
+`
+	if got := string(data); got != want {
+		t.Errorf("got config file %q, want %q", got, want)
+	}
+
+	config, err = LoadConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := config.Get("update.gc"); !ok || got != "false" {
+		t.Errorf("got update.gc %q, %v, want false", got, ok)
+	}
+	if _, ok := config.Get("missing"); ok {
+		t.Errorf("got a value for a missing key")
+	}
+
+	x := &X{Root: tmpDir, Config: config}
+	if got, want := x.CacheDir(), "/tmp/cache"; got != want {
+		t.Errorf("got cache dir %q, want %q", got, want)
+	}
+	config.Set(CacheDirKey, "cache")
+	if got, want := x.CacheDir(), filepath.Join(tmpDir, "cache"); got != want {
+		t.Errorf("got cache dir %q, want %q", got, want)
+	}
+}
+
+// TestLoadConfigs checks that root config settings override user config
+// settings.
+func TestLoadConfigs(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	if err := os.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "home")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := UserConfigFile(), filepath.Join(tmpDir, "home", "jiri", ConfigFile); got != want {
+		t.Errorf("got user config file %q, want %q", got, want)
+	}
+
+	user := &Config{}
+	user.Set("update.gc", "true")
+	user.Set("offline", "true")
+	if err := user.Write(UserConfigFile()); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(tmpDir, "root")
+	rootConfig := &Config{}
+	rootConfig.Set("update.gc", "false")
+	if err := rootConfig.Write(filepath.Join(root, RootMetaDir, ConfigFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		root string
+		want map[string]string
+	}{
+		{"", map[string]string{"update.gc": "true", "offline": "true"}},
+		{root, map[string]string{"update.gc": "false", "offline": "true"}},
+	} {
+		config, err := LoadConfigs(test.root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		for _, s := range config.Settings {
+			got[s.Key] = s.Value
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("root %q: got settings %v, want %v", test.root, got, test.want)
+		}
+	}
+}
+
+// TestConfigFlagDefaults checks that settings of more specific commands take
+// precedence.
+func TestConfigFlagDefaults(t *testing.T) {
+	config := &Config{}
+	config.Set("snapshot.create.dir", "create-dir")
+	config.Set("snapshot.dir", "snapshot-dir")
+	config.Set("dir", "dir")
+	config.Set("v", "true")
+	config.Set("update.gc", "true")
+	config.Set(CacheDirKey, "cache")
+	if config.Unset("missing") {
+		t.Errorf("unset a missing key")
+	}
+
+	tests := []struct {
+		path []string
+		want map[string]string
+	}{
+		{nil, map[string]string{"dir": "dir", "v": "true"}},
+		{[]string{"update"}, map[string]string{"dir": "dir", "v": "true", "gc": "true"}},
+		{[]string{"snapshot"}, map[string]string{"dir": "snapshot-dir", "v": "true"}},
+		{[]string{"snapshot", "create"}, map[string]string{"dir": "create-dir", "v": "true"}},
+		{[]string{"snapshot", "checkout"}, map[string]string{"dir": "snapshot-dir", "v": "true"}},
+	}
+	for _, test := range tests {
+		if got := config.FlagDefaults(test.path); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.path, got, test.want)
+		}
+	}
+
+	if !config.Unset("snapshot.create.dir") {
+		t.Errorf("failed to unset snapshot.create.dir")
+	}
+	if got, want := config.FlagDefaults([]string{"snapshot", "create"})["dir"], "snapshot-dir"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestConfigAlias(t *testing.T) {
+	config := &Config{}
+	config.Set("alias.up", "update -gc")
+	config.Set("alias.snapshot.co", "checkout")
+	tests := []struct {
+		path []string
+		name string
+		want []string
+	}{
+		{nil, "up", []string{"update", "-gc"}},
+		{nil, "co", nil},
+		{[]string{"snapshot"}, "co", []string{"checkout"}},
+		{[]string{"snapshot"}, "up", nil},
+	}
+	for _, test := range tests {
+		if got := config.Alias(test.path, test.name); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v %q: got %v, want %v", test.path, test.name, got, test.want)
+		}
+	}
+}
@@ -99,6 +99,25 @@ func (g *Git) AddRemote(name, path string) error {
 	return g.run("remote", "add", name, path)
 }
 
+// Am applies the patches in the given mailbox to the current branch,
+// removing strip leading components from the file names, as for "git am -p".
+func (g *Git) Am(mbox []byte, strip int) error {
+	var stdout, stderr bytes.Buffer
+	fn := func(s runutil.Sequence) runutil.Sequence {
+		return s.Read(bytes.NewReader(mbox)).Capture(&stdout, &stderr)
+	}
+	args := []string{"am", fmt.Sprintf("-p%d", strip)}
+	if err := g.runWithFn(fn, args...); err != nil {
+		return Error(stdout.String(), stderr.String(), args...)
+	}
+	return nil
+}
+
+// AmAbort aborts an in-progress "git am".
+func (g *Git) AmAbort() error {
+	return g.run("am", "--abort")
+}
+
 // BranchExists tests whether a branch with the given name exists in
 // the local repository.
 func (g *Git) BranchExists(branch string) bool {
@@ -306,6 +325,13 @@ func (g *Git) DeleteBranch(branch string, opts ...DeleteBranchOpt) error {
 	return g.run(args...)
 }
 
+// Diff returns the output of "git diff" for the given arguments, with file
+// names prefixed by prefix, e.g. "a/<prefix>/<file>".
+func (g *Git) Diff(prefix string, args ...string) (string, error) {
+	srcPrefix, dstPrefix := patchPrefixes(prefix)
+	return g.runRawOutput(append([]string{"diff", "--no-color", "--no-ext-diff", "--binary", srcPrefix, dstPrefix}, args...)...)
+}
+
 // DirExistsOnBranch returns true if a directory with the given name
 // exists on the branch.  If branch is empty it defaults to "master".
 func (g *Git) DirExistsOnBranch(dir, branch string) bool {
@@ -360,6 +386,18 @@ func (g *Git) FilesWithUncommittedChanges() ([]string, error) {
 	return append(out, out2...), nil
 }
 
+// FormatPatch returns, in mailbox format, the patches for the commits in the
+// given revision range, with file names prefixed by prefix.  The given
+// headers, of the form "<name>: <value>", are added to each message.
+func (g *Git) FormatPatch(prefix, revRange string, headers ...string) (string, error) {
+	srcPrefix, dstPrefix := patchPrefixes(prefix)
+	args := []string{"format-patch", "--stdout", "--no-color", "--binary", srcPrefix, dstPrefix}
+	for _, header := range headers {
+		args = append(args, "--add-header="+header)
+	}
+	return g.runRawOutput(append(args, revRange)...)
+}
+
 // GetBranches returns a slice of the local branches of the current
 // repository, followed by the name of the current branch. The
 // behavior can be customized by providing optional arguments
@@ -417,6 +455,12 @@ func (g *Git) IsFileCommitted(file string) bool {
 	return g.run("ls-files", file, "--error-unmatch") == nil
 }
 
+// IsRevisionAvailable tests whether the given revision exists as a commit in
+// the local repository.
+func (g *Git) IsRevisionAvailable(revision string) bool {
+	return g.run("cat-file", "-e", revision+"^{commit}") == nil
+}
+
 // LatestCommitMessage returns the latest commit message on the
 // current branch.
 func (g *Git) LatestCommitMessage() (string, error) {
@@ -448,6 +492,30 @@ func (g *Git) Log(branch, base, format string) ([][]string, error) {
 	return result, nil
 }
 
+// LogEntries returns the commits selected by the given "git log" arguments,
+// e.g. a revision range and filters such as --since.  Each entry holds the
+// fields of a commit described by the given format placeholders, e.g. "%H".
+func (g *Git) LogEntries(fields []string, args ...string) ([][]string, error) {
+	var stdout, stderr bytes.Buffer
+	fn := func(s runutil.Sequence) runutil.Sequence { return s.Capture(&stdout, &stderr) }
+	// Separate fields with NUL and entries with RS, as either may span
+	// multiple lines.
+	formatArg := fmt.Sprintf("--format=%s%%x1e", strings.Join(fields, "%x00"))
+	logArgs := append([]string{"log", formatArg}, args...)
+	if err := g.runWithFn(fn, logArgs...); err != nil {
+		return nil, Error(stdout.String(), stderr.String(), logArgs...)
+	}
+	var entries [][]string
+	for _, entry := range strings.Split(stdout.String(), "\x1e") {
+		entry = strings.TrimPrefix(entry, "\n")
+		if entry == "" {
+			continue
+		}
+		entries = append(entries, strings.Split(entry, "\x00"))
+	}
+	return entries, nil
+}
+
 // Merge merges all commits from <branch> to the current branch. If
 // <squash> is set, then all merged commits are squashed into a single
 // commit.
@@ -625,6 +693,12 @@ func (g *Git) SetRemoteUrl(name, url string) error {
 	return g.run("remote", "set-url", name, url)
 }
 
+// SetUpstream sets the upstream of the given local branch to the given
+// upstream, which may be a local branch.
+func (g *Git) SetUpstream(branch, upstream string) error {
+	return g.run("branch", "--set-upstream-to="+upstream, branch)
+}
+
 // Stash attempts to stash any unsaved changes. It returns true if
 // anything was actually stashed, otherwise false. An error is
 // returned if the stash command fails.
@@ -690,6 +764,20 @@ func (g *Git) UntrackedFiles() ([]string, error) {
 	return out, nil
 }
 
+// UpstreamBranch returns the upstream branch of the given local branch, e.g.
+// "master" or "origin/master", or the empty string if the branch doesn't track
+// any branch.
+func (g *Git) UpstreamBranch(branch string) (string, error) {
+	out, err := g.runOutput("for-each-ref", "--format=%(upstream:short)", "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	if len(out) == 0 {
+		return "", nil
+	}
+	return out[0], nil
+}
+
 // Version returns the major and minor git version.
 func (g *Git) Version() (int, int, error) {
 	out, err := g.runOutput("version")
@@ -727,6 +815,15 @@ func (g *Git) run(args ...string) error {
 	return nil
 }
 
+// patchPrefixes returns the --src-prefix and --dst-prefix arguments that root
+// the file names of a patch at the given directory.
+func patchPrefixes(prefix string) (string, string) {
+	if prefix != "" {
+		prefix = strings.TrimSuffix(prefix, "/") + "/"
+	}
+	return "--src-prefix=a/" + prefix, "--dst-prefix=b/" + prefix
+}
+
 func trimOutput(o string) []string {
 	output := strings.TrimSpace(o)
 	if len(output) == 0 {
@@ -744,6 +841,17 @@ func (g *Git) runOutput(args ...string) ([]string, error) {
 	return trimOutput(stdout.String()), nil
 }
 
+// runRawOutput returns the standard output of the given git command, without
+// removing whitespace or splitting it into lines.
+func (g *Git) runRawOutput(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	fn := func(s runutil.Sequence) runutil.Sequence { return s.Capture(&stdout, &stderr) }
+	if err := g.runWithFn(fn, args...); err != nil {
+		return "", Error(stdout.String(), stderr.String(), args...)
+	}
+	return stdout.String(), nil
+}
+
 func (g *Git) runInteractive(args ...string) error {
 	var stderr bytes.Buffer
 	// In order for the editing to work correctly with
@@ -0,0 +1,45 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jiri
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	interruptOnce sync.Once
+	interruptCtx  context.Context
+)
+
+// interruptContext returns a context that is cancelled when the process
+// receives SIGINT or SIGTERM, which stops the commands run by the sequences of
+// an X and lets long operations stop at a safe point.  A second signal
+// terminates the process right away.  The signal handler is installed on the
+// first call.
+func interruptContext() context.Context {
+	interruptOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		interruptCtx = ctx
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			sig := <-ch
+			fmt.Fprintf(os.Stderr, "jiri: received %v, stopping; repeat to exit immediately\n", sig)
+			cancel()
+			sig = <-ch
+			// Restore the default behavior, and deliver the signal again.
+			signal.Stop(ch)
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				p.Signal(sig)
+			}
+		}()
+	})
+	return interruptCtx
+}
@@ -105,6 +105,19 @@ func (fake FakeJiriRoot) AddProject(project project.Project) error {
 	return nil
 }
 
+// AddPackage adds the given package to a remote manifest.
+func (fake FakeJiriRoot) AddPackage(pkg project.Package) error {
+	manifest, err := fake.ReadRemoteManifest()
+	if err != nil {
+		return err
+	}
+	manifest.Packages = append(manifest.Packages, pkg)
+	if err := fake.WriteRemoteManifest(manifest); err != nil {
+		return err
+	}
+	return nil
+}
+
 // AddTool adds the given tool to a remote manifest.
 func (fake FakeJiriRoot) AddTool(tool project.Tool) error {
 	manifest, err := fake.ReadRemoteManifest()
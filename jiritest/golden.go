@@ -0,0 +1,50 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jiritest
+
+import (
+	"os"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/runutil"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+// UpdateGoldenEnv is the environment variable that, if set to "true", makes
+// GoldenX run commands and rewrite golden transcripts rather than replay them.
+const UpdateGoldenEnv = "JIRITEST_UPDATE_GOLDEN"
+
+// GoldenX returns a copy of the given X whose sequences replay the commands of
+// the golden transcript in the given file rather than run them, and a closure
+// that must be run at the end of the test, typically as a defer function; it
+// fails the test if the commands that were run don't match the transcript.
+// The root of the X is written as ${ROOT} in the transcript.
+//
+// If UpdateGoldenEnv is set to "true", the commands are run, and the closure
+// writes their transcript to the file instead.  This lets a test of code that
+// uses gitutil.Git, for example, record the git commands it runs against a
+// real repository once, and then run without git.
+func GoldenX(t *testing.T, x *jiri.X, file string) (*jiri.X, func()) {
+	vars := map[string]string{"ROOT": x.Root}
+	if os.Getenv(UpdateGoldenEnv) == "true" {
+		recorder := runutil.NewRecordingExecutor(vars)
+		return x.Clone(tool.ContextOpts{Executor: recorder}), func() {
+			if err := runutil.WriteTranscript(file, recorder.Transcript()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	transcript, err := runutil.ReadTranscript(file)
+	if err != nil {
+		t.Fatalf("%v; set %s=true to record it", err, UpdateGoldenEnv)
+	}
+	replayer := runutil.NewReplayExecutor(transcript, vars)
+	return x.Clone(tool.ContextOpts{Executor: replayer}), func() {
+		if err := replayer.Done(); err != nil {
+			t.Errorf("commands don't match the golden transcript %s; set %s=true to update it:\n%v", file, UpdateGoldenEnv, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jiritest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri/gitutil"
+)
+
+func TestGoldenX(t *testing.T) {
+	x, cleanup := NewX(t)
+	defer cleanup()
+	x, done := GoldenX(t, x, filepath.Join("testdata", "golden_git.json"))
+	defer done()
+
+	repo := filepath.Join(x.Root, "repo")
+	if err := gitutil.New(x.NewSeq()).Init(repo); err != nil {
+		t.Fatal(err)
+	}
+	// Git doesn't run when the transcript is replayed, so the test creates
+	// the directory itself.
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(repo, "README"), []byte("readme\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	date := "2016-01-01T00:00:00Z"
+	git := gitutil.New(x.NewSeq(), gitutil.RootDirOpt(repo), gitutil.AuthorDateOpt(date), gitutil.CommitterDateOpt(date))
+	if err := git.CommitFile("README", "Add README"); err != nil {
+		t.Fatal(err)
+	}
+	if !git.IsFileCommitted("README") {
+		t.Errorf("README isn't committed")
+	}
+	if revision, err := git.CurrentRevision(); err != nil || len(revision) != 40 {
+		t.Errorf("got revision %q, error %v", revision, err)
+	}
+	if git.BranchExists("no-such-branch") {
+		t.Errorf("branch no-such-branch exists")
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jiri
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// PluginPrefix is the prefix of the names of plugin binaries; the plugin
+	// "jiri-foo" is run by "jiri foo".
+	PluginPrefix = "jiri-"
+
+	// PluginInfoFlag is the flag that makes a plugin print its PluginInfo as
+	// JSON to stdout, and exit.
+	PluginInfoFlag = "--jiri-plugin-info"
+)
+
+// pluginInfoTimeout is how long a plugin may take to print its info.
+const pluginInfoTimeout = 5 * time.Second
+
+// PluginInfo describes a plugin, as printed by the plugin when run with
+// PluginInfoFlag, e.g.
+//
+//	{"name": "foo", "short": "Do foo", "min_jiri_version": "1.2", "needs_root": true}
+type PluginInfo struct {
+	// Name is the name of the plugin, without PluginPrefix.
+	Name string `json:"name"`
+	// Short is a short description of the plugin, shown by "jiri help".
+	Short string `json:"short"`
+	// MinJiriVersion is the minimum version of jiri the plugin works with, as
+	// dot-separated numbers.  Empty means any version.
+	MinJiriVersion string `json:"min_jiri_version,omitempty"`
+	// NeedsRoot indicates whether the plugin must be run in a jiri root.  It is
+	// advisory: jiri reports it, but doesn't check it when running the plugin.
+	NeedsRoot bool `json:"needs_root,omitempty"`
+}
+
+// ReadPluginInfo runs the plugin binary with PluginInfoFlag, and returns the
+// info it prints.
+func ReadPluginInfo(binary string) (*PluginInfo, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(binary, PluginInfoFlag)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, fmt.Errorf("%s %s failed: %v\n%s", binary, PluginInfoFlag, err, stderr.String())
+		}
+	case <-time.After(pluginInfoTimeout):
+		cmd.Process.Kill()
+		<-done
+		return nil, fmt.Errorf("%s %s timed out after %v", binary, PluginInfoFlag, pluginInfoTimeout)
+	}
+	info := &PluginInfo{}
+	if err := json.Unmarshal(stdout.Bytes(), info); err != nil {
+		return nil, fmt.Errorf("invalid output of %s %s: %v", binary, PluginInfoFlag, err)
+	}
+	if info.Name == "" {
+		return nil, fmt.Errorf("invalid output of %s %s: no name", binary, PluginInfoFlag)
+	}
+	return info, nil
+}
+
+// CheckJiriVersion returns an error if the plugin needs a more recent version
+// of jiri than the given one.  Versions that aren't dot-separated numbers,
+// e.g. those of development builds, are assumed to be recent enough.
+func (info *PluginInfo) CheckJiriVersion(version string) error {
+	if info.MinJiriVersion == "" {
+		return nil
+	}
+	have, ok := parseVersion(version)
+	if !ok {
+		return nil
+	}
+	min, ok := parseVersion(info.MinJiriVersion)
+	if !ok {
+		return fmt.Errorf("invalid minimum jiri version %q", info.MinJiriVersion)
+	}
+	for i := 0; i < len(have) || i < len(min); i++ {
+		var h, m int
+		if i < len(have) {
+			h = have[i]
+		}
+		if i < len(min) {
+			m = min[i]
+		}
+		if h != m {
+			if h < m {
+				return fmt.Errorf("needs jiri version %s or later, have %s", info.MinJiriVersion, version)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// parseVersion parses a version of dot-separated numbers, e.g. "1.2.3".
+func parseVersion(version string) ([]int, bool) {
+	var parts []int
+	for _, s := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// Plugin is a plugin binary found on PATH.
+type Plugin struct {
+	// Name is the name of the plugin, e.g. "foo" for "jiri-foo".
+	Name string
+	// Path is the absolute path of the binary.
+	Path string
+	// ShadowedBy is the path of the binary that runs instead of this one,
+	// because it comes first on PATH, or empty if this one runs.
+	ShadowedBy string
+}
+
+// FindPlugins returns the plugin binaries in the directories of the given
+// PATH, sorted by name, in PATH order for plugins with the same name.
+func FindPlugins(path string) []Plugin {
+	var plugins []Plugin
+	first := map[string]string{}
+	for _, dir := range filepath.SplitList(path) {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			name := info.Name()
+			if !strings.HasPrefix(name, PluginPrefix) || len(name) == len(PluginPrefix) || !isExecutable(info) {
+				continue
+			}
+			p := Plugin{Name: strings.TrimPrefix(name, PluginPrefix), Path: filepath.Join(dir, name)}
+			if file, ok := first[p.Name]; ok {
+				if file == p.Path {
+					// The same directory is on PATH more than once.
+					continue
+				}
+				p.ShadowedBy = file
+			} else {
+				first[p.Name] = p.Path
+			}
+			plugins = append(plugins, p)
+		}
+	}
+	sort.Stable(pluginsByName(plugins))
+	return plugins
+}
+
+func isExecutable(info os.FileInfo) bool {
+	mode := info.Mode()
+	return !mode.IsDir() && mode&0111 != 0
+}
+
+type pluginsByName []Plugin
+
+func (p pluginsByName) Len() int           { return len(p) }
+func (p pluginsByName) Less(i, j int) bool { return p[i].Name < p[j].Name }
+func (p pluginsByName) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// PluginCache caches the info of plugin binaries in a file, so that "jiri help"
+// doesn't need to run each plugin.  Entries are invalidated when the size or
+// modification time of the binary changes.  Plugins that fail to print their
+// info are cached as such.  Changes are only written to the file by Save.
+type PluginCache struct {
+	file    string
+	entries map[string]pluginCacheEntry
+	changed bool
+}
+
+type pluginCacheEntry struct {
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mod_time"`
+	Info    *PluginInfo `json:"info,omitempty"`
+	Err     string      `json:"error,omitempty"`
+}
+
+// PluginCacheFile returns the path to the user's plugin cache file,
+// $XDG_CACHE_HOME/jiri/plugins.json, where XDG_CACHE_HOME defaults to
+// $HOME/.cache.
+func PluginCacheFile() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(dir, "jiri", "plugins.json")
+}
+
+// LoadPluginCache loads the plugin cache from the given file.  A missing or
+// invalid file results in an empty cache.
+func LoadPluginCache(file string) *PluginCache {
+	c := &PluginCache{file: file, entries: map[string]pluginCacheEntry{}}
+	if data, err := ioutil.ReadFile(file); err == nil {
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			c.entries = map[string]pluginCacheEntry{}
+		}
+	}
+	return c
+}
+
+// Info returns the info of the given plugin binary, from the cache if the
+// binary hasn't changed since it was cached.  Otherwise it runs the binary,
+// and adds the result to the cache.
+func (c *PluginCache) Info(binary string) (*PluginInfo, error) {
+	stat, err := os.Stat(binary)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := c.entries[binary]; ok && e.Size == stat.Size() && e.ModTime.Equal(stat.ModTime()) {
+		if e.Err != "" {
+			return nil, fmt.Errorf("%s", e.Err)
+		}
+		return e.Info, nil
+	}
+	info, err := ReadPluginInfo(binary)
+	e := pluginCacheEntry{Size: stat.Size(), ModTime: stat.ModTime(), Info: info}
+	if err != nil {
+		e.Err = err.Error()
+	}
+	c.entries[binary] = e
+	c.changed = true
+	return info, err
+}
+
+// Save writes the cache to its file, if it changed since it was loaded.
+func (c *PluginCache) Save() error {
+	if !c.changed {
+		return nil
+	}
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.file), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(c.file, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	c.changed = false
+	return nil
+}
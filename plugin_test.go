@@ -0,0 +1,156 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jiri
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writePlugin(t *testing.T, dir, name, info string) string {
+	file := filepath.Join(dir, name)
+	script := "#!/bin/sh\necho \"$@\" >> " + file + ".log\necho '" + info + "'\n"
+	if err := ioutil.WriteFile(file, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// TestPluginInfo checks that plugin info is read from plugins, and cached.
+func TestPluginInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	foo := writePlugin(t, dir, "jiri-foo", `{"name": "foo", "short": "Do foo", "needs_root": true}`)
+	bad := writePlugin(t, dir, "jiri-bad", `not json`)
+
+	cache := LoadPluginCache(filepath.Join(dir, "cache", "plugins.json"))
+	want := &PluginInfo{Name: "foo", Short: "Do foo", NeedsRoot: true}
+	for i := 0; i < 2; i++ {
+		info, err := cache.Info(foo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(info, want) {
+			t.Errorf("got %#v, want %#v", info, want)
+		}
+		if _, err := cache.Info(bad); err == nil || !strings.Contains(err.Error(), "invalid output") {
+			t.Errorf("got error %v for invalid info", err)
+		}
+	}
+	// The cache file is only written by Save.
+	if _, err := os.Stat(filepath.Join(dir, "cache", "plugins.json")); !os.IsNotExist(err) {
+		t.Errorf("cache file was written before Save: %v", err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	// The plugins were only run once; later lookups, including those from a
+	// new cache, use the cache file.
+	cache = LoadPluginCache(filepath.Join(dir, "cache", "plugins.json"))
+	if info, err := cache.Info(foo); err != nil || !reflect.DeepEqual(info, want) {
+		t.Errorf("got %#v, %v from the cache file, want %#v", info, err, want)
+	}
+	for _, file := range []string{foo, bad} {
+		data, err := ioutil.ReadFile(file + ".log")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(data), PluginInfoFlag+"\n"; got != want {
+			t.Errorf("%s was run with %q, want %q once", file, got, want)
+		}
+	}
+}
+
+// TestPluginInfoUnwritableCache checks that plugin info is returned even if
+// the cache file can't be written.
+func TestPluginInfoUnwritableCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	foo := writePlugin(t, dir, "jiri-foo", `{"name": "foo", "short": "Do foo"}`)
+	// The cache directory is a file, so the cache file can't be created.
+	if err := ioutil.WriteFile(filepath.Join(dir, "cache"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := LoadPluginCache(filepath.Join(dir, "cache", "plugins.json"))
+	want := &PluginInfo{Name: "foo", Short: "Do foo"}
+	if info, err := cache.Info(foo); err != nil || !reflect.DeepEqual(info, want) {
+		t.Errorf("got %#v, %v, want %#v", info, err, want)
+	}
+	if err := cache.Save(); err == nil {
+		t.Errorf("Save succeeded, want an error")
+	}
+	// The info is still cached in memory.
+	if info, err := cache.Info(foo); err != nil || !reflect.DeepEqual(info, want) {
+		t.Errorf("got %#v, %v, want %#v", info, err, want)
+	}
+	data, err := ioutil.ReadFile(foo + ".log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), PluginInfoFlag+"\n"; got != want {
+		t.Errorf("%s was run with %q, want %q once", foo, got, want)
+	}
+}
+
+func TestCheckJiriVersion(t *testing.T) {
+	tests := []struct {
+		min, version string
+		ok           bool
+	}{
+		{"", "1.0", true},
+		{"1.2", "1.2", true},
+		{"1.2", "1.10", true},
+		{"1.2", "2", true},
+		{"1.2.1", "1.2", false},
+		{"2", "1.9.9", false},
+		{"2", "manual-build", true},
+	}
+	for _, test := range tests {
+		info := &PluginInfo{Name: "foo", MinJiriVersion: test.min}
+		if err := info.CheckJiriVersion(test.version); (err == nil) != test.ok {
+			t.Errorf("min %q, version %q: got error %v, want ok %v", test.min, test.version, err, test.ok)
+		}
+	}
+}
+
+func TestFindPlugins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir1, dir2 := filepath.Join(dir, "1"), filepath.Join(dir, "2")
+	for _, d := range []string{dir1, dir2} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writePlugin(t, dir1, "jiri-foo", "")
+	writePlugin(t, dir2, "jiri-foo", "")
+	writePlugin(t, dir2, "jiri-bar", "")
+	if err := ioutil.WriteFile(filepath.Join(dir2, "jiri-data"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := strings.Join([]string{dir1, dir2, dir1}, string(os.PathListSeparator))
+	want := []Plugin{
+		{Name: "bar", Path: filepath.Join(dir2, "jiri-bar")},
+		{Name: "foo", Path: filepath.Join(dir1, "jiri-foo")},
+		{Name: "foo", Path: filepath.Join(dir2, "jiri-foo"), ShadowedBy: filepath.Join(dir1, "jiri-foo")},
+	}
+	if got := FindPlugins(path); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri/gitutil"
+	"fuchsia.googlesource.com/jiri/jiritest"
+	"fuchsia.googlesource.com/jiri/project"
+	"fuchsia.googlesource.com/jiri/tool"
+)
+
+const helloMain = `package main
+
+import "fmt"
+
+func main() { fmt.Println("hello") }
+`
+
+// writeFiles creates the given files, relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestBuildTools checks that BuildTools builds tools in both module and
+// GOPATH mode, and that build failures name the failing tool.
+func TestBuildTools(t *testing.T) {
+	jirix, cleanup := jiritest.NewX(t)
+	defer cleanup()
+	var stderr bytes.Buffer
+	jirix.Context = tool.NewContext(tool.ContextOpts{Stderr: &stderr})
+
+	// A module-aware project, with a nested module.
+	modDir := filepath.Join(jirix.Root, "mod")
+	writeFiles(t, modDir, map[string]string{
+		"go.mod":                 "module example.com/mod\n",
+		"cmd/modtool/main.go":    helloMain,
+		"nested/go.mod":          "module example.com/mod/nested\n",
+		"nested/nestedtool/m.go": helloMain,
+		"broken/main.go":         "package main\n\nfunc main() { undefined() }\n",
+	})
+	// A GOPATH-style project.
+	gopathDir := filepath.Join(jirix.Root, "go", "src", "example.com", "gopath")
+	writeFiles(t, gopathDir, map[string]string{
+		"gopathtool/main.go": helloMain,
+	})
+	projects := project.Projects{}
+	for _, p := range []project.Project{
+		{Name: "mod", Path: modDir, Remote: "mod"},
+		{Name: "gopath", Path: gopathDir, Remote: "gopath"},
+	} {
+		projects[p.Key()] = p
+	}
+	tools := project.Tools{
+		"modtool":    {Name: "modtool", Package: "example.com/mod/cmd/modtool", Project: "mod"},
+		"nestedtool": {Name: "nestedtool", Package: "example.com/mod/nested/nestedtool", Project: "mod"},
+		"gopathtool": {Name: "gopathtool", Package: "example.com/gopath/gopathtool", Project: "gopath"},
+	}
+	outDir := filepath.Join(jirix.Root, "out")
+	if err := project.BuildTools(jirix, projects, tools, outDir); err != nil {
+		t.Fatalf("BuildTools failed: %v\n%s", err, stderr.String())
+	}
+	for name := range tools {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+			t.Errorf("tool %v was not built: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(jirix.RootMetaDir(), "go")); err != nil {
+		t.Errorf("module cache not created under the jiri root: %v", err)
+	}
+
+	// Check that a build failure identifies the tool.
+	tools["broken"] = project.Tool{Name: "broken", Package: "example.com/mod/broken", Project: "mod"}
+	err := project.BuildTools(jirix, projects, tools, outDir)
+	if err == nil {
+		t.Fatalf("BuildTools of broken tool succeeded")
+	}
+	if got, want := err.Error(), "tool build failed for broken"; got != want {
+		t.Errorf("got error %q, want %q", got, want)
+	}
+	if got := stderr.String(); !strings.Contains(got, `failed to build tool "broken"`) || !strings.Contains(got, "undefined") {
+		t.Errorf("unexpected stderr:\n%s", got)
+	}
+}
+
+// TestBuildToolRecipe checks that tools with a build command are built in a
+// sandbox, leaving the project untouched.
+func TestBuildToolRecipe(t *testing.T) {
+	jirix, cleanup := jiritest.NewX(t)
+	defer cleanup()
+	var stderr bytes.Buffer
+	jirix.Context = tool.NewContext(tool.ContextOpts{Stderr: &stderr})
+
+	projectDir := filepath.Join(jirix.Root, "scripts")
+	writeFiles(t, projectDir, map[string]string{
+		"hello.sh": "#!/bin/sh\necho hello\n",
+	})
+	p := project.Project{Name: "scripts", Path: projectDir, Remote: "scripts"}
+	projects := project.Projects{p.Key(): p}
+	tools := project.Tools{
+		"hello": {
+			Name:    "hello",
+			Build:   "mkdir out && cp hello.sh out/hello",
+			Output:  "out/hello",
+			Project: "scripts",
+		},
+	}
+	outDir := filepath.Join(jirix.Root, "out")
+	if err := project.BuildTools(jirix, projects, tools, outDir); err != nil {
+		t.Fatalf("BuildTools failed: %v\n%s", err, stderr.String())
+	}
+	fi, err := os.Stat(filepath.Join(outDir, "hello"))
+	if err != nil {
+		t.Fatalf("tool was not built: %v", err)
+	}
+	if fi.Mode().Perm()&0100 == 0 {
+		t.Errorf("tool is not executable: %v", fi.Mode())
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "out")); !os.IsNotExist(err) {
+		t.Errorf("build wrote to the project directory: %v", err)
+	}
+
+	// A build that doesn't produce its output fails.
+	tools["hello"] = project.Tool{Name: "hello", Build: "true", Output: "out/hello", Project: "scripts"}
+	if err := project.BuildTools(jirix, projects, tools, outDir); err == nil {
+		t.Errorf("BuildTools succeeded without producing output")
+	}
+	if got := stderr.String(); !strings.Contains(got, "build did not produce out/hello") {
+		t.Errorf("unexpected stderr:\n%s", got)
+	}
+
+	// Build and package are mutually exclusive.
+	if _, err := project.ManifestFromBytes([]byte(`<manifest><tools><tool name="x" package="p" build="make" output="x"/></tools></manifest>`)); err == nil {
+		t.Errorf("expected manifest with invalid tool to fail")
+	}
+}
+
+// TestToolCache checks that UpdateUniverse and RebuildTools only rebuild tools
+// that are out of date.
+func TestToolCache(t *testing.T) {
+	localProjects, fake, cleanup := setupUniverse(t)
+	defer cleanup()
+	p := localProjects[1]
+	logFile := filepath.Join(fake.X.Root, "builds.log")
+	if err := fake.AddTool(project.Tool{
+		Name:    "readme",
+		Build:   "echo built >> " + logFile + " && mkdir out && cp README out/readme",
+		Output:  "out/readme",
+		Project: p.Name,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	checkBuilds := func(want int) {
+		data, err := ioutil.ReadFile(logFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(data), "built\n"); got != want {
+			t.Errorf("got %d builds, want %d", got, want)
+		}
+	}
+
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	checkBuilds(1)
+	checkFile(t, filepath.Join(fake.X.BinDir(), "readme"), "initial readme")
+
+	// Nothing changed, so the tool must not be rebuilt.
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	checkBuilds(1)
+
+	// A new revision of the tool project triggers a rebuild.
+	writeReadme(t, fake.X, fake.Projects[p.Name], "new readme")
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	checkBuilds(2)
+	checkFile(t, filepath.Join(fake.X.BinDir(), "readme"), "new readme")
+
+	// The installed tool records what it was built from.
+	md, err := project.InstalledToolMetadata(fake.X, "readme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md == nil {
+		t.Fatalf("no metadata recorded for readme")
+	}
+	rev, err := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(p.Path)).CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"jiri.Project":      p.Name,
+		"jiri.Revision":     rev,
+		"jiri.SnapshotPath": ".jiri_manifest",
+	} {
+		if got := md.Lookup(key); got != want {
+			t.Errorf("got %v %q, want %q", key, got, want)
+		}
+	}
+
+	// The metadata isn't reported once the binary is replaced.
+	if err := ioutil.WriteFile(filepath.Join(fake.X.BinDir(), "readme"), []byte("replaced readme"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if md, err := project.InstalledToolMetadata(fake.X, "readme"); err != nil || md != nil {
+		t.Errorf("got metadata %v, error %v for a replaced binary, want none", md, err)
+	}
+
+	// RebuildTools skips up-to-date tools, unless forced.
+	projects, tools, err := project.LoadManifest(fake.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := project.RebuildTools(fake.X, projects, tools, false); err != nil {
+		t.Fatal(err)
+	}
+	checkBuilds(2)
+	if err := project.RebuildTools(fake.X, projects, tools, true); err != nil {
+		t.Fatal(err)
+	}
+	checkBuilds(3)
+
+	// Local modifications always trigger a rebuild.
+	if err := ioutil.WriteFile(filepath.Join(p.Path, "README"), []byte("local readme"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.RebuildTools(fake.X, projects, project.Tools{"readme": tools["readme"]}, false); err != nil {
+		t.Fatal(err)
+	}
+	checkBuilds(4)
+	checkFile(t, filepath.Join(fake.X.BinDir(), "readme"), "local readme")
+}
@@ -0,0 +1,236 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/runutil"
+)
+
+const (
+	// jiriHooksDir is the directory, relative to the project's .git directory,
+	// that holds the copy of the hooks specified in the manifest.  It is owned
+	// by jiri and is rewritten on every update.
+	jiriHooksDir = "hooks.jiri"
+	// localHooksDir is the directory, relative to the project's .git directory,
+	// that holds hooks installed by the developer.  Jiri never writes to it,
+	// except to move aside a pre-existing hook the first time a manifest hook
+	// with the same name is installed.
+	localHooksDir = "hooks.local"
+	// installedHooksFile records the names of the dispatchers jiri installed
+	// in .git/hooks, so that they can be removed once the corresponding hook
+	// disappears from the manifest.
+	installedHooksFile = ".jiri_installed"
+	// hookDispatcherMarker identifies dispatcher scripts written by jiri.
+	hookDispatcherMarker = "# jiri-hook-dispatcher"
+)
+
+// hookDispatcher is installed in .git/hooks for every hook named in the
+// manifest.  It runs the manifest hook followed by the local hook of the same
+// name, stopping at the first failure.  Hooks that read from stdin (e.g.
+// pre-push) see the same input in both invocations.
+var hookDispatcher = []byte(`#!/bin/sh
+` + hookDispatcherMarker + `
+#
+# This file is managed by jiri and is overwritten on every "jiri update".
+# Install local hooks in .git/` + localHooksDir + ` instead.
+
+name="$(basename "$0")"
+gitdir="$(cd "$(dirname "$0")/.." && pwd)"
+input="$(mktemp)" || exit 1
+trap 'rm -f "$input"' EXIT
+cat >"$input"
+for hook in "$gitdir/` + jiriHooksDir + `/$name" "$gitdir/` + localHooksDir + `/$name"; do
+  if [ -f "$hook" ] && [ -x "$hook" ]; then
+    "$hook" "$@" <"$input" || exit $?
+  fi
+done
+`)
+
+// installGitHooks installs the git hooks specified by the given project,
+// preserving any hooks that were not installed by jiri.  The manifest hooks
+// are copied into .git/hooks.jiri and a dispatcher is written to .git/hooks
+// for each of them.  Hooks that already exist in .git/hooks and were not
+// written by jiri are moved to .git/hooks.local, where the dispatcher picks
+// them up.  Dispatchers installed by a previous update for hooks that are no
+// longer in the manifest are removed, and the corresponding local hook, if
+// any, is moved back into place.
+func installGitHooks(jirix *jiri.X, project Project) error {
+	s := jirix.NewSeq()
+	gitDir := filepath.Join(project.Path, ".git")
+	hooksDir := filepath.Join(gitDir, "hooks")
+	jiriDir := filepath.Join(gitDir, jiriHooksDir)
+	localDir := filepath.Join(gitDir, localHooksDir)
+	recordFile := filepath.Join(hooksDir, installedHooksFile)
+
+	installed, err := readInstalledHooks(jirix, recordFile)
+	if err != nil {
+		return err
+	}
+	var names []string
+	if project.GitHooks != "" {
+		if names, err = hookNames(jirix, project.GitHooks); err != nil {
+			return err
+		}
+	}
+	if len(installed) == 0 && len(names) == 0 {
+		return nil
+	}
+
+	// Refresh the jiri-owned copy of the manifest hooks.
+	if err := s.RemoveAll(jiriDir).Done(); err != nil {
+		return err
+	}
+	if project.GitHooks != "" {
+		if err := copyHooks(jirix, project.GitHooks, jiriDir); err != nil {
+			return err
+		}
+	}
+
+	// Install the dispatchers, moving aside hooks we don't own.
+	if err := s.MkdirAll(hooksDir, 0755).Done(); err != nil {
+		return err
+	}
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+		dst := filepath.Join(hooksDir, name)
+		if !installed[name] {
+			if err := moveAsideHook(jirix, dst, filepath.Join(project.GitHooks, name), filepath.Join(localDir, name)); err != nil {
+				return err
+			}
+		}
+		if err := s.WriteFile(dst, hookDispatcher, 0755).Chmod(dst, 0755).Done(); err != nil {
+			return err
+		}
+	}
+
+	// Remove dispatchers for hooks that are no longer in the manifest.
+	for name := range installed {
+		if wanted[name] {
+			continue
+		}
+		dst := filepath.Join(hooksDir, name)
+		if err := s.Remove(dst).Done(); err != nil && !runutil.IsNotExist(err) {
+			return err
+		}
+		local := filepath.Join(localDir, name)
+		if isFile, err := s.IsFile(local); err != nil {
+			return err
+		} else if isFile {
+			if err := s.Rename(local, dst).Done(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		if err := s.RemoveAll(jiriDir).Done(); err != nil {
+			return err
+		}
+		if err := s.Remove(recordFile).Done(); err != nil && !runutil.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return s.WriteFile(recordFile, []byte(strings.Join(names, "\n")+"\n"), 0644).Done()
+}
+
+// readInstalledHooks returns the set of hook names recorded in the given file.
+// A missing file is treated as an empty record.
+func readInstalledHooks(jirix *jiri.X, file string) (map[string]bool, error) {
+	installed := map[string]bool{}
+	data, err := jirix.NewSeq().ReadFile(file)
+	if err != nil {
+		if runutil.IsNotExist(err) {
+			return installed, nil
+		}
+		return nil, err
+	}
+	for _, name := range strings.Split(string(data), "\n") {
+		if name = strings.TrimSpace(name); name != "" {
+			installed[name] = true
+		}
+	}
+	return installed, nil
+}
+
+// hookNames returns the sorted names of the regular files at the top level of
+// the given hooks directory.  Only those are considered hooks by git.
+func hookNames(jirix *jiri.X, dir string) ([]string, error) {
+	infos, err := jirix.NewSeq().ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() && info.Name() != installedHooksFile {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// copyHooks copies the src hooks directory into dst.  We walk the file
+// system, creating directories and copying files as we encounter them.
+func copyHooks(jirix *jiri.X, src, dst string) error {
+	s := jirix.NewSeq()
+	copyFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, relPath)
+		if info.IsDir() {
+			return s.MkdirAll(dstPath, 0755).Done()
+		}
+		data, err := s.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// The file *must* be executable to be picked up by git.
+		return s.WriteFile(dstPath, data, 0755).Chmod(dstPath, 0755).Done()
+	}
+	return filepath.Walk(src, copyFn)
+}
+
+// moveAsideHook moves the hook at path, if any, to local so that the
+// dispatcher can be installed in its place.  Dispatchers and verbatim copies
+// of the manifest hook, as written by older versions of jiri, are discarded
+// instead.  It is an error for both path and local to hold hooks not
+// installed by jiri.
+func moveAsideHook(jirix *jiri.X, path, manifestHook, local string) error {
+	s := jirix.NewSeq()
+	data, err := s.ReadFile(path)
+	if err != nil {
+		if runutil.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if bytes.Contains(data, []byte(hookDispatcherMarker)) {
+		return nil
+	}
+	if manifestData, err := s.ReadFile(manifestHook); err == nil && bytes.Equal(data, manifestData) {
+		return nil
+	}
+	if _, err := s.Stat(local); err == nil {
+		return fmt.Errorf("cannot install jiri hook %q: it was not installed by jiri and %q already exists; merge the two into %q and remove %q", path, local, local, path)
+	} else if !runutil.IsNotExist(err) {
+		return err
+	}
+	return s.MkdirAll(filepath.Dir(local), 0755).Rename(path, local).Done()
+}
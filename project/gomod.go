@@ -0,0 +1,104 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/runutil"
+)
+
+// goModule identifies a Go module on the local filesystem.
+type goModule struct {
+	// Dir is the module root, i.e. the directory containing go.mod.
+	Dir string
+	// Path is the module path declared in go.mod.
+	Path string
+}
+
+// goModulePath returns the module path declared in the given go.mod file, or
+// the empty string if the file doesn't exist or doesn't declare a module.
+func goModulePath(jirix *jiri.X, file string) (string, error) {
+	data, err := jirix.NewSeq().ReadFile(file)
+	if err != nil {
+		if runutil.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "module" {
+			continue
+		}
+		path := fields[1]
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			path = unquoted
+		}
+		return path, nil
+	}
+	return "", scanner.Err()
+}
+
+// findGoModule returns the module containing the package pkg in the given
+// project.  The project root must contain a go.mod file for the project to be
+// considered module-aware; nested modules under the project root are detected
+// as well, the innermost one containing pkg wins.  The returned bool is false
+// if pkg should be built in GOPATH mode.
+func findGoModule(jirix *jiri.X, project Project, pkg string) (goModule, bool, error) {
+	rootPath, err := goModulePath(jirix, filepath.Join(project.Path, "go.mod"))
+	if err != nil || rootPath == "" {
+		return goModule{}, false, err
+	}
+	if pkg != rootPath && !strings.HasPrefix(pkg, rootPath+"/") {
+		return goModule{}, false, nil
+	}
+	module := goModule{Dir: project.Path, Path: rootPath}
+	// Look for nested modules on the way from the project root to the
+	// package directory.
+	rel := strings.TrimPrefix(strings.TrimPrefix(pkg, rootPath), "/")
+	if rel == "" {
+		return module, true, nil
+	}
+	elems := strings.Split(rel, "/")
+	for i := range elems {
+		dir := filepath.Join(project.Path, filepath.Join(elems[:i+1]...))
+		path, err := goModulePath(jirix, filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return goModule{}, false, err
+		}
+		if path != "" && (pkg == path || strings.HasPrefix(pkg, path+"/")) {
+			module = goModule{Dir: dir, Path: path}
+		}
+	}
+	return module, true, nil
+}
+
+// goModFlag returns the -mod flag to use when building in the given module.
+// Vendored modules are built from their vendor directory; all others must
+// build without modifying go.mod.
+func goModFlag(jirix *jiri.X, module goModule) (string, error) {
+	isFile, err := jirix.NewSeq().IsFile(filepath.Join(module.Dir, "vendor", "modules.txt"))
+	if err != nil {
+		return "", err
+	}
+	if isFile {
+		return "-mod=vendor", nil
+	}
+	return "-mod=readonly", nil
+}
+
+// goCacheDir returns the directory holding the module and build caches used
+// for building tools.  They are kept in the cache directory of the jiri root,
+// so that tool builds neither depend on nor pollute the user's Go environment.
+func goCacheDir(jirix *jiri.X) string {
+	return filepath.Join(jirix.CacheDir(), "go")
+}
@@ -0,0 +1,125 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/gitutil"
+)
+
+// Commit describes a single commit of a project.
+type Commit struct {
+	Project  string    `json:"project"`
+	Revision string    `json:"revision"`
+	Author   string    `json:"author"`
+	Email    string    `json:"email"`
+	Time     time.Time `json:"time"`
+	// Description holds the full commit message.
+	Description string `json:"description"`
+}
+
+// Subject returns the first line of the commit message.
+func (c Commit) Subject() string {
+	return strings.SplitN(c.Description, "\n", 2)[0]
+}
+
+// LogOpts selects the commits returned by ProjectLog.
+type LogOpts struct {
+	// From and To hold the base and head revisions of projects, typically
+	// loaded from snapshots with LoadSnapshotFile.  The commits of a project
+	// are those reachable from its revision in To, or from its master branch
+	// if it isn't in To, and not reachable from its revision in From, if any.
+	From, To Projects
+	// Since and Until, if not zero, bound the commit times.
+	Since, Until time.Time
+	// Author, if not empty, is a regular expression that the author name or
+	// email must match, as for "git log --author".
+	Author string
+}
+
+// ProjectLog returns the commits of the given projects selected by opts,
+// merged into a single stream, newest first.
+func ProjectLog(jirix *jiri.X, projects Projects, opts LogOpts) ([]Commit, error) {
+	jirix.TimerPush("project log")
+	defer jirix.TimerPop()
+
+	var args []string
+	if !opts.Since.IsZero() {
+		args = append(args, "--since="+opts.Since.Format(time.RFC3339))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, "--until="+opts.Until.Format(time.RFC3339))
+	}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author)
+	}
+	var keys ProjectKeys
+	for key := range projects {
+		keys = append(keys, key)
+	}
+	sort.Sort(keys)
+	var commits []Commit
+	for _, key := range keys {
+		p := projects[key]
+		head := "master"
+		if to, ok := opts.To[key]; ok && to.Revision != "" {
+			head = to.Revision
+		}
+		revRange := head
+		if from, ok := opts.From[key]; ok && from.Revision != "" {
+			revRange = from.Revision + ".." + head
+		}
+		projectCommits, err := logProject(jirix, p, append([]string{revRange}, args...)...)
+		if err != nil {
+			return nil, fmt.Errorf("error reading log of project %q: %v", p.Name, err)
+		}
+		commits = append(commits, projectCommits...)
+	}
+	// Projects are visited in order, and git log lists the commits of each
+	// newest first, so a stable sort keeps the output deterministic.
+	sort.Stable(commitsByTime(commits))
+	return commits, nil
+}
+
+// logProject returns the commits of the given project selected by the given
+// "git log" arguments.
+func logProject(jirix *jiri.X, project Project, args ...string) ([]Commit, error) {
+	git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(project.Path))
+	entries, err := git.LogEntries([]string{"%H", "%an", "%ae", "%ct", "%B"}, args...)
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	for _, entry := range entries {
+		if got, want := len(entry), 5; got != want {
+			return nil, fmt.Errorf("unexpected length of %v: got %v, want %v", entry, got, want)
+		}
+		seconds, err := strconv.ParseInt(entry[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid commit time %q: %v", entry[3], err)
+		}
+		commits = append(commits, Commit{
+			Project:     project.Name,
+			Revision:    entry[0],
+			Author:      entry[1],
+			Email:       entry[2],
+			Time:        time.Unix(seconds, 0).UTC(),
+			Description: strings.TrimSpace(entry[4]),
+		})
+	}
+	return commits, nil
+}
+
+type commitsByTime []Commit
+
+func (cs commitsByTime) Len() int           { return len(cs) }
+func (cs commitsByTime) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
+func (cs commitsByTime) Less(i, j int) bool { return cs[i].Time.After(cs[j].Time) }
@@ -0,0 +1,102 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"testing"
+	"time"
+
+	"fuchsia.googlesource.com/jiri/gitutil"
+	"fuchsia.googlesource.com/jiri/project"
+)
+
+// TestProjectLog checks that ProjectLog merges the commits of several projects
+// into a single stream, and applies the snapshot, time and author bounds.
+func TestProjectLog(t *testing.T) {
+	localProjects, fake, cleanup := setupUniverse(t)
+	defer cleanup()
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	p1, p2 := localProjects[1], localProjects[2]
+	base := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := func(p project.Project, author string, when time.Time, msg string) string {
+		date := when.Format(time.RFC3339)
+		s := fake.X.NewSeq().Env(map[string]string{"GIT_AUTHOR_NAME": author, "GIT_AUTHOR_EMAIL": author + "@example.com"})
+		git := gitutil.New(s, gitutil.RootDirOpt(p.Path), gitutil.AuthorDateOpt(date), gitutil.CommitterDateOpt(date))
+		if err := git.CommitWithMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+		rev, err := git.CurrentRevision()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rev
+	}
+	first := commit(p1, "alice", base.Add(1*time.Hour), "first\n\nwith a body")
+	second := commit(p2, "bob", base.Add(2*time.Hour), "second")
+	third := commit(p1, "alice", base.Add(3*time.Hour), "third")
+	projects := project.Projects{p1.Key(): p1, p2.Key(): p2}
+
+	revisions := func(commits []project.Commit) []string {
+		var revs []string
+		for _, c := range commits {
+			revs = append(revs, c.Revision)
+		}
+		return revs
+	}
+	tests := []struct {
+		opts project.LogOpts
+		want []string
+	}{
+		{project.LogOpts{Since: base}, []string{third, second, first}},
+		{project.LogOpts{Since: base, Until: base.Add(150 * time.Minute)}, []string{second, first}},
+		{project.LogOpts{Since: base, Author: "bob"}, []string{second}},
+		{
+			project.LogOpts{
+				Since: base,
+				From:  project.Projects{p1.Key(): project.Project{Revision: first}},
+				To:    project.Projects{p2.Key(): project.Project{Revision: second + "^"}},
+			},
+			[]string{third},
+		},
+	}
+	for _, test := range tests {
+		commits, err := project.ProjectLog(fake.X, projects, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := revisions(commits)
+		if len(got) != len(test.want) {
+			t.Errorf("%+v: got %v, want %v", test.opts, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%+v: got %v, want %v", test.opts, got, test.want)
+				break
+			}
+		}
+	}
+
+	commits, err := project.ProjectLog(fake.X, projects, project.LogOpts{Since: base, Until: base.Add(90 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := project.Commit{
+		Project:     p1.Name,
+		Revision:    first,
+		Author:      "alice",
+		Email:       "alice@example.com",
+		Time:        base.Add(1 * time.Hour),
+		Description: "first\n\nwith a body",
+	}
+	if len(commits) != 1 || commits[0] != want {
+		t.Errorf("got %#v, want %#v", commits, want)
+	}
+	if got, want := commits[0].Subject(), "first"; got != want {
+		t.Errorf("got subject %q, want %q", got, want)
+	}
+}
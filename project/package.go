@@ -0,0 +1,545 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/collect"
+	"fuchsia.googlesource.com/jiri/runutil"
+)
+
+const (
+	// PackageFormatTarGz identifies gzip-compressed tar archives.
+	PackageFormatTarGz = "tar.gz"
+	// PackageFormatZip identifies zip archives.
+	PackageFormatZip = "zip"
+
+	// packageStampFile is the name of the file, relative to the package
+	// directory, that records the package a directory was extracted from.
+	packageStampFile = ".jiri_package"
+	// packagesFile is the name of the file, relative to the jiri root
+	// metadata directory, that records all packages installed by jiri.
+	packagesFile = "packages"
+)
+
+// Packages maps package names to their detailed description.
+type Packages map[string]Package
+
+// toSlice returns a slice of Packages in the Packages map, sorted by name.
+func (ps Packages) toSlice() []Package {
+	var pSlice []Package
+	for _, p := range ps {
+		pSlice = append(pSlice, p)
+	}
+	sort.Sort(packagesByName(pSlice))
+	return pSlice
+}
+
+type packagesByName []Package
+
+func (ps packagesByName) Len() int           { return len(ps) }
+func (ps packagesByName) Less(i, j int) bool { return ps[i].Name < ps[j].Name }
+func (ps packagesByName) Swap(i, j int)      { ps[i], ps[j] = ps[j], ps[i] }
+
+// Package represents a prebuilt archive that is downloaded, verified and
+// extracted into the jiri root, as opposed to a project, which is a git
+// repository.
+type Package struct {
+	// Name is the package name.
+	Name string `xml:"name,attr,omitempty"`
+	// Path is the directory the package is extracted to.
+	Path string `xml:"path,attr,omitempty"`
+	// URL is the location of the archive.  Supported schemes are "http",
+	// "https" and "file".
+	URL string `xml:"url,attr,omitempty"`
+	// SHA256 is the hex-encoded SHA-256 digest of the archive.
+	SHA256 string `xml:"sha256,attr,omitempty"`
+	// Format is the archive format, either "tar.gz" or "zip".  If not set,
+	// the format is derived from the extension of the URL.
+	Format  string   `xml:"format,attr,omitempty"`
+	XMLName struct{} `xml:"package"`
+}
+
+// formatFromURL returns the archive format implied by the extension of the
+// given URL, or the empty string if it can't be determined.
+func formatFromURL(u string) string {
+	if parsed, err := url.Parse(u); err == nil {
+		u = parsed.Path
+	}
+	switch {
+	case strings.HasSuffix(u, ".tar.gz"), strings.HasSuffix(u, ".tgz"):
+		return PackageFormatTarGz
+	case strings.HasSuffix(u, ".zip"):
+		return PackageFormatZip
+	}
+	return ""
+}
+
+func (p *Package) fillDefaults() error {
+	if p.Format == "" {
+		p.Format = formatFromURL(p.URL)
+	}
+	p.SHA256 = strings.ToLower(p.SHA256)
+	return p.validate()
+}
+
+func (p *Package) unfillDefaults() error {
+	if p.Format == formatFromURL(p.URL) {
+		p.Format = ""
+	}
+	return nil
+}
+
+func (p *Package) validate() error {
+	switch {
+	case p.Name == "":
+		return fmt.Errorf("bad package: name must be specified: %+v", *p)
+	case p.Path == "":
+		return fmt.Errorf("bad package %q: path must be specified", p.Name)
+	case p.URL == "":
+		return fmt.Errorf("bad package %q: url must be specified", p.Name)
+	}
+	if sum, err := hex.DecodeString(p.SHA256); err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("bad package %q: sha256 must be a hex-encoded SHA-256 digest, got %q", p.Name, p.SHA256)
+	}
+	if p.Format != PackageFormatTarGz && p.Format != PackageFormatZip {
+		return fmt.Errorf("bad package %q: unsupported format %q, must be %q or %q", p.Name, p.Format, PackageFormatTarGz, PackageFormatZip)
+	}
+	return nil
+}
+
+// absolutizePaths makes all relative paths absolute by prepending basepath.
+func (p *Package) absolutizePaths(basepath string) {
+	if p.Path != "" && !filepath.IsAbs(p.Path) {
+		p.Path = filepath.Join(basepath, p.Path)
+	}
+}
+
+// relativizePaths makes all absolute paths relative to basepath.
+func (p *Package) relativizePaths(basepath string) error {
+	if filepath.IsAbs(p.Path) {
+		relPath, err := filepath.Rel(basepath, p.Path)
+		if err != nil {
+			return err
+		}
+		p.Path = relPath
+	}
+	return nil
+}
+
+// LocalPackages returns the packages that jiri has installed in the local
+// filesystem.
+func LocalPackages(jirix *jiri.X) (Packages, error) {
+	file := filepath.Join(jirix.RootMetaDir(), packagesFile)
+	if _, err := jirix.NewSeq().Stat(file); err != nil {
+		if runutil.IsNotExist(err) {
+			return Packages{}, nil
+		}
+		return nil, err
+	}
+	m, err := ManifestFromFile(jirix, file)
+	if err != nil {
+		return nil, err
+	}
+	packages := Packages{}
+	for _, pkg := range m.Packages {
+		pkg.absolutizePaths(jirix.Root)
+		packages[pkg.Name] = pkg
+	}
+	return packages, nil
+}
+
+// writeLocalPackages records the given packages as installed.
+func writeLocalPackages(jirix *jiri.X, packages Packages) error {
+	m := Manifest{Packages: packages.toSlice()}
+	return m.ToFile(jirix, filepath.Join(jirix.RootMetaDir(), packagesFile))
+}
+
+// checkPackagePaths returns an error if the path of one of the given packages
+// is the jiri root, is outside of it, or overlaps the root metadata directory
+// or one of the given projects.  Packages are installed and removed by
+// removing their whole directory, so such a package would remove the root, a
+// checkout or files that jiri doesn't manage.
+func checkPackagePaths(jirix *jiri.X, packages Packages, projects Projects) error {
+	root := filepath.Clean(jirix.Root)
+	metaDir := jirix.RootMetaDir()
+	for _, pkg := range packages.toSlice() {
+		path := filepath.Clean(pkg.Path)
+		switch {
+		case path == root:
+			return fmt.Errorf("bad package %q: path %q is the jiri root", pkg.Name, path)
+		case !isUnder(root, path):
+			return fmt.Errorf("bad package %q: path %q is outside of the jiri root %q", pkg.Name, path, root)
+		case isUnder(metaDir, path):
+			return fmt.Errorf("bad package %q: path %q is in the root metadata directory %q", pkg.Name, path, metaDir)
+		}
+		for _, p := range projects {
+			projectPath := filepath.Clean(p.Path)
+			if isUnder(projectPath, path) || isUnder(path, projectPath) {
+				return fmt.Errorf("bad package %q: path %q overlaps project %q in %q", pkg.Name, path, p.Name, projectPath)
+			}
+		}
+	}
+	return nil
+}
+
+// updatePackages installs the remote packages that are missing or out of date
+// locally.  Local packages that are no longer in the manifest are removed if
+// gc is set; otherwise the user is told about them.  Nothing is installed or
+// removed unless the paths of all packages are valid for the given projects,
+// as checked by checkPackagePaths.
+func updatePackages(jirix *jiri.X, localPackages, remotePackages Packages, projects Projects, gc bool) error {
+	jirix.TimerPush("update packages")
+	defer jirix.TimerPop()
+	for _, packages := range []Packages{remotePackages, localPackages} {
+		if err := checkPackagePaths(jirix, packages, projects); err != nil {
+			return err
+		}
+	}
+	s := jirix.NewSeq()
+	installed := Packages{}
+	for i, pkg := range remotePackages.toSlice() {
+		if err := checkCancelled(jirix, "after updating %d of %d packages", i, len(remotePackages)); err != nil {
+			return err
+		}
+		current, err := packageIsCurrent(jirix, pkg)
+		if err != nil {
+			return err
+		}
+		if !current {
+			if err := installPackage(jirix, pkg); err != nil {
+				return err
+			}
+		}
+		installed[pkg.Name] = pkg
+		// Remove the old copy of a package that moved.
+		if local, ok := localPackages[pkg.Name]; ok && local.Path != pkg.Path {
+			if err := s.RemoveAll(local.Path).Done(); err != nil {
+				return err
+			}
+		}
+	}
+	for _, pkg := range localPackages.toSlice() {
+		if _, ok := remotePackages[pkg.Name]; ok {
+			continue
+		}
+		if gc {
+			// Packages never contain local work, so they can always be removed.
+			if err := s.RemoveAll(pkg.Path).Done(); err != nil {
+				return err
+			}
+			continue
+		}
+		lines := []string{
+			fmt.Sprintf("NOTE: package %v was not found in the project manifest", pkg.Name),
+			`invoke "jiri update -gc" to remove all such local packages`,
+		}
+		s.Verbose(true).Output(lines)
+		installed[pkg.Name] = pkg
+	}
+	return writeLocalPackages(jirix, installed)
+}
+
+// packageIsCurrent returns true iff the directory at pkg.Path holds the
+// contents of pkg, as recorded by its stamp file.
+func packageIsCurrent(jirix *jiri.X, pkg Package) (bool, error) {
+	data, err := jirix.NewSeq().ReadFile(filepath.Join(pkg.Path, packageStampFile))
+	if err != nil {
+		if runutil.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	var stamp Package
+	if err := xml.Unmarshal(data, &stamp); err != nil {
+		// A corrupt stamp is treated as a stale package.
+		return false, nil
+	}
+	return stamp.SHA256 == pkg.SHA256 && stamp.URL == pkg.URL && stamp.Format == pkg.Format, nil
+}
+
+// installPackage downloads, verifies and extracts the given package.  The
+// archive is extracted into a temporary directory next to pkg.Path, which then
+// replaces any existing contents of pkg.Path.
+func installPackage(jirix *jiri.X, pkg Package) (e error) {
+	s := jirix.NewSeq()
+	s.Verbose(true).Output([]string{fmt.Sprintf("installing package %q in %q", pkg.Name, pkg.Path)})
+	parent := filepath.Dir(pkg.Path)
+	if err := s.MkdirAll(parent, 0755).Done(); err != nil {
+		return err
+	}
+
+	archive, err := s.TempFile(parent, ".jiri-package-download-")
+	if err != nil {
+		return err
+	}
+	defer collect.Error(func() error { return s.RemoveAll(archive.Name()).Done() }, &e)
+	defer collect.Error(archive.Close, &e)
+	if err := downloadPackage(jirix, pkg, archive); err != nil {
+		return fmt.Errorf("package %q: %v", pkg.Name, err)
+	}
+
+	tmpDir, err := s.TempDir(parent, ".jiri-package-")
+	if err != nil {
+		return err
+	}
+	defer collect.Error(func() error { return s.RemoveAll(tmpDir).Done() }, &e)
+	switch pkg.Format {
+	case PackageFormatTarGz:
+		err = extractTarGz(archive, tmpDir)
+	case PackageFormatZip:
+		err = extractZip(archive, tmpDir)
+	}
+	if err != nil {
+		return fmt.Errorf("package %q: extracting %v failed: %v", pkg.Name, pkg.URL, err)
+	}
+	stamp, err := xml.Marshal(pkg)
+	if err != nil {
+		return err
+	}
+	if err := s.WriteFile(filepath.Join(tmpDir, packageStampFile), stamp, 0644).Chmod(tmpDir, 0755).Done(); err != nil {
+		return err
+	}
+	return s.RemoveAll(pkg.Path).Rename(tmpDir, pkg.Path).Done()
+}
+
+// downloadPackage writes the archive for pkg to w and verifies its checksum.
+func downloadPackage(jirix *jiri.X, pkg Package, w io.WriteSeeker) error {
+	var r io.ReadCloser
+	u, err := url.Parse(pkg.URL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if err := jirix.RequireOnline(fmt.Sprintf("downloading package %q", pkg.Name)); err != nil {
+			return err
+		}
+		req, err := http.NewRequest("GET", pkg.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(jirix.Ctx()))
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("GET %v: %v", pkg.URL, resp.Status)
+		}
+		r = resp.Body
+	case "file":
+		if r, err = jirix.NewSeq().Open(u.Path); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported url scheme %q in %v", u.Scheme, pkg.URL)
+	}
+	defer r.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), r); err != nil {
+		return fmt.Errorf("download of %v failed: %v", pkg.URL, err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != pkg.SHA256 {
+		return fmt.Errorf("checksum mismatch for %v: got sha256 %v, want %v", pkg.URL, got, pkg.SHA256)
+	}
+	_, err = w.Seek(0, io.SeekStart)
+	return err
+}
+
+// extractPath returns the path that the archive entry name is extracted to
+// under dir, rejecting entries that would escape dir.
+func extractPath(dir, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if !isUnder(dir, path) {
+		return "", fmt.Errorf("invalid archive entry %q", name)
+	}
+	return path, nil
+}
+
+// checkLinkname returns an error if the target of the symlink extracted to
+// path under dir is absolute or resolves outside dir.
+func checkLinkname(dir, path, name, linkname string) error {
+	if filepath.IsAbs(linkname) || !isUnder(dir, filepath.Join(filepath.Dir(path), filepath.FromSlash(linkname))) {
+		return fmt.Errorf("invalid target %q of symlink archive entry %q", linkname, name)
+	}
+	return nil
+}
+
+// checkExtractPath returns an error if the archive entry name, extracted to
+// path, would be written outside realDir, the resolved directory the archive is
+// extracted to.  The text of path is under realDir, but symlinks extracted
+// earlier from the same archive may redirect its parent directories, or path
+// itself, elsewhere.
+func checkExtractPath(realDir, path, name string) error {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("invalid archive entry %q: overwrites a symlink", name)
+	}
+	// Resolve the deepest parent directory that already exists.
+	parent := filepath.Dir(path)
+	for {
+		real, err := filepath.EvalSymlinks(parent)
+		if err == nil {
+			if !isUnder(realDir, real) {
+				return fmt.Errorf("invalid archive entry %q: extracted through a symlink to %q", name, real)
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent = filepath.Dir(parent)
+	}
+}
+
+// checkSymlinks returns an error if a symlink under realDir, the resolved
+// directory an archive was extracted to, resolves outside of it.  Chains of
+// symlinks can do so even if the target of each is under realDir as text.
+func checkSymlinks(realDir string) error {
+	return filepath.Walk(realDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !isUnder(realDir, real) {
+			rel, _ := filepath.Rel(realDir, path)
+			return fmt.Errorf("symlink archive entry %q resolves to %q, outside of the package", filepath.ToSlash(rel), real)
+		}
+		return nil
+	})
+}
+
+// isUnder returns true iff path is dir or is under dir.
+func isUnder(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// writeExtractedFile creates the file at path with the given mode and copies r
+// into it.
+func writeExtractedFile(path string, mode os.FileMode, r io.Reader) (e error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer collect.Error(f.Close, &e)
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func extractTarGz(r io.Reader, dir string) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return checkSymlinks(realDir)
+		}
+		if err != nil {
+			return err
+		}
+		path, err := extractPath(realDir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkExtractPath(realDir, path, hdr.Name); err != nil {
+			return err
+		}
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, mode|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := writeExtractedFile(path, mode, tr); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := checkLinkname(realDir, path, hdr.Name, hdr.Linkname); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported type %q for archive entry %q", hdr.Typeflag, hdr.Name)
+		}
+	}
+}
+
+func extractZip(f *os.File, dir string) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return err
+	}
+	for _, file := range zr.File {
+		path, err := extractPath(realDir, file.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkExtractPath(realDir, path, file.Name); err != nil {
+			return err
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, file.Mode().Perm()|0700); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = writeExtractedFile(path, file.Mode().Perm(), rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
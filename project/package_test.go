@@ -0,0 +1,349 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri/jiritest"
+	"fuchsia.googlesource.com/jiri/project"
+)
+
+// makeTarGz returns a tar.gz archive holding the given files.
+func makeTarGz(t *testing.T, files map[string]string) []byte {
+	return makeTarGzWithLinks(t, files, nil)
+}
+
+// makeTarGzWithLinks returns a tar.gz archive holding the given files, followed
+// by the given symlinks, which map names to targets.
+func makeTarGzWithLinks(t *testing.T, files, links map[string]string) []byte {
+	var entries []tarEntry
+	for name, contents := range files {
+		entries = append(entries, tarEntry{name: name, contents: contents})
+	}
+	for name, target := range links {
+		entries = append(entries, tarEntry{name: name, link: target})
+	}
+	return makeTarGzEntries(t, entries)
+}
+
+// tarEntry describes an entry of a tar archive: a symlink to link if link is
+// set, and otherwise a file holding contents.
+type tarEntry struct {
+	name, contents, link string
+}
+
+// makeTarGzEntries returns a tar.gz archive holding the given entries, in
+// order.
+func makeTarGzEntries(t *testing.T, entries []tarEntry) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0755, Size: int64(len(e.contents)), Typeflag: tar.TypeReg}
+		if e.link != "" {
+			hdr = &tar.Header{Name: e.name, Mode: 0777, Linkname: e.link, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// makeZip returns a zip archive holding the given files.
+func makeZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func checkFile(t *testing.T, path, want string) {
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("unexpected content of %v: got %q, want %q", path, got, want)
+	}
+}
+
+// setRemotePackages replaces the packages in the remote manifest.
+func setRemotePackages(t *testing.T, fake *jiritest.FakeJiriRoot, packages ...project.Package) {
+	m, err := fake.ReadRemoteManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Packages = packages
+	if err := fake.WriteRemoteManifest(m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUpdateUniversePackages checks that UpdateUniverse downloads, verifies and
+// extracts packages, records them in snapshots, and garbage collects them.
+func TestUpdateUniversePackages(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	archives := map[string][]byte{
+		"/v1.tar.gz": makeTarGz(t, map[string]string{"bin/tool": "v1"}),
+		"/v2.tar.gz": makeTarGz(t, map[string]string{"bin/tool": "v2"}),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := archives[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	zipFile := filepath.Join(fake.X.Root, "sdk.zip")
+	zipData := makeZip(t, map[string]string{"sdk/README": "sdk"})
+	if err := ioutil.WriteFile(zipFile, zipData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	toolchain := project.Package{
+		Name:   "toolchain",
+		Path:   "prebuilt/toolchain",
+		URL:    server.URL + "/v1.tar.gz",
+		SHA256: sha256Hex(archives["/v1.tar.gz"]),
+	}
+	sdk := project.Package{
+		Name:   "sdk",
+		Path:   "prebuilt/sdk",
+		URL:    "file://" + zipFile,
+		SHA256: sha256Hex(zipData),
+		Format: "zip",
+	}
+	setRemotePackages(t, fake, toolchain, sdk)
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	toolchainDir := filepath.Join(fake.X.Root, "prebuilt", "toolchain")
+	sdkDir := filepath.Join(fake.X.Root, "prebuilt", "sdk")
+	checkFile(t, filepath.Join(toolchainDir, "bin", "tool"), "v1")
+	checkFile(t, filepath.Join(sdkDir, "sdk", "README"), "sdk")
+
+	// Packages must be recorded in snapshots.
+	snapshot := filepath.Join(fake.X.Root, "snapshot")
+	if err := project.CreateSnapshot(fake.X, snapshot, ""); err != nil {
+		t.Fatal(err)
+	}
+	m, err := project.ManifestFromFile(fake.X, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(m.Packages), 2; got != want {
+		t.Fatalf("got %d packages in snapshot, want %d", got, want)
+	}
+	if got, want := m.Packages[1].Path, toolchain.Path; got != want {
+		t.Errorf("got package path %q in snapshot, want %q", got, want)
+	}
+
+	// A bad checksum must fail the update and leave the old version in place.
+	toolchain.URL = server.URL + "/v2.tar.gz"
+	setRemotePackages(t, fake, toolchain, sdk)
+	if err := fake.UpdateUniverse(false); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+	checkFile(t, filepath.Join(toolchainDir, "bin", "tool"), "v1")
+
+	// Updating the package replaces its contents.
+	toolchain.SHA256 = sha256Hex(archives["/v2.tar.gz"])
+	setRemotePackages(t, fake, toolchain, sdk)
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, filepath.Join(toolchainDir, "bin", "tool"), "v2")
+
+	// Packages removed from the manifest are only deleted with gc.
+	setRemotePackages(t, fake, toolchain)
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sdkDir); err != nil {
+		t.Errorf("package deleted without gc: %v", err)
+	}
+	if err := fake.UpdateUniverse(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sdkDir); !os.IsNotExist(err) {
+		t.Errorf("expected %v to be deleted, got %v", sdkDir, err)
+	}
+	checkFile(t, filepath.Join(toolchainDir, "bin", "tool"), "v2")
+}
+
+// TestUpdateUniversePackageSymlinks checks that symlinks in package archives
+// are extracted, unless they point outside the package.
+func TestUpdateUniversePackageSymlinks(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	tests := []struct {
+		links map[string]string
+		err   string
+	}{
+		{map[string]string{"bin/tool-link": "tool", "lib/tool": "../bin/tool"}, ""},
+		{map[string]string{"bin/passwd": "/etc/passwd"}, `invalid target "/etc/passwd" of symlink archive entry "bin/passwd"`},
+		{map[string]string{"bin/escape": "../../outside"}, `invalid target "../../outside" of symlink archive entry "bin/escape"`},
+	}
+	for _, test := range tests {
+		data := makeTarGzWithLinks(t, map[string]string{"bin/tool": "tool"}, test.links)
+		file := filepath.Join(fake.X.Root, "links.tar.gz")
+		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		setRemotePackages(t, fake, project.Package{
+			Name:   "links",
+			Path:   "prebuilt/links",
+			URL:    "file://" + file,
+			SHA256: sha256Hex(data),
+		})
+		err := fake.UpdateUniverse(false)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v for symlinks %v, want %q", err, test.links, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := filepath.Join(fake.X.Root, "prebuilt", "links")
+		for name := range test.links {
+			checkFile(t, filepath.Join(dir, name), "tool")
+		}
+	}
+}
+
+// TestUpdateUniversePackageSymlinkChains checks that chains of symlinks, each
+// of which points inside the package as text, can't be used to resolve or
+// write outside the package.
+func TestUpdateUniversePackageSymlinkChains(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	// "x/y/d" points at the package directory, so "x/y/d/l" points at its
+	// parent.
+	chain := []tarEntry{{name: "x/y/d", link: "../.."}, {name: "x/y/d/l", link: ".."}}
+	tests := []struct {
+		entries []tarEntry
+		err     string
+	}{
+		{chain, `symlink archive entry "l" resolves to`},
+		{append(chain, tarEntry{name: "x/y/d/l/escaped", contents: "escaped"}), `invalid archive entry "x/y/d/l/escaped": extracted through a symlink`},
+		{[]tarEntry{{name: "bin/l", link: "tool"}, {name: "bin/l", contents: "escaped"}}, `invalid archive entry "bin/l": overwrites a symlink`},
+	}
+	for _, test := range tests {
+		data := makeTarGzEntries(t, test.entries)
+		file := filepath.Join(fake.X.Root, "chain.tar.gz")
+		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		setRemotePackages(t, fake, project.Package{
+			Name:   "chain",
+			Path:   "prebuilt/chain",
+			URL:    "file://" + file,
+			SHA256: sha256Hex(data),
+		})
+		if err := fake.UpdateUniverse(false); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("got error %v for entries %v, want %q", err, test.entries, test.err)
+		}
+		if _, err := os.Lstat(filepath.Join(fake.X.Root, "prebuilt", "escaped")); !os.IsNotExist(err) {
+			t.Fatalf("a file was written outside of the package: %v", err)
+		}
+		if _, err := os.Lstat(filepath.Join(fake.X.Root, "prebuilt", "chain")); !os.IsNotExist(err) {
+			t.Errorf("package %v was installed: %v", test.entries, err)
+		}
+	}
+}
+
+// TestUpdateUniversePackagePaths checks that packages whose paths are the jiri
+// root, are outside of it, or overlap its metadata or a project are rejected
+// before anything is removed.
+func TestUpdateUniversePackagePaths(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	if err := fake.CreateRemoteProject("p"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.AddProject(project.Project{Name: "p", Path: "src/p", Remote: fake.Projects["p"]}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	data := makeTarGz(t, map[string]string{"bin/tool": "tool"})
+	file := filepath.Join(fake.X.Root, "tool.tar.gz")
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct{ path, err string }{
+		{".", "is the jiri root"},
+		{"..", "is outside of the jiri root"},
+		{".jiri_root/bin", "is in the root metadata directory"},
+		{"src/p/prebuilt", `overlaps project "p"`},
+		{"src", `overlaps project "p"`},
+	}
+	for _, test := range tests {
+		setRemotePackages(t, fake, project.Package{
+			Name:   "tool",
+			Path:   test.path,
+			URL:    "file://" + file,
+			SHA256: sha256Hex(data),
+		})
+		if err := fake.UpdateUniverse(true); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("path %q: got error %v, want %q", test.path, err, test.err)
+		}
+		for _, dir := range []string{fake.X.RootMetaDir(), filepath.Join(fake.X.Root, "src", "p", ".git")} {
+			if _, err := os.Stat(dir); err != nil {
+				t.Fatalf("path %q: %v", test.path, err)
+			}
+		}
+	}
+}
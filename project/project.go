@@ -13,6 +13,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -21,6 +22,7 @@ import (
 	"fuchsia.googlesource.com/jiri/collect"
 	"fuchsia.googlesource.com/jiri/gitutil"
 	"fuchsia.googlesource.com/jiri/googlesource"
+	"fuchsia.googlesource.com/jiri/metadata"
 	"fuchsia.googlesource.com/jiri/runutil"
 )
 
@@ -43,6 +45,7 @@ type Manifest struct {
 	Imports      []Import      `xml:"imports>import"`
 	LocalImports []LocalImport `xml:"imports>localimport"`
 	Projects     []Project     `xml:"projects>project"`
+	Packages     []Package     `xml:"packages>package"`
 	Tools        []Tool        `xml:"tools>tool"`
 	// SnapshotPath is the relative path to the snapshot file from JIRI_ROOT.
 	// It is only set when creating a snapshot.
@@ -87,12 +90,14 @@ var (
 	newlineBytes       = []byte("\n")
 	emptyImportsBytes  = []byte("\n  <imports></imports>\n")
 	emptyProjectsBytes = []byte("\n  <projects></projects>\n")
+	emptyPackagesBytes = []byte("\n  <packages></packages>\n")
 	emptyToolsBytes    = []byte("\n  <tools></tools>\n")
 
 	endElemBytes        = []byte("/>\n")
 	endImportBytes      = []byte("></import>\n")
 	endLocalImportBytes = []byte("></localimport>\n")
 	endProjectBytes     = []byte("></project>\n")
+	endPackageBytes     = []byte("></package>\n")
 	endToolBytes        = []byte("></tool>\n")
 
 	endImportSoloBytes  = []byte("></import>")
@@ -107,6 +112,7 @@ func (m *Manifest) deepCopy() *Manifest {
 	x.Imports = append([]Import(nil), m.Imports...)
 	x.LocalImports = append([]LocalImport(nil), m.LocalImports...)
 	x.Projects = append([]Project(nil), m.Projects...)
+	x.Packages = append([]Package(nil), m.Packages...)
 	x.Tools = append([]Tool(nil), m.Tools...)
 	return x
 }
@@ -125,10 +131,12 @@ func (m *Manifest) ToBytes() ([]byte, error) {
 	// elements, or produce short empty elements, so we post-process the data.
 	data = bytes.Replace(data, emptyImportsBytes, newlineBytes, -1)
 	data = bytes.Replace(data, emptyProjectsBytes, newlineBytes, -1)
+	data = bytes.Replace(data, emptyPackagesBytes, newlineBytes, -1)
 	data = bytes.Replace(data, emptyToolsBytes, newlineBytes, -1)
 	data = bytes.Replace(data, endImportBytes, endElemBytes, -1)
 	data = bytes.Replace(data, endLocalImportBytes, endElemBytes, -1)
 	data = bytes.Replace(data, endProjectBytes, endElemBytes, -1)
+	data = bytes.Replace(data, endPackageBytes, endElemBytes, -1)
 	data = bytes.Replace(data, endToolBytes, endElemBytes, -1)
 	if !bytes.HasSuffix(data, newlineBytes) {
 		data = append(data, '\n')
@@ -158,6 +166,14 @@ func (m *Manifest) ToFile(jirix *jiri.X, filename string) error {
 		projects = append(projects, project)
 	}
 	m.Projects = projects
+	packages := []Package{}
+	for _, pkg := range m.Packages {
+		if err := pkg.relativizePaths(jirix.Root); err != nil {
+			return err
+		}
+		packages = append(packages, pkg)
+	}
+	m.Packages = packages
 	data, err := m.ToBytes()
 	if err != nil {
 		return err
@@ -181,6 +197,11 @@ func (m *Manifest) fillDefaults() error {
 			return err
 		}
 	}
+	for index := range m.Packages {
+		if err := m.Packages[index].fillDefaults(); err != nil {
+			return err
+		}
+	}
 	for index := range m.Tools {
 		if err := m.Tools[index].fillDefaults(); err != nil {
 			return err
@@ -205,6 +226,11 @@ func (m *Manifest) unfillDefaults() error {
 			return err
 		}
 	}
+	for index := range m.Packages {
+		if err := m.Packages[index].unfillDefaults(); err != nil {
+			return err
+		}
+	}
 	for index := range m.Tools {
 		if err := m.Tools[index].unfillDefaults(); err != nil {
 			return err
@@ -536,8 +562,19 @@ type Tool struct {
 	Data string `xml:"data,attr,omitempty"`
 	// Name is the name of the tool binary.
 	Name string `xml:"name,attr,omitempty"`
-	// Package is the package path of the tool.
+	// Package is the package path of the tool.  It must not be set for
+	// tools that specify a build command.
 	Package string `xml:"package,attr,omitempty"`
+	// Build is a shell command that builds a tool that is not a Go package.
+	// It is run with the root of a copy of the tool project as its working
+	// directory.
+	Build string `xml:"build,attr,omitempty"`
+	// Output is the path of the artifact produced by Build, relative to the
+	// tool project.  It is installed under the tool name.
+	Output string `xml:"output,attr,omitempty"`
+	// Plugin indicates whether the tool is a jiri plugin, run as a subcommand
+	// of jiri.  The name of a plugin must start with jiri.PluginPrefix.
+	Plugin bool `xml:"plugin,attr,omitempty"`
 	// Project identifies the project that contains the tool. If not
 	// set, "https://fuchsia.googlesource.com/<JiriProject>" is
 	// used as the default.
@@ -552,7 +589,7 @@ func (t *Tool) fillDefaults() error {
 	if t.Project == "" {
 		t.Project = "https://fuchsia.googlesource.com/" + JiriProject
 	}
-	return nil
+	return t.validate()
 }
 
 func (t *Tool) unfillDefaults() error {
@@ -561,6 +598,20 @@ func (t *Tool) unfillDefaults() error {
 	}
 	// Don't unfill the jiri project setting, since that's not meant to be
 	// optional.
+	return t.validate()
+}
+
+func (t *Tool) validate() error {
+	switch {
+	case t.Build != "" && t.Package != "":
+		return fmt.Errorf("bad tool %q: package and build cannot both be specified", t.Name)
+	case t.Build != "" && t.Output == "":
+		return fmt.Errorf("bad tool %q: output must be specified with build", t.Name)
+	case t.Build == "" && t.Output != "":
+		return fmt.Errorf("bad tool %q: output requires build to be specified", t.Name)
+	case t.Plugin && !strings.HasPrefix(t.Name, jiri.PluginPrefix):
+		return fmt.Errorf("bad tool %q: the name of a plugin must start with %q", t.Name, jiri.PluginPrefix)
+	}
 	return nil
 }
 
@@ -611,12 +662,19 @@ func CreateSnapshot(jirix *jiri.X, file, snapshotPath string) error {
 		manifest.Projects = append(manifest.Projects, project)
 	}
 
+	// Add all local packages to manifest.
+	localPackages, err := LocalPackages(jirix)
+	if err != nil {
+		return err
+	}
+	manifest.Packages = localPackages.toSlice()
+
 	// Add all tools from the current manifest to the snapshot manifest.
 	// We can't just call LoadManifest here, since that determines the
 	// local projects using FastScan, but if we're calling CreateSnapshot
 	// during "jiri update" and we added some new projects, they won't be
 	// found anymore.
-	_, tools, err := loadManifestFile(jirix, jirix.JiriManifestFile(), localProjects)
+	_, tools, _, err := loadManifestFile(jirix, jirix.JiriManifestFile(), localProjects)
 	if err != nil {
 		return err
 	}
@@ -638,11 +696,11 @@ func CheckoutSnapshot(jirix *jiri.X, snapshot string, gc bool) error {
 	if err != nil {
 		return err
 	}
-	remoteProjects, remoteTools, err := LoadSnapshotFile(jirix, snapshot)
+	remoteProjects, remoteTools, remotePackages, err := loadManifestFile(jirix, snapshot, nil)
 	if err != nil {
 		return err
 	}
-	if err := updateTo(jirix, localProjects, remoteProjects, remoteTools, gc); err != nil {
+	if err := updateTo(jirix, localProjects, remoteProjects, remoteTools, remotePackages, snapshot, gc); err != nil {
 		return err
 	}
 	return WriteUpdateHistorySnapshot(jirix, snapshot)
@@ -651,7 +709,8 @@ func CheckoutSnapshot(jirix *jiri.X, snapshot string, gc bool) error {
 // LoadSnapshotFile loads the specified snapshot manifest.  If the snapshot
 // manifest contains a remote import, an error will be returned.
 func LoadSnapshotFile(jirix *jiri.X, file string) (Projects, Tools, error) {
-	return loadManifestFile(jirix, file, nil)
+	projects, tools, _, err := loadManifestFile(jirix, file, nil)
+	return projects, tools, err
 }
 
 // CurrentProjectKey gets the key of the current project from the current
@@ -760,6 +819,9 @@ func projectsExistLocally(jirix *jiri.X, projects Projects) (bool, error) {
 func PollProjects(jirix *jiri.X, projectSet map[string]struct{}) (_ Update, e error) {
 	jirix.TimerPush("poll projects")
 	defer jirix.TimerPop()
+	if err := jirix.RequireOnline("polling projects"); err != nil {
+		return nil, err
+	}
 
 	// Switch back to current working directory when we're done.
 	cwd, err := os.Getwd()
@@ -780,7 +842,7 @@ func PollProjects(jirix *jiri.X, projectSet map[string]struct{}) (_ Update, e er
 
 	// Compute difference between local and remote.
 	update := Update{}
-	ops := computeOperations(localProjects, remoteProjects, false)
+	ops := computeOperations(localProjects, remoteProjects, false, true)
 	s := jirix.NewSeq()
 	for _, op := range ops {
 		name := op.Project().Name
@@ -806,20 +868,17 @@ func PollProjects(jirix *jiri.X, projectSet map[string]struct{}) (_ Update, e er
 			}
 
 			// Collect commits visible from FETCH_HEAD that aren't visible from master.
-			commitsText, err := gitutil.New(jirix.NewSeq()).Log("FETCH_HEAD", "master", "%an%n%ae%n%B")
+			p := updateOp.project
+			p.Path = updateOp.destination
+			commits, err := logProject(jirix, p, "master..FETCH_HEAD")
 			if err != nil {
 				return nil, err
 			}
-
-			// Format those commits and add them to the results.
-			for _, commitText := range commitsText {
-				if got, want := len(commitText), 3; got < want {
-					return nil, fmt.Errorf("Unexpected length of %v: got %v, want at least %v", commitText, got, want)
-				}
+			for _, commit := range commits {
 				cls = append(cls, CL{
-					Author:      commitText[0],
-					Email:       commitText[1],
-					Description: strings.Join(commitText[2:], "\n"),
+					Author:      commit.Author,
+					Email:       commit.Email,
+					Description: commit.Description,
 				})
 			}
 		}
@@ -844,23 +903,25 @@ func LoadManifest(jirix *jiri.X) (Projects, Tools, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	return loadManifestFile(jirix, file, localProjects)
+	projects, tools, _, err := loadManifestFile(jirix, file, localProjects)
+	return projects, tools, err
 }
 
 // loadManifestFile loads the manifest starting with the given file, resolving
-// remote and local imports.  Local projects are used to resolve remote imports;
-// if nil, encountering any remote import will result in an error.
+// remote and local imports, and returns the projects, tools and packages it
+// specifies.  Local projects are used to resolve remote imports; if nil,
+// encountering any remote import will result in an error.
 //
 // WARNING: loadManifestFile cannot be run multiple times in parallel!  It
 // invokes git operations which require a lock on the filesystem.  If you see
 // errors about ".git/index.lock exists", you are likely calling
 // loadManifestFile in parallel.
-func loadManifestFile(jirix *jiri.X, file string, localProjects Projects) (Projects, Tools, error) {
+func loadManifestFile(jirix *jiri.X, file string, localProjects Projects) (Projects, Tools, Packages, error) {
 	ld := newManifestLoader(localProjects, false)
 	if err := ld.Load(jirix, "", file, ""); err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	return ld.Projects, ld.Tools, nil
+	return ld.Projects, ld.Tools, ld.Packages, nil
 }
 
 // getManifestRemote returns the remote url of the origin from the manifest
@@ -877,20 +938,22 @@ func getManifestRemote(jirix *jiri.X, manifestPath string) (string, error) {
 		}, "get manifest origin").Done()
 }
 
-func loadUpdatedManifest(jirix *jiri.X, localProjects Projects) (Projects, Tools, string, error) {
+func loadUpdatedManifest(jirix *jiri.X, localProjects Projects) (Projects, Tools, Packages, string, error) {
 	jirix.TimerPush("load updated manifest")
 	defer jirix.TimerPop()
-	ld := newManifestLoader(localProjects, true)
+	// In offline mode, the manifest import projects are reset without being
+	// fetched.
+	ld := newManifestLoader(localProjects, !jirix.Offline)
 	if err := ld.Load(jirix, "", jirix.JiriManifestFile(), ""); err != nil {
-		return nil, nil, ld.TmpDir, err
+		return nil, nil, nil, ld.TmpDir, err
 	}
-	return ld.Projects, ld.Tools, ld.TmpDir, nil
+	return ld.Projects, ld.Tools, ld.Packages, ld.TmpDir, nil
 }
 
-// UpdateUniverse updates all local projects and tools to match the remote
-// counterparts identified in the manifest. Optionally, the 'gc' flag can be
-// used to indicate that local projects that no longer exist remotely should be
-// removed.
+// UpdateUniverse updates all local projects, packages and tools to match the
+// remote counterparts identified in the manifest. Optionally, the 'gc' flag can
+// be used to indicate that local projects and packages that no longer exist
+// remotely should be removed.
 func UpdateUniverse(jirix *jiri.X, gc bool) (e error) {
 	jirix.TimerPush("update universe")
 	defer jirix.TimerPop()
@@ -908,35 +971,51 @@ func UpdateUniverse(jirix *jiri.X, gc bool) (e error) {
 	// Load the manifest, updating all manifest projects to match their remote
 	// counterparts.
 	s := jirix.NewSeq()
-	remoteProjects, remoteTools, tmpLoadDir, err := loadUpdatedManifest(jirix, localProjects)
+	remoteProjects, remoteTools, remotePackages, tmpLoadDir, err := loadUpdatedManifest(jirix, localProjects)
 	if tmpLoadDir != "" {
 		defer collect.Error(func() error { return s.RemoveAll(tmpLoadDir).Done() }, &e)
 	}
 	if err != nil {
 		return err
 	}
-	return updateTo(jirix, localProjects, remoteProjects, remoteTools, gc)
+	return updateTo(jirix, localProjects, remoteProjects, remoteTools, remotePackages, jirix.JiriManifestFile(), gc)
 }
 
-// updateTo updates the local projects and tools to the state specified in
-// remoteProjects and remoteTools.
-func updateTo(jirix *jiri.X, localProjects, remoteProjects Projects, remoteTools Tools, gc bool) (e error) {
-	s := jirix.NewSeq()
+// updateTo updates the local projects, packages and tools to the state
+// specified in remoteProjects, remotePackages and remoteTools, which were
+// loaded from the manifest or snapshot file snapshotPath.
+func updateTo(jirix *jiri.X, localProjects, remoteProjects Projects, remoteTools Tools, remotePackages Packages, snapshotPath string, gc bool) (e error) {
 	// 1. Update all local projects to match the specified projects argument.
-	if err := updateProjects(jirix, localProjects, remoteProjects, gc); err != nil {
+	if err := updateProjects(jirix, localProjects, remoteProjects, gc, !jirix.Offline); err != nil {
 		return err
 	}
-	// 2. Build all tools in a temporary directory.
-	tmpToolsDir, err := s.TempDir("", "tmp-jiri-tools-build")
+	// 2. Install the packages that are missing or out of date.
+	if err := checkCancelled(jirix, "before updating packages"); err != nil {
+		return err
+	}
+	localPackages, err := LocalPackages(jirix)
 	if err != nil {
-		return fmt.Errorf("TempDir() failed: %v", err)
+		return err
 	}
-	defer collect.Error(func() error { return s.RemoveAll(tmpToolsDir).Done() }, &e)
-	if err := buildToolsFromMaster(jirix, remoteProjects, remoteTools, tmpToolsDir); err != nil {
+	// Packages must not overlap the projects, including local projects that
+	// are no longer in the manifest but were left in place.
+	projects := Projects{}
+	for key, p := range localProjects {
+		if _, err := jirix.NewSeq().Stat(p.Path); err == nil {
+			projects[key] = p
+		}
+	}
+	for key, p := range remoteProjects {
+		projects[key] = p
+	}
+	if err := updatePackages(jirix, localPackages, remotePackages, projects, gc); err != nil {
 		return err
 	}
-	// 3. Install the tools into $JIRI_ROOT/.jiri_root/bin.
-	if err := InstallTools(jirix, tmpToolsDir); err != nil {
+	// 3. Build and install the tools that are out of date.
+	if err := checkCancelled(jirix, "before updating tools"); err != nil {
+		return err
+	}
+	if err := updateTools(jirix, remoteProjects, remoteTools, snapshotPath); err != nil {
 		return err
 	}
 	// 4. If we have the jiri project, then update the jiri script in
@@ -949,6 +1028,158 @@ func updateTo(jirix *jiri.X, localProjects, remoteProjects Projects, remoteTools
 	return updateJiriScript(jirix, jiriProject)
 }
 
+// updateTools builds the tools that are out of date from the master branch of
+// their projects, installs them into $JIRI_ROOT/.jiri_root/bin, and records
+// what they were built from.
+func updateTools(jirix *jiri.X, projects Projects, tools Tools, snapshotPath string) (e error) {
+	// Build the tools in a temporary directory.
+	s := jirix.NewSeq()
+	tmpToolsDir, err := s.TempDir("", "tmp-jiri-tools-build")
+	if err != nil {
+		return fmt.Errorf("TempDir() failed: %v", err)
+	}
+	defer collect.Error(func() error { return s.RemoveAll(tmpToolsDir).Done() }, &e)
+	keys, err := toolKeys(jirix, projects, tools, "master")
+	if err != nil {
+		return err
+	}
+	staleTools, err := outOfDateTools(jirix, tools, keys)
+	if err != nil {
+		return err
+	}
+	mds, err := buildToolsFromMaster(jirix, projects, staleTools, tmpToolsDir, snapshotPath)
+	if err != nil {
+		return err
+	}
+	if err := InstallTools(jirix, tmpToolsDir); err != nil {
+		return err
+	}
+	return recordToolKeys(jirix, staleTools, keys, mds)
+}
+
+// UpdateProjects updates the projects identified by the given arguments, as
+// interpreted by MatchProjects, to match the manifest.  Manifest projects that
+// the selected projects are imported through are updated as well, and the
+// tools built from the selected projects are rebuilt if they are out of date.
+// All other projects, packages and tools are left alone.  If no arguments are
+// given, all projects are selected.
+//
+// If local is true, nothing is fetched: the selected projects are reset to
+// the revisions the manifest specifies, as known from their last fetch.
+// Projects that don't exist locally can't be created in local mode.  Offline
+// mode implies local mode.
+func UpdateProjects(jirix *jiri.X, args []string, local bool) (e error) {
+	jirix.TimerPush("update selected projects")
+	defer jirix.TimerPop()
+
+	fetch := !local && !jirix.Offline
+	localProjects, err := LocalProjects(jirix, FastScan)
+	if err != nil {
+		return err
+	}
+	// Load the manifest.  Unless we're in local mode, this fetches and resets
+	// all manifest import projects.
+	ld := newManifestLoader(localProjects, fetch)
+	err = ld.Load(jirix, "", jirix.JiriManifestFile(), "")
+	if ld.TmpDir != "" {
+		defer collect.Error(func() error { return jirix.NewSeq().RemoveAll(ld.TmpDir).Done() }, &e)
+	}
+	if err != nil {
+		return err
+	}
+	remoteProjects := ld.Projects
+	if len(args) > 0 {
+		if remoteProjects, err = MatchProjects(ld.Projects, args); err != nil {
+			return err
+		}
+	}
+	for key := range ld.importKeys {
+		if p, ok := ld.Projects[key]; ok {
+			remoteProjects[key] = p
+		}
+	}
+	// Only consider the local counterparts of the selected projects, so that
+	// no other project is touched.
+	selectedLocalProjects := Projects{}
+	for key := range remoteProjects {
+		if p, ok := localProjects[key]; ok {
+			selectedLocalProjects[key] = p
+		}
+	}
+	if err := updateProjects(jirix, selectedLocalProjects, remoteProjects, false, fetch); err != nil {
+		return err
+	}
+	remoteTools := Tools{}
+	for name, tool := range ld.Tools {
+		if len(remoteProjects.Find(tool.Project)) > 0 {
+			remoteTools[name] = tool
+		}
+	}
+	return updateTools(jirix, remoteProjects, remoteTools, jirix.JiriManifestFile())
+}
+
+// MatchProjects returns the projects identified by the given arguments.  Each
+// argument is interpreted as a project name, a path inside a project, or a
+// regular expression matching project keys, in that order.  An error is
+// returned if an argument doesn't identify any project.
+func MatchProjects(projects Projects, args []string) (Projects, error) {
+	result := Projects{}
+	for _, arg := range args {
+		matches := Projects{}
+		for key, p := range projects {
+			if p.Name == arg || string(key) == arg {
+				matches[key] = p
+			}
+		}
+		if len(matches) == 0 {
+			if p, ok := projectContaining(projects, arg); ok {
+				matches[p.Key()] = p
+			}
+		}
+		if len(matches) == 0 {
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%q is neither a project nor a valid regular expression: %v", arg, err)
+			}
+			for key, p := range projects {
+				if re.MatchString(string(key)) {
+					matches[key] = p
+				}
+			}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no projects match %q", arg)
+		}
+		for key, p := range matches {
+			result[key] = p
+		}
+	}
+	return result, nil
+}
+
+// projectContaining returns the innermost project containing the given path,
+// if the path exists.
+func projectContaining(projects Projects, path string) (Project, bool) {
+	if _, err := os.Stat(path); err != nil {
+		return Project{}, false
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return Project{}, false
+	}
+	var result Project
+	found := false
+	for _, p := range projects {
+		if path != p.Path && !strings.HasPrefix(path, p.Path+string(filepath.Separator)) {
+			continue
+		}
+		if !found || len(p.Path) > len(result.Path) {
+			result, found = p, true
+		}
+	}
+	return result, found
+}
+
 // WriteUpdateHistorySnapshot creates a snapshot of the current state of all
 // projects and writes it to the update history directory.
 func WriteUpdateHistorySnapshot(jirix *jiri.X, snapshotPath string) error {
@@ -1033,21 +1264,51 @@ func ApplyToLocalMaster(jirix *jiri.X, projects Projects, fn func() error) (e er
 }
 
 // BuildTools builds the given tools and places the resulting binaries into the
-// given directory.
-func BuildTools(jirix *jiri.X, projects Projects, tools Tools, outputDir string) (e error) {
+// given directory.  Tools that specify a build command are built by running
+// it in a sandbox.  Go tools whose project contains a go.mod file are built in
+// module mode, using module and build caches private to the jiri root; all
+// other Go tools are built in GOPATH mode.
+//
+// Go tools have build metadata embedded via the metadata package; see
+// toolMetadata for details.
+func BuildTools(jirix *jiri.X, projects Projects, tools Tools, outputDir string) error {
+	_, err := buildTools(jirix, projects, tools, outputDir, jirix.JiriManifestFile())
+	return err
+}
+
+// buildTools implements BuildTools, and returns the metadata of the tools that
+// were built.  The snapshotPath identifies the manifest or snapshot file the
+// tools are built from.
+func buildTools(jirix *jiri.X, projects Projects, tools Tools, outputDir, snapshotPath string) (_ map[string]*metadata.T, e error) {
 	jirix.TimerPush("build tools")
 	defer jirix.TimerPop()
 	if len(tools) == 0 {
 		// Nothing to do here...
-		return nil
+		return nil, nil
 	}
-	toolPkgs := []string{}
+	names := []string{}
+	toolProjects := map[string]Project{}
+	recipes := map[string]Project{}
+	modules := map[string]goModule{}
 	workspaceSet := map[string]bool{}
-	for _, tool := range tools {
-		toolPkgs = append(toolPkgs, tool.Package)
+	for name, tool := range tools {
+		names = append(names, name)
 		toolProject, err := projects.FindUnique(tool.Project)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		toolProjects[name] = toolProject
+		if tool.Build != "" {
+			recipes[name] = toolProject
+			continue
+		}
+		module, ok, err := findGoModule(jirix, toolProject, tool.Package)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			modules[name] = module
+			continue
 		}
 		// Identify the Go workspace the tool is in. To this end we use a
 		// heuristic that identifies the maximal suffix of the project path
@@ -1062,14 +1323,16 @@ func BuildTools(jirix *jiri.X, projects Projects, tools Tools, outputDir string)
 			}
 		}
 		if workspace == "" {
-			return fmt.Errorf("could not identify go workspace for tool %v", tool.Name)
+			return nil, fmt.Errorf("could not identify go workspace for tool %v", tool.Name)
 		}
 		workspaceSet[workspace] = true
 	}
+	sort.Strings(names)
 	workspaces := []string{}
 	for workspace := range workspaceSet {
 		workspaces = append(workspaces, workspace)
 	}
+	sort.Strings(workspaces)
 	if envGoPath := os.Getenv("GOPATH"); envGoPath != "" {
 		workspaces = append(workspaces, strings.Split(envGoPath, string(filepath.ListSeparator))...)
 	}
@@ -1079,23 +1342,110 @@ func BuildTools(jirix *jiri.X, projects Projects, tools Tools, outputDir string)
 	// weird errors when they share a pkgdir.
 	tmpPkgDir, err := s.TempDir("", "tmp-pkg-dir")
 	if err != nil {
-		return fmt.Errorf("TempDir() failed: %v", err)
+		return nil, fmt.Errorf("TempDir() failed: %v", err)
 	}
 	defer collect.Error(func() error { return jirix.NewSeq().RemoveAll(tmpPkgDir).Done() }, &e)
 
-	// We unset GOARCH and GOOS because jiri update should always build for the
-	// native architecture and OS.  Also, as of go1.5, setting GOBIN is not
-	// compatible with GOARCH or GOOS.
+	if rel, err := filepath.Rel(jirix.Root, snapshotPath); err == nil && !strings.HasPrefix(rel, "..") {
+		snapshotPath = rel
+	}
+	buildTime, version := time.Now(), ""
+	if len(recipes) < len(names) {
+		version = goVersion(jirix)
+	}
+
+	// Build the tools one at a time, so that a failure can be attributed to
+	// the tool that caused it.
+	var failed []string
+	mds := map[string]*metadata.T{}
+	for _, name := range names {
+		tool := tools[name]
+		var err error
+		if toolProject, ok := recipes[name]; ok {
+			mds[name] = toolMetadata(jirix, toolProject, snapshotPath, buildTime, "")
+			err = buildToolRecipe(jirix, toolProject, tool, outputDir)
+		} else {
+			mds[name] = toolMetadata(jirix, toolProjects[name], snapshotPath, buildTime, version)
+			if module, ok := modules[name]; ok {
+				err = buildGoTool(jirix, tool, &module, nil, "", outputDir, mds[name])
+			} else {
+				err = buildGoTool(jirix, tool, nil, workspaces, tmpPkgDir, outputDir, mds[name])
+			}
+		}
+		if err != nil {
+			failed = append(failed, name)
+			delete(mds, name)
+			fmt.Fprintf(jirix.Stderr(), "failed to build tool %q:\n%v\n", name, err)
+		}
+	}
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("tool build failed for %v", strings.Join(failed, ", "))
+	}
+	return mds, nil
+}
+
+// toolMetadata returns the metadata embedded in a tool built from the given
+// project: the project name and revision, the path of the manifest or
+// snapshot the tool was built from, the build time and, for Go tools, the Go
+// version.
+func toolMetadata(jirix *jiri.X, project Project, snapshotPath string, buildTime time.Time, goVersion string) *metadata.T {
+	md := new(metadata.T)
+	md.Insert("jiri.Project", project.Name)
+	// The revision is unknown if the project isn't a git repository.
+	if revision, err := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(project.Path)).CurrentRevision(); err == nil {
+		md.Insert("jiri.Revision", revision)
+	}
+	md.Insert("jiri.SnapshotPath", snapshotPath)
+	md.Insert("jiri.BuildTime", buildTime.UTC().Format(time.RFC3339))
+	if fields := strings.Fields(goVersion); len(fields) >= 3 {
+		// "go version go1.x os/arch"
+		md.Insert("go.Version", fields[2])
+	}
+	return md
+}
+
+// buildGoTool builds the given Go tool into outputDir, embedding the given
+// metadata.  If module is nil, the tool is built in GOPATH mode using the given
+// workspaces and pkgDir.
+func buildGoTool(jirix *jiri.X, tool Tool, module *goModule, workspaces []string, pkgDir, outputDir string, md *metadata.T) error {
+	// We unset GOARCH and GOOS because jiri update should always build for
+	// the native architecture and OS.  Also, as of go1.5, setting GOBIN is
+	// not compatible with GOARCH or GOOS.
 	env := map[string]string{
 		"GOARCH": "",
 		"GOOS":   "",
 		"GOBIN":  outputDir,
-		"GOPATH": strings.Join(workspaces, string(filepath.ListSeparator)),
 	}
-	args := append([]string{"install", "-pkgdir", tmpPkgDir}, toolPkgs...)
+	var dir string
+	var args []string
+	if module != nil {
+		modFlag, err := goModFlag(jirix, *module)
+		if err != nil {
+			return err
+		}
+		cacheDir := goCacheDir(jirix)
+		env["GO111MODULE"] = "on"
+		// Keep the module cache writable, so that the jiri root can be
+		// removed with "rm -rf".
+		env["GOFLAGS"] = modFlag + " -modcacherw"
+		env["GOMODCACHE"] = filepath.Join(cacheDir, "pkg", "mod")
+		env["GOCACHE"] = filepath.Join(cacheDir, "cache")
+		env["GOPATH"] = cacheDir
+		env["GOWORK"] = "off"
+		dir = module.Dir
+		args = []string{"install"}
+	} else {
+		env["GO111MODULE"] = "off"
+		env["GOPATH"] = strings.Join(workspaces, string(filepath.ListSeparator))
+		args = []string{"install", "-pkgdir", pkgDir}
+	}
+	args = append(args, "-ldflags", metadata.LDFlag(md), tool.Package)
 	var stderr bytes.Buffer
-	if err := s.Env(env).Capture(ioutil.Discard, &stderr).Last("go", args...); err != nil {
-		return fmt.Errorf("tool build failed\n%v", stderr.String())
+	if err := jirix.NewSeq().Dir(dir).Env(env).Capture(ioutil.Discard, &stderr).Last("go", args...); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("go %v: %v", strings.Join(args, " "), msg)
+		}
+		return err
 	}
 	return nil
 }
@@ -1103,33 +1453,42 @@ func BuildTools(jirix *jiri.X, projects Projects, tools Tools, outputDir string)
 // buildToolsFromMaster builds and installs all jiri tools using the version
 // available in the local master branch of the tools repository. Notably, this
 // function does not perform any version control operation on the master
-// branch.
-func buildToolsFromMaster(jirix *jiri.X, projects Projects, tools Tools, outputDir string) error {
+// branch.  The metadata of the built tools is returned.
+func buildToolsFromMaster(jirix *jiri.X, projects Projects, tools Tools, outputDir, snapshotPath string) (map[string]*metadata.T, error) {
 	toolsToBuild := Tools{}
 	toolNames := []string{} // Used for logging purposes.
 	for _, tool := range tools {
-		// Skip tools with no package specified. Besides increasing
-		// robustness, this step also allows us to create jiri root
-		// fakes without having to provide an implementation for the "jiri"
-		// tool, which every manifest needs to specify.
-		if tool.Package == "" {
+		// Skip tools with neither a package nor a build command specified.
+		// Besides increasing robustness, this step also allows us to create
+		// jiri root fakes without having to provide an implementation for the
+		// "jiri" tool, which every manifest needs to specify.
+		if tool.Package == "" && tool.Build == "" {
 			continue
 		}
 		toolsToBuild[tool.Name] = tool
 		toolNames = append(toolNames, tool.Name)
 	}
+	if len(toolsToBuild) == 0 {
+		return nil, nil
+	}
 
+	var mds map[string]*metadata.T
 	updateFn := func() error {
 		return ApplyToLocalMaster(jirix, projects, func() error {
-			return BuildTools(jirix, projects, toolsToBuild, outputDir)
+			var err error
+			mds, err = buildTools(jirix, projects, toolsToBuild, outputDir, snapshotPath)
+			return err
 		})
 	}
 
 	// Always log the output of updateFn, irrespective of the value of the
 	// verbose flag.
-	return jirix.NewSeq().Verbose(true).
+	if err := jirix.NewSeq().Verbose(true).
 		Call(updateFn, "build tools: %v", strings.Join(toolNames, " ")).
-		Done()
+		Done(); err != nil {
+		return nil, err
+	}
+	return mds, nil
 }
 
 // CleanupProjects restores the given jiri projects back to their master
@@ -1332,18 +1691,30 @@ func resetProjectCurrentBranch(jirix *jiri.X, project Project) error {
 	}
 	// Having a specific revision trumps everything else.
 	if project.Revision != "HEAD" {
-		return gitutil.New(jirix.NewSeq()).Reset(project.Revision)
+		git := gitutil.New(jirix.NewSeq())
+		if err := git.Reset(project.Revision); err != nil {
+			// Without a fetch, e.g. in offline mode, the revision may
+			// not have been fetched yet.
+			if !git.IsRevisionAvailable(project.Revision) {
+				return fmt.Errorf("revision %v of project %q is not available locally", project.Revision, project.Name)
+			}
+			return err
+		}
+		return nil
 	}
 	// If no revision, reset to the configured remote branch.
 	return gitutil.New(jirix.NewSeq()).Reset("origin/" + project.RemoteBranch)
 }
 
-// syncProjectMaster fetches from the project remote and resets the local master
-// branch to the revision and branch specified on the project.
-func syncProjectMaster(jirix *jiri.X, project Project) error {
+// syncProjectMaster fetches from the project remote, if fetch is true, and
+// resets the local master branch to the revision and branch specified on the
+// project.
+func syncProjectMaster(jirix *jiri.X, project Project, fetch bool) error {
 	return ApplyToLocalMaster(jirix, Projects{project.Key(): project}, func() error {
-		if err := fetchProject(jirix, project); err != nil {
-			return err
+		if fetch {
+			if err := fetchProject(jirix, project); err != nil {
+				return err
+			}
 		}
 		return resetProjectCurrentBranch(jirix, project)
 	})
@@ -1361,18 +1732,24 @@ func newManifestLoader(localProjects Projects, update bool) *loader {
 	return &loader{
 		Projects:      make(Projects),
 		Tools:         make(Tools),
+		Packages:      make(Packages),
 		localProjects: localProjects,
 		update:        update,
+		importKeys:    make(map[ProjectKey]bool),
 	}
 }
 
 type loader struct {
 	Projects      Projects
 	Tools         Tools
+	Packages      Packages
 	TmpDir        string
 	localProjects Projects
 	update        bool
 	cycleStack    []cycleInfo
+	// importKeys holds the keys of the projects that remote imports were
+	// loaded from.
+	importKeys map[ProjectKey]bool
 }
 
 type cycleInfo struct {
@@ -1451,6 +1828,7 @@ func (ld *loader) load(jirix *jiri.X, root, file string) error {
 		nextRoot := filepath.Join(root, remote.Root)
 		remote.Name = filepath.Join(nextRoot, remote.Name)
 		key := remote.ProjectKey()
+		ld.importKeys[key] = true
 		p, ok := ld.localProjects[key]
 		if !ok {
 			if !ld.update {
@@ -1516,6 +1894,17 @@ func (ld *loader) load(jirix *jiri.X, root, file string) error {
 		}
 		ld.Tools[name] = tool
 	}
+	// Collect packages.
+	for _, pkg := range m.Packages {
+		// Make paths absolute by prepending JIRI_ROOT/<root>, and prepend the
+		// root to the package name, as we do for projects.
+		pkg.absolutizePaths(filepath.Join(jirix.Root, root))
+		pkg.Name = filepath.Join(root, pkg.Name)
+		if dup, ok := ld.Packages[pkg.Name]; ok && dup != pkg {
+			return fmt.Errorf("duplicate package %q found in %v", pkg.Name, shortFileName(jirix.Root, file))
+		}
+		ld.Packages[pkg.Name] = pkg
+	}
 	return nil
 }
 
@@ -1524,10 +1913,8 @@ func (ld *loader) resetAndLoad(jirix *jiri.X, root, file, cycleKey string, proje
 	pushd := jirix.NewSeq().Pushd(project.Path)
 	defer collect.Error(pushd.Done, &e)
 	// Reset the local master branch to what's specified on the project.  We only
-	// fetch on updates; non-updates just perform the reset.
-	//
-	// TODO(toddw): Support "jiri update -local=p1,p2" by simply calling ld.Load
-	// for the given projects, rather than ApplyToLocalMaster(fetch+reset+load).
+	// fetch on updates; non-updates, including "jiri update -local", just
+	// perform the reset.
 	return ApplyToLocalMaster(jirix, Projects{project.Key(): project}, func() error {
 		if ld.update {
 			if err := fetchProject(jirix, project); err != nil {
@@ -1630,20 +2017,28 @@ func getRemoteHeadRevisions(jirix *jiri.X, remoteProjects Projects) {
 	}
 }
 
-func updateProjects(jirix *jiri.X, localProjects, remoteProjects Projects, gc bool) error {
+func updateProjects(jirix *jiri.X, localProjects, remoteProjects Projects, gc, fetch bool) error {
 	jirix.TimerPush("update projects")
 	defer jirix.TimerPop()
 
-	getRemoteHeadRevisions(jirix, remoteProjects)
-	ops := computeOperations(localProjects, remoteProjects, gc)
+	if fetch {
+		getRemoteHeadRevisions(jirix, remoteProjects)
+	}
+	ops := computeOperations(localProjects, remoteProjects, gc, fetch)
 	updates := newFsUpdates()
 	for _, op := range ops {
+		if op.Kind() == "create" && !fetch {
+			return fmt.Errorf("project %q does not exist locally and can't be created without fetching", op.Project().Name)
+		}
 		if err := op.Test(jirix, updates); err != nil {
 			return err
 		}
 	}
 	s := jirix.NewSeq()
-	for _, op := range ops {
+	for i, op := range ops {
+		if err := checkCancelled(jirix, "after updating %d of %d projects", i, len(ops)); err != nil {
+			return err
+		}
 		updateFn := func() error { return op.Run(jirix) }
 		// Always log the output of updateFn, irrespective of
 		// the value of the verbose flag.
@@ -1657,6 +2052,16 @@ func updateProjects(jirix *jiri.X, localProjects, remoteProjects Projects, gc bo
 	return applyGitHooks(jirix, ops)
 }
 
+// checkCancelled returns an error if the operation in progress was cancelled,
+// e.g. by an interrupt, that describes where it stopped.  It is called at safe
+// points, where stopping leaves the jiri root in a consistent state.
+func checkCancelled(jirix *jiri.X, format string, args ...interface{}) error {
+	if err := jirix.Ctx().Err(); err != nil {
+		return fmt.Errorf("cancelled %s: %v", fmt.Sprintf(format, args...), err)
+	}
+	return nil
+}
+
 // runHooks runs all hooks for the given operations.
 func runHooks(jirix *jiri.X, ops []operation) error {
 	jirix.TimerPush("run hooks")
@@ -1668,6 +2073,9 @@ func runHooks(jirix *jiri.X, ops []operation) error {
 		if op.Kind() != "create" && op.Kind() != "move" && op.Kind() != "update" {
 			continue
 		}
+		if err := checkCancelled(jirix, "before running the hook of project %q", op.Project().Name); err != nil {
+			return err
+		}
 		s := jirix.NewSeq()
 		s.Verbose(true).Output([]string{fmt.Sprintf("running hook for project %q", op.Project().Name)})
 		if err := s.Dir(op.Project().Path).Capture(os.Stdout, os.Stderr).Last(op.Project().RunHook, op.Kind()); err != nil {
@@ -1697,38 +2105,14 @@ func applyGitHooks(jirix *jiri.X, ops []operation) error {
 				return err
 			}
 		}
-		if op.Project().GitHooks == "" {
+		if op.Kind() == "delete" {
 			continue
 		}
-		if op.Kind() != "create" && op.Kind() != "move" && op.Kind() != "update" {
-			continue
-		}
-		// Apply git hooks, overwriting any existing hooks.  Jiri is in control of
-		// writing all hooks.
-		gitHooksDstDir := filepath.Join(op.Project().Path, ".git", "hooks")
-		// Copy the specified GitHooks directory into the project's git
-		// hook directory.  We walk the file system, creating directories
-		// and copying files as we encounter them.
-		copyFn := func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			relPath, err := filepath.Rel(op.Project().GitHooks, path)
-			if err != nil {
-				return err
-			}
-			dst := filepath.Join(gitHooksDstDir, relPath)
-			if info.IsDir() {
-				return s.MkdirAll(dst, 0755).Done()
-			}
-			src, err := s.ReadFile(path)
-			if err != nil {
-				return err
-			}
-			// The file *must* be executable to be picked up by git.
-			return s.WriteFile(dst, src, 0755).Done()
-		}
-		if err := filepath.Walk(op.Project().GitHooks, copyFn); err != nil {
+		// Install the git hooks alongside any hooks the developer has installed
+		// locally.  This also cleans up hooks that were removed from the
+		// manifest, so it must run for unchanged projects and for projects
+		// without hooks.
+		if err := installGitHooks(jirix, op.Project()); err != nil {
 			return err
 		}
 	}
@@ -1849,7 +2233,7 @@ func (op createOperation) Run(jirix *jiri.X) (e error) {
 		Rename(tmpDir, op.destination).Done(); err != nil {
 		return err
 	}
-	return syncProjectMaster(jirix, op.project)
+	return syncProjectMaster(jirix, op.project, true)
 }
 
 func (op createOperation) String() string {
@@ -1936,6 +2320,9 @@ func (op deleteOperation) Test(jirix *jiri.X, updates *fsUpdates) error {
 // moveOperation represents the relocation of a project.
 type moveOperation struct {
 	commonOperation
+	// fetch determines whether the project is fetched from its remote
+	// before being advanced.
+	fetch bool
 }
 
 func (op moveOperation) Kind() string {
@@ -1950,7 +2337,7 @@ func (op moveOperation) Run(jirix *jiri.X) error {
 	if err := reportNonMaster(jirix, op.project); err != nil {
 		return err
 	}
-	if err := syncProjectMaster(jirix, op.project); err != nil {
+	if err := syncProjectMaster(jirix, op.project, op.fetch); err != nil {
 		return err
 	}
 	return writeMetadata(jirix, op.project, op.project.Path)
@@ -1982,6 +2369,9 @@ func (op moveOperation) Test(jirix *jiri.X, updates *fsUpdates) error {
 // updateOperation represents the update of a project.
 type updateOperation struct {
 	commonOperation
+	// fetch determines whether the project is fetched from its remote
+	// before being advanced.
+	fetch bool
 }
 
 func (op updateOperation) Kind() string {
@@ -1991,7 +2381,7 @@ func (op updateOperation) Run(jirix *jiri.X) error {
 	if err := reportNonMaster(jirix, op.project); err != nil {
 		return err
 	}
-	if err := syncProjectMaster(jirix, op.project); err != nil {
+	if err := syncProjectMaster(jirix, op.project, op.fetch); err != nil {
 		return err
 	}
 	return writeMetadata(jirix, op.project, op.project.Path)
@@ -2076,7 +2466,7 @@ func (ops operations) Swap(i, j int) {
 // system and manifest file respectively) and outputs a collection of
 // operations that describe the actions needed to update the target
 // projects.
-func computeOperations(localProjects, remoteProjects Projects, gc bool) operations {
+func computeOperations(localProjects, remoteProjects Projects, gc, fetch bool) operations {
 	result := operations{}
 	allProjects := map[ProjectKey]bool{}
 	for _, p := range localProjects {
@@ -2093,13 +2483,13 @@ func computeOperations(localProjects, remoteProjects Projects, gc bool) operatio
 		if project, ok := remoteProjects[key]; ok {
 			remote = &project
 		}
-		result = append(result, computeOp(local, remote, gc))
+		result = append(result, computeOp(local, remote, gc, fetch))
 	}
 	sort.Sort(result)
 	return result
 }
 
-func computeOp(local, remote *Project, gc bool) operation {
+func computeOp(local, remote *Project, gc, fetch bool) operation {
 	switch {
 	case local == nil && remote != nil:
 		return createOperation{commonOperation{
@@ -2122,13 +2512,13 @@ func computeOp(local, remote *Project, gc bool) operation {
 				destination: remote.Path,
 				project:     *remote,
 				source:      local.Path,
-			}}
+			}, fetch}
 		case local.Revision != remote.Revision:
 			return updateOperation{commonOperation{
 				destination: remote.Path,
 				project:     *remote,
 				source:      local.Path,
-			}}
+			}, fetch}
 		default:
 			return nullOperation{commonOperation{
 				destination: remote.Path,
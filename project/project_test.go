@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"sort"
@@ -230,6 +231,112 @@ func TestUpdateUniverseSimple(t *testing.T) {
 	}
 }
 
+// TestUpdateProjects checks that UpdateProjects only updates the selected
+// projects, and doesn't fetch in local mode.
+func TestUpdateProjects(t *testing.T) {
+	localProjects, fake, cleanup := setupUniverse(t)
+	defer cleanup()
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	p1, p2 := localProjects[1], localProjects[2]
+	writeReadme(t, fake.X, fake.Projects[p1.Name], "new readme")
+	writeReadme(t, fake.X, fake.Projects[p2.Name], "new readme")
+
+	// Select a project by name.
+	if err := project.UpdateProjects(fake.X, []string{p1.Name}, false); err != nil {
+		t.Fatal(err)
+	}
+	checkReadme(t, fake.X, p1, "new readme")
+	checkReadme(t, fake.X, p2, "initial readme")
+
+	// Local mode doesn't fetch the new revision...
+	if err := project.UpdateProjects(fake.X, []string{p2.Path}, true); err != nil {
+		t.Fatal(err)
+	}
+	checkReadme(t, fake.X, p2, "initial readme")
+	// ...but applies it once it has been fetched.
+	if err := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(p2.Path)).Fetch("origin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.UpdateProjects(fake.X, []string{p2.Path}, true); err != nil {
+		t.Fatal(err)
+	}
+	checkReadme(t, fake.X, p2, "new readme")
+
+	if err := project.UpdateProjects(fake.X, []string{"no-such-project"}, false); err == nil {
+		t.Errorf("expected UpdateProjects of a missing project to fail")
+	}
+}
+
+// TestUpdateUniverseOffline checks that UpdateUniverse doesn't fetch in offline
+// mode, and fails clearly when it needs objects that aren't available locally.
+func TestUpdateUniverseOffline(t *testing.T) {
+	localProjects, fake, cleanup := setupUniverse(t)
+	defer cleanup()
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	p := localProjects[1]
+	manifestDir := filepath.Join(fake.X.Root, "manifest")
+	fetch := func(dir string) {
+		if err := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(dir)).Fetch("origin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Pin the project to a new remote revision, and only fetch the manifest.
+	writeReadme(t, fake.X, fake.Projects[p.Name], "new readme")
+	rev, err := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(fake.Projects[p.Name])).CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := fake.ReadRemoteManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, mp := range m.Projects {
+		if mp.Name == p.Name {
+			m.Projects[i].Revision = rev
+		}
+	}
+	if err := fake.WriteRemoteManifest(m); err != nil {
+		t.Fatal(err)
+	}
+	fetch(manifestDir)
+
+	fake.X.Offline = true
+	if err := fake.UpdateUniverse(false); err == nil || !strings.Contains(err.Error(), "not available locally") {
+		t.Errorf("expected missing revision error, got %v", err)
+	}
+	checkReadme(t, fake.X, p, "initial readme")
+
+	// Once the revision has been fetched, the update succeeds offline.
+	fetch(p.Path)
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	checkReadme(t, fake.X, p, "new readme")
+
+	// New projects can't be created offline.
+	fake.X.Offline = false
+	if err := fake.CreateRemoteProject("new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.AddProject(project.Project{
+		Name:   "new",
+		Path:   filepath.Join(fake.X.Root, "new"),
+		Remote: fake.Projects["new"],
+	}); err != nil {
+		t.Fatal(err)
+	}
+	fetch(manifestDir)
+	fake.X.Offline = true
+	if err := fake.UpdateUniverse(false); err == nil || !strings.Contains(err.Error(), "can't be created without fetching") {
+		t.Errorf("expected a create error, got %v", err)
+	}
+}
+
 // TestUpdateUniverseWithRevision checks that UpdateUniverse will pull remote
 // projects at the specified revision.
 func TestUpdateUniverseWithRevision(t *testing.T) {
@@ -465,6 +572,99 @@ func TestUpdateUniverseRemoteBranch(t *testing.T) {
 	checkReadme(t, fake.X, localProjects[1], "non-master commit")
 }
 
+// setProjectGitHooks points the githooks attribute of the named project in
+// the remote manifest to the given directory.
+func setProjectGitHooks(t *testing.T, fake *jiritest.FakeJiriRoot, name, dir string) {
+	m, err := fake.ReadRemoteManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects := []project.Project{}
+	for _, p := range m.Projects {
+		if p.Name == name {
+			p.GitHooks = dir
+		}
+		projects = append(projects, p)
+	}
+	m.Projects = projects
+	if err := fake.WriteRemoteManifest(m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUpdateUniverseGitHooks checks that UpdateUniverse installs the git hooks
+// from the manifest without clobbering hooks installed by the developer, and
+// that hooks removed from the manifest are cleaned up.
+func TestUpdateUniverseGitHooks(t *testing.T) {
+	localProjects, fake, cleanup := setupUniverse(t)
+	defer cleanup()
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	p := localProjects[1]
+	gitDir := filepath.Join(p.Path, ".git")
+	logFile := filepath.Join(fake.X.Root, "hooks.log")
+	writeHook := func(path, msg string) {
+		script := fmt.Sprintf("#!/bin/sh\necho %s $1 >> %s\n", msg, logFile)
+		if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Install a local hook, then add hooks to the manifest.
+	userHook := filepath.Join(gitDir, "hooks", "pre-commit")
+	writeHook(userHook, "local")
+	hooksDir := filepath.Join(fake.X.Root, "githooks")
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeHook(filepath.Join(hooksDir, "pre-commit"), "jiri")
+	writeHook(filepath.Join(hooksDir, "post-merge"), "jiri")
+	setProjectGitHooks(t, fake, p.Name, hooksDir)
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "hooks.local", "pre-commit")); err != nil {
+		t.Fatalf("local hook was not preserved: %v", err)
+	}
+	// Updating again must not move the dispatcher into hooks.local.
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(userHook, "arg").CombinedOutput(); err != nil {
+		t.Fatalf("running %v failed: %v\n%s", userHook, err, out)
+	}
+	got, err := ioutil.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "jiri arg\nlocal arg\n"; string(got) != want {
+		t.Errorf("unexpected hook output: got %q, want %q", got, want)
+	}
+
+	// Remove the hooks from the manifest and check that the local hook is
+	// restored and the other hook is removed.
+	setProjectGitHooks(t, fake, p.Name, "")
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ioutil.ReadFile(userHook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "echo local") {
+		t.Errorf("local hook was not restored, got:\n%s", got)
+	}
+	for _, path := range []string{
+		filepath.Join(gitDir, "hooks", "post-merge"),
+		filepath.Join(gitDir, "hooks.jiri"),
+	} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %v to be removed, got %v", path, err)
+		}
+	}
+}
+
 func TestFileImportCycle(t *testing.T) {
 	jirix, cleanup := jiritest.NewX(t)
 	defer cleanup()
@@ -672,6 +872,22 @@ func TestManifestToFromBytes(t *testing.T) {
 						Revision:     "rev2",
 					},
 				},
+				Packages: []project.Package{
+					{
+						Name:   "package1",
+						Path:   "path3",
+						URL:    "https://example.com/package1.tar.gz",
+						SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+						Format: "tar.gz",
+					},
+					{
+						Name:   "package2",
+						Path:   "path4",
+						URL:    "https://example.com/package2",
+						SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+						Format: "zip",
+					},
+				},
 				Tools: []project.Tool{
 					{
 						Data:    "tooldata",
@@ -690,6 +906,10 @@ func TestManifestToFromBytes(t *testing.T) {
     <project name="project1" path="path1" remote="remote1" gerrithost="https://test-review.googlesource.com" githooks="path/to/githooks" runhook="path/to/hook"/>
     <project name="project2" path="path2" remote="remote2" remotebranch="branch2" revision="rev2"/>
   </projects>
+  <packages>
+    <package name="package1" path="path3" url="https://example.com/package1.tar.gz" sha256="e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"/>
+    <package name="package2" path="path4" url="https://example.com/package2" sha256="e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" format="zip"/>
+  </packages>
   <tools>
     <tool data="tooldata" name="tool" project="toolproject"/>
   </tools>
@@ -0,0 +1,144 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/collect"
+	"fuchsia.googlesource.com/jiri/gitutil"
+)
+
+// RebaseStatus describes the outcome of rebasing a local branch.
+type RebaseStatus string
+
+const (
+	RebaseDone     = RebaseStatus("rebased")
+	RebaseSkipped  = RebaseStatus("skipped")
+	RebaseConflict = RebaseStatus("conflict")
+)
+
+// BranchRebase records the outcome of rebasing a local branch of a project
+// onto master.
+type BranchRebase struct {
+	Project string
+	Branch  string
+	Status  RebaseStatus
+	// Reason explains why the branch was skipped or why the rebase failed.
+	Reason string
+}
+
+// RebaseBranches rebases the local branches of the given projects onto their
+// master branch, typically after master has been updated.  If all is false,
+// only the branches that track master or origin/master are rebased.
+//
+// Projects with uncommitted changes are skipped.  If a rebase runs into
+// conflicts, it is aborted, leaving the branch as it was.  Each project is
+// left on the branch it was on.  The results are sorted by project and branch.
+func RebaseBranches(jirix *jiri.X, projects Projects, all bool) ([]BranchRebase, error) {
+	jirix.TimerPush("rebase branches")
+	defer jirix.TimerPop()
+
+	var results []BranchRebase
+	for _, p := range projects {
+		projectResults, err := rebaseProjectBranches(jirix, p, all)
+		if err != nil {
+			return nil, fmt.Errorf("error rebasing branches of project %q: %v", p.Name, err)
+		}
+		results = append(results, projectResults...)
+	}
+	sort.Sort(branchRebases(results))
+	return results, nil
+}
+
+func rebaseProjectBranches(jirix *jiri.X, project Project, all bool) (_ []BranchRebase, e error) {
+	git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(project.Path))
+	branches, current, err := git.GetBranches()
+	if err != nil {
+		return nil, err
+	}
+	var candidates []string
+	for _, branch := range branches {
+		// Skip master and detached heads.
+		if branch == "master" || strings.HasPrefix(branch, "(") {
+			continue
+		}
+		if !all {
+			upstream, err := git.UpstreamBranch(branch)
+			if err != nil {
+				return nil, err
+			}
+			if upstream != "master" && upstream != "origin/master" {
+				continue
+			}
+		}
+		candidates = append(candidates, branch)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	result := func(branch string, status RebaseStatus, reason string) BranchRebase {
+		return BranchRebase{Project: project.Name, Branch: branch, Status: status, Reason: reason}
+	}
+	var results []BranchRebase
+	uncommitted, err := git.HasUncommittedChanges()
+	if err != nil {
+		return nil, err
+	}
+	if uncommitted {
+		for _, branch := range candidates {
+			results = append(results, result(branch, RebaseSkipped, "uncommitted changes"))
+		}
+		return results, nil
+	}
+
+	// Return to the original branch, or revision if the head is detached,
+	// when done.
+	original := current
+	if strings.HasPrefix(current, "(") {
+		if original, err = git.CurrentRevision(); err != nil {
+			return nil, err
+		}
+	}
+	defer collect.Error(func() error { return git.CheckoutBranch(original) }, &e)
+
+	for _, branch := range candidates {
+		behind, err := git.CountCommits("master", branch)
+		if err != nil {
+			return nil, err
+		}
+		if behind == 0 {
+			results = append(results, result(branch, RebaseSkipped, "up to date"))
+			continue
+		}
+		if err := git.CheckoutBranch(branch); err != nil {
+			return nil, err
+		}
+		if err := git.Rebase("master"); err != nil {
+			if err := git.RebaseAbort(); err != nil {
+				return nil, err
+			}
+			results = append(results, result(branch, RebaseConflict, "rebase aborted"))
+			continue
+		}
+		results = append(results, result(branch, RebaseDone, ""))
+	}
+	return results, nil
+}
+
+type branchRebases []BranchRebase
+
+func (rs branchRebases) Len() int      { return len(rs) }
+func (rs branchRebases) Swap(i, j int) { rs[i], rs[j] = rs[j], rs[i] }
+func (rs branchRebases) Less(i, j int) bool {
+	if rs[i].Project != rs[j].Project {
+		return rs[i].Project < rs[j].Project
+	}
+	return rs[i].Branch < rs[j].Branch
+}
@@ -0,0 +1,95 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri/gitutil"
+	"fuchsia.googlesource.com/jiri/project"
+)
+
+// TestRebaseBranches checks that RebaseBranches rebases the selected local
+// branches onto master, and aborts rebases that run into conflicts.
+func TestRebaseBranches(t *testing.T) {
+	localProjects, fake, cleanup := setupUniverse(t)
+	defer cleanup()
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	p := localProjects[1]
+	git := gitutil.New(fake.X.NewSeq(), gitutil.RootDirOpt(p.Path))
+	commitNewFile := func(name string) {
+		if err := ioutil.WriteFile(filepath.Join(p.Path, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		commitFile(t, fake.X, p.Path, name, "add "+name)
+	}
+
+	// Create a branch that tracks master, one that doesn't, and one that
+	// conflicts with the upcoming change to master.
+	for _, branch := range []string{"tracked", "untracked", "conflict"} {
+		if err := git.CreateBranch(branch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, branch := range []string{"tracked", "conflict"} {
+		if err := git.SetUpstream(branch, "master"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkout := func(branch string) {
+		if err := git.CheckoutBranch(branch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkout("tracked")
+	commitNewFile("tracked")
+	checkout("untracked")
+	commitNewFile("untracked")
+	checkout("conflict")
+	writeReadme(t, fake.X, p.Path, "conflicting readme")
+	checkout("master")
+
+	writeReadme(t, fake.X, fake.Projects[p.Name], "new readme")
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	projects := project.Projects{p.Key(): p}
+
+	results, err := project.RebaseBranches(fake.X, projects, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []project.BranchRebase{
+		{Project: p.Name, Branch: "conflict", Status: project.RebaseConflict, Reason: "rebase aborted"},
+		{Project: p.Name, Branch: "tracked", Status: project.RebaseDone},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %#v, want %#v", results, want)
+	}
+	if behind, err := git.CountCommits("master", "tracked"); err != nil || behind != 0 {
+		t.Errorf("tracked branch is %d commits behind master: %v", behind, err)
+	}
+	if branch, err := git.CurrentBranchName(); err != nil || branch != "master" {
+		t.Errorf("got current branch %q, want master: %v", branch, err)
+	}
+
+	results, err = project.RebaseBranches(fake.X, projects, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []project.BranchRebase{
+		{Project: p.Name, Branch: "conflict", Status: project.RebaseConflict, Reason: "rebase aborted"},
+		{Project: p.Name, Branch: "tracked", Status: project.RebaseSkipped, Reason: "up to date"},
+		{Project: p.Name, Branch: "untracked", Status: project.RebaseDone},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got %#v, want %#v", results, want)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/collect"
+)
+
+// buildToolRecipe builds a tool that specifies a build command, and places
+// the resulting artifact into outputDir under the tool name.
+//
+// The build runs in a sandbox: a temporary copy of the tool project, without
+// its git and jiri metadata, so that build products never end up in the
+// project itself.  TMPDIR points to a scratch directory inside the sandbox.
+func buildToolRecipe(jirix *jiri.X, project Project, tool Tool, outputDir string) (e error) {
+	s := jirix.NewSeq()
+	sandbox, err := s.TempDir("", "tmp-jiri-tool-"+tool.Name)
+	if err != nil {
+		return fmt.Errorf("TempDir() failed: %v", err)
+	}
+	defer collect.Error(func() error { return jirix.NewSeq().RemoveAll(sandbox).Done() }, &e)
+	srcDir, tmpDir := filepath.Join(sandbox, "src"), filepath.Join(sandbox, "tmp")
+	if err := s.MkdirAll(tmpDir, 0755).Done(); err != nil {
+		return err
+	}
+	if err := copySourceTree(project.Path, srcDir); err != nil {
+		return fmt.Errorf("copying %v failed: %v", project.Path, err)
+	}
+
+	env := map[string]string{"TMPDIR": tmpDir}
+	var stderr bytes.Buffer
+	if err := s.Dir(srcDir).Env(env).Capture(ioutil.Discard, &stderr).Last("sh", "-c", tool.Build); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%v: %v", tool.Build, msg)
+		}
+		return fmt.Errorf("%v: %v", tool.Build, err)
+	}
+
+	artifact := filepath.Join(srcDir, filepath.FromSlash(tool.Output))
+	if !strings.HasPrefix(artifact, srcDir+string(filepath.Separator)) {
+		return fmt.Errorf("output %q is outside of project %q", tool.Output, project.Name)
+	}
+	data, err := s.ReadFile(artifact)
+	if err != nil {
+		return fmt.Errorf("build did not produce %v: %v", tool.Output, err)
+	}
+	dst := filepath.Join(outputDir, tool.Name)
+	return s.MkdirAll(outputDir, 0755).WriteFile(dst, data, 0755).Chmod(dst, 0755).Done()
+}
+
+// copySourceTree copies the directory tree rooted at src to dst, preserving
+// file modes and symbolic links.  Git and jiri metadata are skipped.
+func copySourceTree(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relPath)
+		switch {
+		case info.IsDir():
+			if name := info.Name(); path != src && (name == ".git" || name == jiri.ProjectMetaDir) {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		// Skip sockets, devices and other special files.
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) (e error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer collect.Error(in.Close, &e)
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer collect.Error(out.Close, &e)
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -71,6 +71,8 @@ func GetProjectStates(jirix *jiri.X, checkDirty bool) (map[ProjectKey]*ProjectSt
 	if err != nil {
 		return nil, err
 	}
+	jirix.TimerPush("project states")
+	defer jirix.TimerPop()
 	states := make(map[ProjectKey]*ProjectState, len(projects))
 	sem := make(chan error, len(projects))
 	for key, project := range projects {
@@ -78,8 +80,15 @@ func GetProjectStates(jirix *jiri.X, checkDirty bool) (map[ProjectKey]*ProjectSt
 			Project: project,
 		}
 		states[key] = state
-		// jirix is not threadsafe, so we make a clone for each goroutine.
-		go setProjectState(jirix.Clone(tool.ContextOpts{}), state, checkDirty, sem)
+		// jirix is not threadsafe, so we make a clone for each goroutine, which
+		// times its work with a timer of its own.
+		forkx := jirix.Clone(tool.ContextOpts{Timer: jirix.TimerFork(project.Name)})
+		go func() {
+			ch := make(chan error, 1)
+			setProjectState(forkx, state, checkDirty, ch)
+			forkx.TimerFinish()
+			sem <- <-ch
+		}()
 	}
 	for _ = range projects {
 		err := <-sem
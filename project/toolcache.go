@@ -0,0 +1,276 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fuchsia.googlesource.com/jiri"
+	"fuchsia.googlesource.com/jiri/collect"
+	"fuchsia.googlesource.com/jiri/gitutil"
+	"fuchsia.googlesource.com/jiri/metadata"
+	"fuchsia.googlesource.com/jiri/runutil"
+)
+
+// toolCacheDir returns the directory that records, for every tool installed
+// in $JIRI_ROOT/.jiri_root/bin, the key of the inputs it was built from and
+// the metadata embedded in it.
+func toolCacheDir(jirix *jiri.X) string {
+	return filepath.Join(jirix.RootMetaDir(), "tool_cache")
+}
+
+// isBuildable returns true iff jiri knows how to build the given tool.
+func isBuildable(tool Tool) bool {
+	return tool.Package != "" || tool.Build != ""
+}
+
+// goVersion returns the output of "go version", or the empty string if go is
+// not available.
+func goVersion(jirix *jiri.X) string {
+	var out bytes.Buffer
+	if err := jirix.NewSeq().Capture(&out, ioutil.Discard).Last("go", "version"); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// toolKeys computes, for each of the given tools, a key that identifies the
+// inputs of its build: the revision of its project, the Go version and the
+// tool definition.  The revision is that of the given branch, or of the
+// current checkout if branch is "HEAD".  Tools that can't be cached, e.g.
+// because their project has uncommitted changes, get an empty key.
+func toolKeys(jirix *jiri.X, projects Projects, tools Tools, branch string) (map[string]string, error) {
+	keys := map[string]string{}
+	version := ""
+	for name, tool := range tools {
+		if !isBuildable(tool) {
+			continue
+		}
+		toolProject, err := projects.FindUnique(tool.Project)
+		if err != nil {
+			// Let BuildTools report the error.
+			keys[name] = ""
+			continue
+		}
+		revision, err := toolRevision(jirix, toolProject, branch)
+		if err != nil {
+			return nil, err
+		}
+		if revision == "" {
+			keys[name] = ""
+			continue
+		}
+		if version == "" {
+			version = goVersion(jirix)
+		}
+		definition, err := xml.Marshal(tool)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s\n%s\n%s\n", revision, version, definition)
+		keys[name] = hex.EncodeToString(hash.Sum(nil))
+	}
+	return keys, nil
+}
+
+// toolRevision returns the revision of the given branch of the project, or of
+// the current checkout if branch is "HEAD".  The empty string is returned if
+// the current checkout has uncommitted changes or untracked files.
+func toolRevision(jirix *jiri.X, project Project, branch string) (string, error) {
+	git := gitutil.New(jirix.NewSeq(), gitutil.RootDirOpt(project.Path))
+	if branch != "HEAD" {
+		return git.CurrentRevisionOfBranch(branch)
+	}
+	uncommitted, err := git.HasUncommittedChanges()
+	if err != nil {
+		return "", err
+	}
+	untracked, err := git.HasUntrackedFiles()
+	if err != nil {
+		return "", err
+	}
+	if uncommitted || untracked {
+		return "", nil
+	}
+	return git.CurrentRevision()
+}
+
+// outOfDateTools returns the buildable tools whose installed binary is
+// missing or was built from inputs other than those identified by keys.
+func outOfDateTools(jirix *jiri.X, tools Tools, keys map[string]string) (Tools, error) {
+	s := jirix.NewSeq()
+	result := Tools{}
+	for name, tool := range tools {
+		if !isBuildable(tool) {
+			continue
+		}
+		key := keys[name]
+		if key != "" {
+			installed, err := s.IsFile(filepath.Join(jirix.BinDir(), name))
+			if err != nil {
+				return nil, err
+			}
+			data, err := s.ReadFile(filepath.Join(toolCacheDir(jirix), name))
+			if err != nil && !runutil.IsNotExist(err) {
+				return nil, err
+			}
+			if installed && string(data) == key {
+				continue
+			}
+		}
+		result[name] = tool
+	}
+	return result, nil
+}
+
+// recordToolKeys records the keys and metadata of the given tools after they
+// have been installed.  Tools without a key have their key removed, so that
+// they are rebuilt next time.
+func recordToolKeys(jirix *jiri.X, tools Tools, keys map[string]string, mds map[string]*metadata.T) error {
+	s := jirix.NewSeq()
+	dir := toolCacheDir(jirix)
+	if err := s.MkdirAll(dir, 0755).Done(); err != nil {
+		return err
+	}
+	for name := range tools {
+		file := filepath.Join(dir, name)
+		if key := keys[name]; key != "" {
+			if err := s.WriteFile(file, []byte(key), 0644).Done(); err != nil {
+				return err
+			}
+		} else if err := s.Remove(file).Done(); err != nil && !runutil.IsNotExist(err) {
+			return err
+		}
+		if md := mds[name]; md != nil {
+			if err := recordToolMetadata(jirix, name, md); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// toolMetadataSuffix is appended to the tool name to form the name of the file
+// in the tool cache that records the metadata of the installed tool.
+const toolMetadataSuffix = ".metadata"
+
+// toolMetadataFile is the contents of the file in the tool cache that records
+// the metadata of an installed tool.  The size and modification time of the
+// binary identify the binary that the metadata was embedded in, so that the
+// metadata isn't reported for a binary that replaced it.
+type toolMetadataFile struct {
+	XMLName  xml.Name `xml:"tool"`
+	Size     int64    `xml:"size,attr"`
+	ModTime  string   `xml:"modtime,attr"`
+	Metadata string   `xml:",innerxml"`
+}
+
+// newToolMetadataFile returns the record of the given metadata for the binary
+// described by info.
+func newToolMetadataFile(info os.FileInfo, md string) toolMetadataFile {
+	return toolMetadataFile{
+		Size:     info.Size(),
+		ModTime:  info.ModTime().UTC().Format(time.RFC3339Nano),
+		Metadata: md,
+	}
+}
+
+// recordToolMetadata records the metadata embedded in the installed tool with
+// the given name.
+func recordToolMetadata(jirix *jiri.X, name string, md *metadata.T) error {
+	s := jirix.NewSeq()
+	info, err := s.Stat(filepath.Join(jirix.BinDir(), name))
+	if err != nil {
+		return err
+	}
+	data, err := xml.MarshalIndent(newToolMetadataFile(info, "\n"+md.ToXML()+"\n"), "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.WriteFile(filepath.Join(toolCacheDir(jirix), name+toolMetadataSuffix), append(data, '\n'), 0644).Done()
+}
+
+// InstalledToolMetadata returns the metadata that was embedded in the tool
+// with the given name when it was built and installed into
+// $JIRI_ROOT/.jiri_root/bin.  If no metadata was recorded, e.g. because the
+// tool wasn't installed by jiri, or if the binary has changed since then, nil
+// is returned.
+func InstalledToolMetadata(jirix *jiri.X, name string) (*metadata.T, error) {
+	s := jirix.NewSeq()
+	info, err := s.Stat(filepath.Join(jirix.BinDir(), name))
+	if err != nil {
+		if runutil.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := s.ReadFile(filepath.Join(toolCacheDir(jirix), name+toolMetadataSuffix))
+	if err != nil {
+		if runutil.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var file toolMetadataFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid tool metadata file for %q: %v", name, err)
+	}
+	if want := newToolMetadataFile(info, ""); file.Size != want.Size || file.ModTime != want.ModTime {
+		return nil, nil
+	}
+	return metadata.FromXML([]byte(file.Metadata))
+}
+
+// RebuildTools builds the given tools from the current checkout of their
+// projects and installs them into $JIRI_ROOT/.jiri_root/bin.  Tools that are
+// up to date with respect to their project revision, the Go version and their
+// definition are skipped, unless force is set.
+func RebuildTools(jirix *jiri.X, projects Projects, tools Tools, force bool) (e error) {
+	keys, err := toolKeys(jirix, projects, tools, "HEAD")
+	if err != nil {
+		return err
+	}
+	toolsToBuild := Tools{}
+	if force {
+		for name, tool := range tools {
+			if isBuildable(tool) {
+				toolsToBuild[name] = tool
+			}
+		}
+	} else if toolsToBuild, err = outOfDateTools(jirix, tools, keys); err != nil {
+		return err
+	}
+	if len(toolsToBuild) == 0 {
+		return nil
+	}
+
+	// Create a temporary directory in which tools will be built.
+	s := jirix.NewSeq()
+	tmpDir, err := s.TempDir("", "tmp-jiri-rebuild")
+	if err != nil {
+		return fmt.Errorf("TempDir() failed: %v", err)
+	}
+	defer collect.Error(func() error { return jirix.NewSeq().RemoveAll(tmpDir).Done() }, &e)
+
+	// Build and install tools.
+	mds, err := buildTools(jirix, projects, toolsToBuild, tmpDir, jirix.JiriManifestFile())
+	if err != nil {
+		return err
+	}
+	if err := InstallTools(jirix, tmpDir); err != nil {
+		return err
+	}
+	return recordToolKeys(jirix, toolsToBuild, keys, mds)
+}
@@ -51,10 +51,18 @@ func Function(ctx *tool.Context, fn func() error, opts ...RetryOpt) error {
 		if err = fn(); err == nil {
 			return nil
 		}
+		if ctx.Ctx().Err() != nil {
+			// Don't retry once cancelled.
+			return err
+		}
 		fmt.Fprintf(ctx.Stderr(), "%v\n", err)
 		if i < attempts {
 			fmt.Fprintf(ctx.Stdout(), "Wait for %v before next attempt...\n", interval)
-			time.Sleep(interval)
+			select {
+			case <-time.After(interval):
+			case <-ctx.Ctx().Done():
+				return err
+			}
 		}
 	}
 	return fmt.Errorf("Failed %d times in a row. Last error:\n%v", attempts, err)
@@ -5,6 +5,7 @@
 package runutil
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -37,6 +38,12 @@ type opts struct {
 type executor struct {
 	indent int
 	opts   opts
+	// ctx cancels the commands and functions run by the executor.
+	ctx context.Context
+	// trace, if not nil, records the commands run by the executor.
+	trace *Trace
+	// commands, if not nil, runs the commands that the executor waits for.
+	commands Executor
 }
 
 func newExecutor(env map[string]string, stdin io.Reader, stdout, stderr io.Writer, color, verbose bool) *executor {
@@ -49,6 +56,7 @@ func newExecutor(env map[string]string, stdin io.Reader, stdout, stderr io.Write
 	}
 	return &executor{
 		indent: 0,
+		ctx:    context.Background(),
 		opts: opts{
 			color:   color,
 			env:     env,
@@ -66,12 +74,13 @@ var (
 
 // run run's the command and waits for it to finish
 func (e *executor) run(timeout time.Duration, opts opts, path string, args ...string) error {
-	_, err := e.execute(true, timeout, opts, path, args...)
+	_, _, err := e.execute(true, timeout, opts, path, args...)
 	return err
 }
 
-// start start's the command and does not wait for it to finish.
-func (e *executor) start(timeout time.Duration, opts opts, path string, args ...string) (*exec.Cmd, error) {
+// start start's the command and does not wait for it to finish.  The returned
+// tracedCommand must be passed to traceDone once the command has finished.
+func (e *executor) start(timeout time.Duration, opts opts, path string, args ...string) (*exec.Cmd, *tracedCommand, error) {
 	return e.execute(false, timeout, opts, path, args...)
 }
 
@@ -81,7 +90,10 @@ func (e *executor) function(opts opts, fn func() error, format string, args ...i
 	e.increaseIndent()
 	defer e.decreaseIndent()
 	e.printf(e.verboseStdout(opts), format, args...)
-	err := fn()
+	err := e.ctx.Err()
+	if err == nil {
+		err = fn()
+	}
 	e.printf(e.verboseStdout(opts), okOrFailed(err))
 	return err
 }
@@ -138,7 +150,7 @@ func (e *executor) call(fn func() error, format string, args ...interface{}) err
 // arguments and options. If the wait flag is set, the function waits for the
 // completion of the binary and the timeout value can optionally specify for
 // how long should the function wait before timing out.
-func (e *executor) execute(wait bool, timeout time.Duration, opts opts, path string, args ...string) (*exec.Cmd, error) {
+func (e *executor) execute(wait bool, timeout time.Duration, opts opts, path string, args ...string) (*exec.Cmd, *tracedCommand, error) {
 	e.increaseIndent()
 	defer e.decreaseIndent()
 
@@ -155,6 +167,10 @@ func (e *executor) execute(wait bool, timeout time.Duration, opts opts, path str
 		// latter is not thread-safe.
 		path = binary
 	}
+	if err := e.ctx.Err(); err != nil {
+		e.printf(e.verboseStdout(opts), "%s %s: CANCELLED", path, strings.Join(args, " "))
+		return nil, nil, err
+	}
 	command := exec.Command(path, args...)
 	command.Dir = opts.dir
 	command.Stdin = opts.stdin
@@ -173,37 +189,109 @@ func (e *executor) execute(wait bool, timeout time.Duration, opts opts, path str
 		}
 		e.printf(out, strings.Replace(strings.Join(args, " "), "%", "%%", -1))
 	}
+	tc := e.traceCommand(command)
 
 	var err error
 	switch {
+	case !wait && e.commands != nil:
+		err = fmt.Errorf("Start is not supported with an Executor")
+		e.printf(e.verboseStdout(opts), okOrFailed(err))
+		e.traceDone(tc, command, err)
+		return command, tc, err
 	case !wait:
+		if e.cancellable() {
+			e.setProcessGroup(opts, command)
+		}
 		err = command.Start()
 		e.printf(e.verboseStdout(opts), okOrFailed(err))
+		if err != nil {
+			e.traceDone(tc, command, err)
+		}
+		return command, tc, err
 
-	case timeout == 0:
-		err = command.Run()
-		e.printf(e.verboseStdout(opts), okOrFailed(err))
+	case e.commands != nil:
+		ran := false
+		err = e.commands.Execute(command, func(command *exec.Cmd) error {
+			ran = true
+			return e.wait(timeout, opts, command)
+		})
+		if !ran {
+			e.printf(e.verboseStdout(opts), okOrFailed(err))
+		}
 	default:
-		err = e.timedCommand(timeout, opts, command)
-		// Verbose output handled in timedCommand.
+		err = e.wait(timeout, opts, command)
+	}
+	e.traceDone(tc, command, err)
+	return command, nil, err
+}
+
+// wait runs the command as a subprocess and waits for it to finish.
+func (e *executor) wait(timeout time.Duration, opts opts, command *exec.Cmd) error {
+	if timeout == 0 && !e.cancellable() {
+		err := command.Run()
+		e.printf(e.verboseStdout(opts), okOrFailed(err))
+		return err
+	}
+	// Verbose output handled in timedCommand.
+	return e.timedCommand(timeout, opts, command)
+}
+
+// cancellable returns whether the context of the executor can be cancelled.
+func (e *executor) cancellable() bool {
+	return e.ctx.Done() != nil
+}
+
+// setProcessGroup makes the process of the command a new process group leader,
+// so that the command and its children can be terminated together, unless the
+// command reads from a terminal.  A process in a background process group that
+// reads from the terminal would be stopped, and the terminal already signals
+// the whole foreground process group anyway.
+func (e *executor) setProcessGroup(opts opts, command *exec.Cmd) {
+	if !isReaderTTY(opts.stdin) {
+		command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+}
+
+// terminate terminates the command, and its process group if it leads one.
+func (e *executor) terminate(opts opts, command *exec.Cmd) {
+	if command.SysProcAttr != nil && command.SysProcAttr.Setpgid {
+		e.terminateProcessGroup(opts, command)
+		return
+	}
+	if err := command.Process.Kill(); err != nil {
+		e.printf(e.stderrFromOpts(opts), "Kill(%v) failed: %v", command.Process.Pid, err)
+	}
+}
+
+// watch terminates the command when the context of the executor is cancelled
+// before exited is closed.
+func (e *executor) watch(opts opts, command *exec.Cmd, exited <-chan struct{}) {
+	select {
+	case <-e.ctx.Done():
+		e.terminate(opts, command)
+	case <-exited:
 	}
-	return command, err
 }
 
 // timedCommand executes the given command, terminating it forcefully
-// if it is still running after the given timeout elapses.
+// if it is still running after the given timeout elapses, or once the
+// context of the executor is cancelled.  A zero timeout means no timeout.
 func (e *executor) timedCommand(timeout time.Duration, opts opts, command *exec.Cmd) error {
-	// Make the process of this command a new process group leader
-	// to facilitate clean up of processes that time out.
-	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-	// Kill this process group explicitly when receiving SIGTERM
-	// or SIGINT signals.
-	sigchan := make(chan os.Signal, 1)
-	signal.Notify(sigchan, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT)
-	go func() {
-		<-sigchan
-		e.terminateProcessGroup(opts, command)
-	}()
+	if e.cancellable() {
+		e.setProcessGroup(opts, command)
+	} else {
+		// Make the process of this command a new process group leader
+		// to facilitate clean up of processes that time out.
+		command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		// Kill this process group explicitly when receiving SIGTERM
+		// or SIGINT signals.
+		sigchan := make(chan os.Signal, 1)
+		signal.Notify(sigchan, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT)
+		go func() {
+			<-sigchan
+			e.terminateProcessGroup(opts, command)
+		}()
+	}
 	if err := command.Start(); err != nil {
 		e.printf(e.verboseStdout(opts), "FAILED: %v", err)
 		return err
@@ -212,14 +300,23 @@ func (e *executor) timedCommand(timeout time.Duration, opts opts, command *exec.
 	go func() {
 		done <- command.Wait()
 	}()
+	var timer <-chan time.Time
+	if timeout != 0 {
+		timer = time.After(timeout)
+	}
 	select {
-	case <-time.After(timeout):
+	case <-timer:
 		// The command has timed out.
-		e.terminateProcessGroup(opts, command)
+		e.terminate(opts, command)
 		// Allow goroutine to exit.
 		<-done
 		e.printf(e.verboseStdout(opts), "TIMED OUT")
 		return commandTimedOutErr
+	case <-e.ctx.Done():
+		e.terminate(opts, command)
+		<-done
+		e.printf(e.verboseStdout(opts), "CANCELLED")
+		return e.ctx.Err()
 	case err := <-done:
 		e.printf(e.verboseStdout(opts), okOrFailed(err))
 		return err
@@ -6,6 +6,7 @@ package runutil
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -118,6 +119,37 @@ func NewSequence(env map[string]string, stdin io.Reader, stdout, stderr io.Write
 	return s
 }
 
+// WithContext arranges for all following calls to Run, Last, Start and Call to
+// be cancelled once the given context is done.  Commands that are running are
+// terminated along with their process groups, and later calls fail with the
+// error of the context, for which IsCancelled returns true.  Unlike the other
+// modifier methods, the context isn't cleared after the next call.
+func (s Sequence) WithContext(ctx context.Context) Sequence {
+	s.r.ctx = ctx
+	return s
+}
+
+// WithTrace arranges for all following calls to Run, Last and Start to be
+// recorded in the given trace.  Unlike the other modifier methods, the trace
+// isn't cleared after the next call.
+func (s Sequence) WithTrace(t *Trace) Sequence {
+	s.r.trace = t
+	return s
+}
+
+// WithExecutor arranges for all following calls to Run and Last to run their
+// commands through the given Executor; calls to Start fail.  Unlike the other
+// modifier methods, the executor isn't cleared after the next call.
+func (s Sequence) WithExecutor(e Executor) Sequence {
+	s.r.commands = e
+	return s
+}
+
+// Context returns the context of the sequence, as set by WithContext.
+func (s Sequence) Context() context.Context {
+	return s.r.ctx
+}
+
 // RunOpts returns the value of verbose that was used to
 // create this sequence.
 func (s Sequence) RunOpts() (verbose bool) {
@@ -245,12 +277,15 @@ func (s Sequence) Error() error {
 }
 
 // TranslateExitCode translates errors from the "os/exec" package that
-// contain exit codes into cmdline.ErrExitCode errors.
+// contain exit codes, and ExitErrors, into cmdline.ErrExitCode errors.
 func TranslateExitCode(err error) error {
 	return translateExitCode(GetOriginalError(err))
 }
 
 func translateExitCode(err error) error {
+	if exit, ok := err.(*ExitError); ok {
+		return cmdline.ErrExitCode(exit.Code)
+	}
 	if exit, ok := err.(*exec.ExitError); ok {
 		if wait, ok := exit.Sys().(syscall.WaitStatus); ok {
 			if status := wait.ExitStatus(); wait.Exited() && status != 0 {
@@ -298,6 +333,15 @@ func IsPermission(err error) bool {
 	return os.IsPermission(err)
 }
 
+// IsCancelled returns a boolean indicating whether the error is a result of
+// the context of the sequence being cancelled, or reaching its deadline.
+func IsCancelled(err error) bool {
+	if we, ok := err.(*wrappedError); ok {
+		err = we.oe
+	}
+	return err == context.Canceled || err == context.DeadlineExceeded
+}
+
 // IsTimeout returns a boolean indicating whether the error is a result of
 // a timeout.
 func IsTimeout(err error) bool {
@@ -393,6 +437,19 @@ func isWriterTTY(w io.Writer) (isTTY bool) {
 	return isTTY
 }
 
+// isReaderTTY returns whether the io.Reader r is an os.File pointing at a tty.
+func isReaderTTY(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return (stat.Mode() & (os.ModeDevice | os.ModeCharDevice)) == (os.ModeDevice | os.ModeCharDevice)
+}
+
 func (s Sequence) initAndDefer(h *Handle) func() {
 	if s.stdout == nil && s.stderr == nil {
 		fout, err := ioutil.TempFile("", "seq")
@@ -542,6 +599,9 @@ type Handle struct {
 	filename       string
 	deferFn        func()
 	cmd            *exec.Cmd
+	exited         chan struct{}
+	r              *executor
+	traced         *tracedCommand
 }
 
 // Kill terminates the currently running background process.
@@ -561,6 +621,10 @@ func (h *Handle) Signal(sig os.Signal) error {
 // Wait waits for the currently running background process to terminate.
 func (h *Handle) Wait() error {
 	err := h.cmd.Wait()
+	if h.exited != nil {
+		close(h.exited)
+	}
+	h.r.traceDone(h.traced, h.cmd, err)
 	h.deferFn()
 	if len(h.filename) > 0 {
 		if err != nil {
@@ -579,10 +643,15 @@ func (s Sequence) Start(path string, args ...string) (*Handle, error) {
 	if s.err != nil {
 		return nil, s.Done()
 	}
-	h := &Handle{}
+	h := &Handle{r: s.r}
 	h.deferFn = s.initAndDefer(h)
-	cmd, err := s.r.start(s.timeout, s.getOpts(), path, args...)
-	h.cmd = cmd
+	opts := s.getOpts()
+	cmd, traced, err := s.r.start(s.timeout, opts, path, args...)
+	h.cmd, h.traced = cmd, traced
+	if err == nil && s.r.cancellable() {
+		h.exited = make(chan struct{})
+		go s.r.watch(opts, cmd, h.exited)
+	}
 	s.setError(err, fmt.Sprintf("Start(%q%s)", path, fmtStringArgs(args...)))
 	return h, s.Error()
 }
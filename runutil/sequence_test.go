@@ -7,6 +7,7 @@ package runutil_test
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -655,3 +656,107 @@ func TestStartWithOutput(t *testing.T) {
 		t.Errorf("got %v, want %v", got, want)
 	}
 }
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	seq := runutil.NewSequence(nil, nil, ioutil.Discard, ioutil.Discard, false, false).WithContext(ctx)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	// The shell runs sleep in a child process, which is terminated along
+	// with the shell.
+	err := seq.Last("sh", "-c", "sleep 10; echo done")
+	if got, want := runutil.IsCancelled(err), true; got != want {
+		t.Errorf("got IsCancelled %v for %v, want %v", got, err, want)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelling took %v", elapsed)
+	}
+
+	// Later calls fail without running anything.
+	called := false
+	err = seq.Call(func() error { called = true; return nil }, "call").Done()
+	if !runutil.IsCancelled(err) || called {
+		t.Errorf("got error %v, called %v after cancel", err, called)
+	}
+	if err := seq.Last("true"); !runutil.IsCancelled(err) {
+		t.Errorf("got error %v after cancel", err)
+	}
+
+	// Background commands are terminated as well.
+	ctx, cancel = context.WithCancel(context.Background())
+	seq = runutil.NewSequence(nil, nil, ioutil.Discard, ioutil.Discard, false, false).WithContext(ctx)
+	h, err := seq.Start("sleep", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := h.Wait(); err == nil {
+		t.Errorf("expected the background command to be terminated")
+	}
+
+	// A context that reaches its deadline cancels the sequence too.
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	seq = runutil.NewSequence(nil, nil, ioutil.Discard, ioutil.Discard, false, false).WithContext(ctx)
+	if err := seq.Last("sleep", "10"); !runutil.IsCancelled(err) {
+		t.Errorf("got error %v after the deadline, want a cancelled error", err)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	trace := runutil.NewTrace(filepath.Join(dir, "logs", "trace.jsonl"), 1<<20, 2)
+	seq := runutil.NewSequence(nil, nil, ioutil.Discard, ioutil.Discard, false, false).WithTrace(trace)
+	var out bytes.Buffer
+	if err := seq.Capture(&out, nil).Dir(dir).Env(map[string]string{"TRACE_TEST": "x"}).Last("sh", "-c", "echo $TRACE_TEST"); err != nil {
+		t.Fatal(err)
+	}
+	if err := seq.Last("sh", "-c", "echo failing; exit 3"); err == nil {
+		t.Fatal("expected an error")
+	}
+	records, err := runutil.ReadTrace(trace.Files()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(records), 2; got != want {
+		t.Fatalf("got %d records, want %d", got, want)
+	}
+	r := records[0]
+	if got, want := strings.Join(r.Args[1:], " "), "-c echo $TRACE_TEST"; got != want {
+		t.Errorf("got args %q, want %q", got, want)
+	}
+	if v := r.Env["TRACE_TEST"]; r.Dir != dir || v == nil || *v != "x" || len(r.Env) != 1 {
+		t.Errorf("got dir %q, env %v", r.Dir, r.Env)
+	}
+	if r.ExitCode != 0 || r.Error != "" || r.Output != "x\n" || out.String() != "x\n" {
+		t.Errorf("got exit code %d, error %q, output %q, captured %q", r.ExitCode, r.Error, r.Output, out.String())
+	}
+	if r := records[1]; r.ExitCode != 3 || r.Error == "" || r.Output != "failing\n" {
+		t.Errorf("got exit code %d, error %q, output %q", r.ExitCode, r.Error, r.Output)
+	}
+
+	// The trace is rotated once it grows too big, keeping 2 rotated files.
+	trace = runutil.NewTrace(filepath.Join(dir, "small.jsonl"), 1, 2)
+	for i := 0; i < 4; i++ {
+		if err := trace.Record(runutil.TraceRecord{ExitCode: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := trace.Files()
+	if got, want := len(files), 3; got != want {
+		t.Fatalf("got files %v, want %d", files, want)
+	}
+	records, err = runutil.ReadTrace(files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range records {
+		if got, want := r.ExitCode, i+1; got != want {
+			t.Errorf("record %d has exit code %d, want %d", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"fuchsia.googlesource.com/jiri/envvar"
+)
+
+// maxTraceOutput is the number of bytes of the output of a command that are
+// recorded in its TraceRecord.
+const maxTraceOutput = 4096
+
+// TraceRecord describes a command run by a Sequence.
+type TraceRecord struct {
+	// Time is when the command started.
+	Time time.Time `json:"time"`
+	// Args holds the path of the command, followed by its args.
+	Args []string `json:"args"`
+	// Dir is the working directory of the command.
+	Dir string `json:"dir,omitempty"`
+	// Env holds the environment variables of the command that differ from
+	// those of jiri; a nil value means the variable was unset.
+	Env map[string]*string `json:"env,omitempty"`
+	// DurationMs is how long the command ran, in milliseconds.
+	DurationMs int64 `json:"duration_ms"`
+	// ExitCode is the exit code of the command, or -1 if it didn't exit
+	// normally.
+	ExitCode int `json:"exit_code"`
+	// Error is the error the command failed with, if any.
+	Error string `json:"error,omitempty"`
+	// Output holds the start of the stdout and stderr of the command, if they
+	// weren't written to a terminal.
+	Output string `json:"output,omitempty"`
+	// Truncated indicates whether Output was truncated.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Trace appends a TraceRecord for each command run by the sequences that use
+// it, as a line of JSON, to a file.  Once the file grows beyond a maximum
+// size, it is rotated: it is renamed with the suffix ".1", the previous ".1"
+// file becomes ".2", and so on, up to a number of files to keep.  A Trace can
+// be shared by concurrent sequences.
+type Trace struct {
+	mu      sync.Mutex
+	file    string
+	maxSize int64
+	keep    int
+}
+
+// NewTrace returns a Trace that writes to the given file, which is rotated
+// once it grows beyond maxSize bytes, keeping the given number of rotated
+// files.
+func NewTrace(file string, maxSize int64, keep int) *Trace {
+	return &Trace{file: file, maxSize: maxSize, keep: keep}
+}
+
+// File returns the file the trace writes to.
+func (t *Trace) File() string {
+	return t.file
+}
+
+// Files returns the rotated files of the trace that exist, from oldest to
+// newest, followed by the current file if it exists.
+func (t *Trace) Files() []string {
+	var files []string
+	for i := t.keep; i >= 0; i-- {
+		file := t.rotatedFile(i)
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func (t *Trace) rotatedFile(i int) string {
+	if i == 0 {
+		return t.file
+	}
+	return fmt.Sprintf("%s.%d", t.file, i)
+}
+
+// Record appends the given record to the trace.
+func (t *Trace) Record(r TraceRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.rotate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.file), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(t.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rotate rotates the file of the trace if it has grown too big.
+func (t *Trace) rotate() error {
+	info, err := os.Stat(t.file)
+	if err != nil || info.Size() < t.maxSize {
+		return nil
+	}
+	if t.keep == 0 {
+		return os.Remove(t.file)
+	}
+	for i := t.keep - 1; i >= 0; i-- {
+		if err := os.Rename(t.rotatedFile(i), t.rotatedFile(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTrace returns the records of the given trace files, in order.
+func ReadTrace(files ...string) ([]TraceRecord, error) {
+	var records []TraceRecord
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for line := 1; scanner.Scan(); line++ {
+			var r TraceRecord
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+			}
+			records = append(records, r)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// traceBuffer holds the start of the output of a traced command.
+type traceBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *traceBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n := maxTraceOutput - b.buf.Len(); len(p) > n {
+		b.buf.Write(p[:n])
+		b.truncated = true
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// tracedCommand records a command run by an executor in its trace.
+type tracedCommand struct {
+	record TraceRecord
+	output *traceBuffer
+}
+
+// traceCommand prepares the given command to be recorded in the trace of the
+// executor, if it has one, by teeing its output unless it goes to a terminal.
+func (e *executor) traceCommand(command *exec.Cmd) *tracedCommand {
+	if e.trace == nil {
+		return nil
+	}
+	dir := command.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	tc := &tracedCommand{
+		record: TraceRecord{
+			Time: time.Now(),
+			Args: command.Args,
+			Dir:  dir,
+			Env:  envDeltas(command.Env),
+		},
+		output: &traceBuffer{},
+	}
+	if command.Stdout == nil {
+		command.Stdout = tc.output
+	} else if _, ok := command.Stdout.(*os.File); !ok {
+		command.Stdout = io.MultiWriter(command.Stdout, tc.output)
+	}
+	if command.Stderr == nil {
+		command.Stderr = tc.output
+	} else if _, ok := command.Stderr.(*os.File); !ok {
+		command.Stderr = io.MultiWriter(command.Stderr, tc.output)
+	}
+	return tc
+}
+
+// traceDone records the outcome of the traced command in the trace of the
+// executor.
+func (e *executor) traceDone(tc *tracedCommand, command *exec.Cmd, err error) {
+	if tc == nil {
+		return
+	}
+	r := tc.record
+	r.DurationMs = int64(time.Since(r.Time) / time.Millisecond)
+	r.ExitCode = -1
+	if command.ProcessState != nil {
+		if status, ok := command.ProcessState.Sys().(syscall.WaitStatus); ok && status.Exited() {
+			r.ExitCode = status.ExitStatus()
+		}
+	} else if code, ok := exitCode(err); ok {
+		// The command was replayed by an Executor.
+		r.ExitCode = code
+	} else if err == nil {
+		r.ExitCode = 0
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	tc.output.mu.Lock()
+	r.Output, r.Truncated = tc.output.buf.String(), tc.output.truncated
+	tc.output.mu.Unlock()
+	if err := e.trace.Record(r); err != nil {
+		e.printf(e.stderrFromOpts(e.opts), "failed to write trace: %v", err)
+	}
+}
+
+// envDeltas returns the variables of the given environment that differ from
+// those of the process.
+func envDeltas(env []string) map[string]*string {
+	if env == nil {
+		return nil
+	}
+	vars := envvar.VarsFromOS()
+	base := vars.ToMap()
+	cmdEnv := envvar.SliceToMap(env)
+	for key, value := range cmdEnv {
+		if old, ok := base[key]; !ok || old != value {
+			vars.Set(key, value)
+		}
+	}
+	for key := range base {
+		if _, ok := cmdEnv[key]; !ok {
+			vars.Delete(key)
+		}
+	}
+	deltas := vars.Deltas()
+	if len(deltas) == 0 {
+		return nil
+	}
+	return deltas
+}
@@ -0,0 +1,323 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Executor runs the commands of a sequence that waits for them to finish,
+// that is those of Run and Last.  It lets tests record the commands run by a
+// sequence, or replace them altogether.
+type Executor interface {
+	// Execute runs the given command.  The output of the command must be
+	// written to cmd.Stdout and cmd.Stderr, if they're not nil.  run runs the
+	// command as a subprocess, as sequences without an Executor do.
+	Execute(cmd *exec.Cmd, run func(*exec.Cmd) error) error
+}
+
+// ExitError is the error of a replayed command that exited with a non-zero
+// code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// TranscriptEntry describes a command in a Transcript.
+type TranscriptEntry struct {
+	// Args holds the base name of the command, followed by its args.
+	Args []string `json:"args"`
+	// Dir is the working directory of the command.
+	Dir string `json:"dir,omitempty"`
+	// Stdout and Stderr hold the output of the command.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// ExitCode is the exit code of the command.
+	ExitCode int `json:"exit_code,omitempty"`
+	// Error is the error of a command that failed without an exit code,
+	// such as one that couldn't be started or that timed out.
+	Error string `json:"error,omitempty"`
+}
+
+// Transcript is a list of the commands run by a sequence, in order.
+//
+// The values of variables given to the executors that record and replay a
+// transcript, such as temporary directories, are replaced by ${NAME} in the
+// transcript, so that it doesn't depend on them.
+type Transcript []TranscriptEntry
+
+// ReadTranscript reads a transcript written by WriteTranscript.
+func ReadTranscript(file string) (Transcript, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var t Transcript
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("invalid transcript %s: %v", file, err)
+	}
+	return t, nil
+}
+
+// WriteTranscript writes the given transcript to a file, as indented JSON.
+func WriteTranscript(file string, t Transcript) error {
+	if t == nil {
+		t = Transcript{}
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// substitutions replaces the values of variables with ${NAME} and back.
+type substitutions struct {
+	names  []string
+	values map[string]string
+}
+
+func newSubstitutions(vars map[string]string) substitutions {
+	s := substitutions{values: map[string]string{}}
+	for name, value := range vars {
+		if value != "" {
+			s.names = append(s.names, name)
+			s.values[name] = value
+		}
+	}
+	// Replace longer values first, in case one contains another.
+	sort.Sort(s)
+	return s
+}
+
+// Len, Swap and Less sort the names of the variables by decreasing length of
+// their values, and then by name.
+func (s substitutions) Len() int      { return len(s.names) }
+func (s substitutions) Swap(i, j int) { s.names[i], s.names[j] = s.names[j], s.names[i] }
+func (s substitutions) Less(i, j int) bool {
+	vi, vj := s.values[s.names[i]], s.values[s.names[j]]
+	if len(vi) != len(vj) {
+		return len(vi) > len(vj)
+	}
+	return s.names[i] < s.names[j]
+}
+
+// hide replaces the values of the variables in str with their names.
+func (s substitutions) hide(str string) string {
+	for _, name := range s.names {
+		str = strings.Replace(str, s.values[name], "${"+name+"}", -1)
+	}
+	return str
+}
+
+// expand replaces the names of the variables in str with their values.
+func (s substitutions) expand(str string) string {
+	for _, name := range s.names {
+		str = strings.Replace(str, "${"+name+"}", s.values[name], -1)
+	}
+	return str
+}
+
+// entry returns the transcript entry of the given command, without its
+// outcome.
+func (s substitutions) entry(cmd *exec.Cmd) TranscriptEntry {
+	e := TranscriptEntry{Dir: s.hide(cmd.Dir)}
+	for i, arg := range cmd.Args {
+		if i == 0 {
+			arg = filepath.Base(arg)
+		}
+		e.Args = append(e.Args, s.hide(arg))
+	}
+	return e
+}
+
+// RecordingExecutor is an Executor that runs commands as subprocesses, and
+// records them in a transcript.  It can be shared by concurrent sequences.
+type RecordingExecutor struct {
+	mu         sync.Mutex
+	subst      substitutions
+	transcript Transcript
+}
+
+// NewRecordingExecutor returns a RecordingExecutor that replaces the values of
+// the given variables by ${NAME} in its transcript.
+func NewRecordingExecutor(vars map[string]string) *RecordingExecutor {
+	return &RecordingExecutor{subst: newSubstitutions(vars)}
+}
+
+// Execute implements Executor.
+func (r *RecordingExecutor) Execute(cmd *exec.Cmd, run func(*exec.Cmd) error) error {
+	e := r.subst.entry(cmd)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = teeOutput(cmd.Stdout, &stdout)
+	cmd.Stderr = teeOutput(cmd.Stderr, &stderr)
+	err := run(cmd)
+	e.Stdout, e.Stderr = r.subst.hide(stdout.String()), r.subst.hide(stderr.String())
+	if code, ok := exitCode(err); ok {
+		e.ExitCode = code
+	} else if err != nil {
+		e.Error = r.subst.hide(err.Error())
+	}
+	r.mu.Lock()
+	r.transcript = append(r.transcript, e)
+	r.mu.Unlock()
+	return err
+}
+
+// Transcript returns the commands recorded so far.
+func (r *RecordingExecutor) Transcript() Transcript {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append(Transcript(nil), r.transcript...)
+}
+
+// teeOutput returns a writer that writes to both w and buf, where w is the
+// stdout or stderr of a command.  The output of commands that write to a file,
+// such as a terminal, isn't recorded.
+func teeOutput(w io.Writer, buf *bytes.Buffer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	if _, ok := w.(*os.File); ok {
+		return w
+	}
+	return io.MultiWriter(w, buf)
+}
+
+// exitCode returns the exit code of a command that failed with the given
+// error, if it has one.
+func exitCode(err error) (int, bool) {
+	switch err := err.(type) {
+	case *ExitError:
+		return err.Code, true
+	case *exec.ExitError:
+		if status, ok := err.Sys().(syscall.WaitStatus); ok && status.Exited() {
+			return status.ExitStatus(), true
+		}
+	}
+	return 0, false
+}
+
+// ReplayExecutor is an Executor that doesn't run commands, but serves their
+// stdout, stderr and exit code from a transcript instead.  Each command is
+// matched, by its args and dir, with the first entry of the transcript that
+// hasn't been served yet and matches it, so that concurrent sequences may run
+// their commands in any order.  Commands that don't match any entry fail.  It
+// can be shared by concurrent sequences.
+type ReplayExecutor struct {
+	mu         sync.Mutex
+	subst      substitutions
+	transcript Transcript
+	used       []bool
+	unexpected []TranscriptEntry
+}
+
+// NewReplayExecutor returns a ReplayExecutor that serves the commands of the
+// given transcript, replacing ${NAME} in it with the values of the given
+// variables.
+func NewReplayExecutor(t Transcript, vars map[string]string) *ReplayExecutor {
+	return &ReplayExecutor{
+		subst:      newSubstitutions(vars),
+		transcript: t,
+		used:       make([]bool, len(t)),
+	}
+}
+
+// Execute implements Executor.
+func (r *ReplayExecutor) Execute(cmd *exec.Cmd, run func(*exec.Cmd) error) error {
+	want := r.subst.entry(cmd)
+	r.mu.Lock()
+	e, ok := r.match(want)
+	if !ok {
+		r.unexpected = append(r.unexpected, want)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unexpected command %s", formatEntry(want))
+	}
+	if cmd.Stdout != nil {
+		io.WriteString(cmd.Stdout, r.subst.expand(e.Stdout))
+	}
+	if cmd.Stderr != nil {
+		io.WriteString(cmd.Stderr, r.subst.expand(e.Stderr))
+	}
+	switch {
+	case e.Error != "":
+		return errors.New(r.subst.expand(e.Error))
+	case e.ExitCode != 0:
+		return &ExitError{Code: e.ExitCode}
+	}
+	return nil
+}
+
+// match marks the first unused entry of the transcript that has the args and
+// dir of want as used, and returns it.
+func (r *ReplayExecutor) match(want TranscriptEntry) (TranscriptEntry, bool) {
+	for i, e := range r.transcript {
+		if r.used[i] || e.Dir != want.Dir || !equalArgs(e.Args, want.Args) {
+			continue
+		}
+		r.used[i] = true
+		return e, true
+	}
+	return TranscriptEntry{}, false
+}
+
+// Done returns an error that lists the commands that didn't match the
+// transcript and the entries of the transcript that weren't served, if any.
+func (r *ReplayExecutor) Done() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lines []string
+	for _, e := range r.unexpected {
+		lines = append(lines, "unexpected command "+formatEntry(e))
+	}
+	for i, e := range r.transcript {
+		if !r.used[i] {
+			lines = append(lines, "missing command "+formatEntry(e))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+func equalArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func formatEntry(e TranscriptEntry) string {
+	if e.Dir == "" {
+		return fmt.Sprintf("%q", e.Args)
+	}
+	return fmt.Sprintf("%q in %s", e.Args, e.Dir)
+}
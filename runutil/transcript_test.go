@@ -0,0 +1,89 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runutil_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fuchsia.googlesource.com/jiri/cmdline"
+	"fuchsia.googlesource.com/jiri/runutil"
+)
+
+// runTranscriptCommands runs the commands of TestRecordReplay in dir, and
+// returns their output.
+func runTranscriptCommands(t *testing.T, e runutil.Executor, dir string) string {
+	seq := runutil.NewSequence(nil, nil, ioutil.Discard, ioutil.Discard, false, false).WithExecutor(e)
+	var stdout, stderr bytes.Buffer
+	if err := seq.Capture(&stdout, &stderr).Dir(dir).Last("sh", "-c", "echo out in "+dir+"; echo err >&2"); err != nil {
+		t.Fatal(err)
+	}
+	err := seq.Capture(&stdout, &stderr).Last("sh", "-c", "exit 2")
+	if got, want := runutil.TranslateExitCode(err), cmdline.ErrExitCode(2); got != want {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+	return stdout.String() + stderr.String()
+}
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	recorder := runutil.NewRecordingExecutor(map[string]string{"ROOT": dir})
+	recorded := runTranscriptCommands(t, recorder, dir)
+	want := runutil.Transcript{
+		{Args: []string{"sh", "-c", "echo out in ${ROOT}; echo err >&2"}, Dir: "${ROOT}", Stdout: "out in ${ROOT}\n", Stderr: "err\n"},
+		{Args: []string{"sh", "-c", "exit 2"}, ExitCode: 2},
+	}
+	if got := recorder.Transcript(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got transcript %#v, want %#v", got, want)
+	}
+	file := filepath.Join(dir, "testdata", "transcript.json")
+	if err := runutil.WriteTranscript(file, recorder.Transcript()); err != nil {
+		t.Fatal(err)
+	}
+	transcript, err := runutil.ReadTranscript(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(transcript, want) {
+		t.Errorf("got transcript %#v from %s, want %#v", transcript, file, want)
+	}
+
+	// The transcript is replayed in another directory, without running sh.
+	other := filepath.Join(dir, "other")
+	replayer := runutil.NewReplayExecutor(transcript, map[string]string{"ROOT": other})
+	if got, want := runTranscriptCommands(t, replayer, other), strings.Replace(recorded, dir, other, -1); got != want {
+		t.Errorf("got replayed output %q, want %q", got, want)
+	}
+	if err := replayer.Done(); err != nil {
+		t.Error(err)
+	}
+
+	// Commands that don't match the transcript fail, and are reported along
+	// with the commands of the transcript that didn't run.
+	replayer = runutil.NewReplayExecutor(transcript, map[string]string{"ROOT": other})
+	seq := runutil.NewSequence(nil, nil, ioutil.Discard, ioutil.Discard, false, false).WithExecutor(replayer)
+	if err := seq.Last("sh", "-c", "exit 3"); err == nil || !strings.Contains(err.Error(), `unexpected command ["sh" "-c" "exit 3"]`) {
+		t.Errorf("got error %v for an unexpected command", err)
+	}
+	if _, err := seq.Start("sh", "-c", "exit 2"); err == nil {
+		t.Errorf("Start succeeded with an Executor")
+	}
+	wantErr := `unexpected command ["sh" "-c" "exit 3"]
+missing command ["sh" "-c" "echo out in ${ROOT}; echo err >&2"] in ${ROOT}
+missing command ["sh" "-c" "exit 2"]`
+	if err := replayer.Done(); err == nil || err.Error() != wantErr {
+		t.Errorf("got error %v, want %v", err, wantErr)
+	}
+}
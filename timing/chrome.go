@@ -0,0 +1,126 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package timing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// ChromeTracePrinter writes Intervals in the Chrome Trace Event format, which
+// can be loaded in chrome://tracing or Perfetto (https://ui.perfetto.dev).
+// The intervals are written as the events of a single process, with one thread
+// per track, named after the first interval of the track.
+type ChromeTracePrinter struct {
+	// Zero is the absolute start time of the intervals, which is recorded in
+	// the metadata of the trace.  Typically this is set to Timer.Zero.
+	Zero time.Time
+	// Process is the name of the process.  Defaults to the name of the first
+	// interval if the value is empty.
+	Process string
+}
+
+// chromeEvent is an event of the Chrome Trace Event format.  Times are in
+// microseconds.
+type chromeEvent struct {
+	Name  string                 `json:"name"`
+	Phase string                 `json:"ph"`
+	Ts    float64                `json:"ts"`
+	Dur   float64                `json:"dur,omitempty"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// chromePid is the pid of the process of the events.
+const chromePid = 1
+
+func micros(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
+
+// Print writes the given intervals to w as a JSON trace, with a complete event
+// per interval.
+//
+// The time now is used as the end time for any open intervals, and is
+// represented as a duration from the zero time for the intervals;
+// e.g. use Timer.Now() for intervals collected by the Timer.
+func (p ChromeTracePrinter) Print(w io.Writer, intervals []Interval, now time.Duration) error {
+	if p.Process == "" && len(intervals) > 0 {
+		p.Process = intervals[0].Name
+	}
+	events := []chromeEvent{{
+		Name:  "process_name",
+		Phase: "M",
+		Pid:   chromePid,
+		Args:  map[string]interface{}{"name": p.Process},
+	}}
+	tracks := map[int]string{}
+	for _, i := range intervals {
+		if _, ok := tracks[i.Track]; !ok {
+			tracks[i.Track] = i.Name
+		}
+	}
+	var tids []int
+	for tid := range tracks {
+		tids = append(tids, tid)
+	}
+	sort.Ints(tids)
+	for _, tid := range tids {
+		events = append(events, chromeEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			Pid:   chromePid,
+			Tid:   tid,
+			Args:  map[string]interface{}{"name": tracks[tid]},
+		}, chromeEvent{
+			Name:  "thread_sort_index",
+			Phase: "M",
+			Pid:   chromePid,
+			Tid:   tid,
+			Args:  map[string]interface{}{"sort_index": tid},
+		})
+	}
+	for _, i := range intervals {
+		end := i.End
+		if end == InvalidDuration {
+			end = now
+		}
+		events = append(events, chromeEvent{
+			Name:  i.Name,
+			Phase: "X",
+			Ts:    micros(i.Start),
+			Dur:   micros(end - i.Start),
+			Pid:   chromePid,
+			Tid:   i.Track,
+		})
+	}
+	// Write one event per line, to keep traces readable and diffable.
+	if _, err := io.WriteString(w, "{\"traceEvents\": [\n"); err != nil {
+		return err
+	}
+	for index, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		sep := ","
+		if index == len(events)-1 {
+			sep = ""
+		}
+		if _, err := fmt.Fprintf(w, "  %s%s\n", data, sep); err != nil {
+			return err
+		}
+	}
+	zero, err := json.Marshal(p.Zero.Format(time.RFC3339Nano))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "],\n\"displayTimeUnit\": \"ms\",\n\"otherData\": {\"zero\": %s}}\n", zero)
+	return err
+}
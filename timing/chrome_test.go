@@ -0,0 +1,51 @@
+// Copyright 2016 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package timing
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChromeTracePrinter(t *testing.T) {
+	intervals := []Interval{
+		{"root", 0, sec(0), sec(10), 0},
+		{"A", 1, sec(1), sec(4), 0},
+		{"B", 1, sec(2), InvalidDuration, 1},
+		{"B1", 2, sec(3), sec(5), 1},
+	}
+	var buf bytes.Buffer
+	p := ChromeTracePrinter{Zero: time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := p.Print(&buf, intervals, sec(8)); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []chromeEvent
+		OtherData   map[string]string
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("invalid trace: %v\n%s", err, buf.String())
+	}
+	want := []chromeEvent{
+		{Name: "process_name", Phase: "M", Pid: 1, Args: map[string]interface{}{"name": "root"}},
+		{Name: "thread_name", Phase: "M", Pid: 1, Tid: 0, Args: map[string]interface{}{"name": "root"}},
+		{Name: "thread_sort_index", Phase: "M", Pid: 1, Tid: 0, Args: map[string]interface{}{"sort_index": 0.0}},
+		{Name: "thread_name", Phase: "M", Pid: 1, Tid: 1, Args: map[string]interface{}{"name": "B"}},
+		{Name: "thread_sort_index", Phase: "M", Pid: 1, Tid: 1, Args: map[string]interface{}{"sort_index": 1.0}},
+		{Name: "root", Phase: "X", Ts: 0, Dur: 10e6, Pid: 1, Tid: 0},
+		{Name: "A", Phase: "X", Ts: 1e6, Dur: 3e6, Pid: 1, Tid: 0},
+		{Name: "B", Phase: "X", Ts: 2e6, Dur: 6e6, Pid: 1, Tid: 1},
+		{Name: "B1", Phase: "X", Ts: 3e6, Dur: 2e6, Pid: 1, Tid: 1},
+	}
+	if !reflect.DeepEqual(trace.TraceEvents, want) {
+		t.Errorf("got events %+v, want %+v", trace.TraceEvents, want)
+	}
+	if got, want := trace.OtherData["zero"], "2016-01-02T03:04:05Z"; got != want {
+		t.Errorf("got zero %q, want %q", got, want)
+	}
+}
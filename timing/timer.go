@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,16 +30,26 @@ type Interval struct {
 	Name       string
 	Depth      int
 	Start, End time.Duration
+	// Track identifies the sequence of work the interval belongs to.
+	// Intervals on different tracks may overlap, e.g. when work is done
+	// concurrently.  Timer puts all intervals on track 0.
+	Track int
 }
 
 // Timer provides support for tracking a tree of strictly hierarchical time
-// intervals.  If you need to track overlapping time intervals, simply use
-// separate Timers.
+// intervals.
 //
 // Timer maintains a notion of a current interval, initialized to the root.  The
 // tree of intervals is constructed by push and pop operations, which add and
 // update intervals to the tree, while updating the currently referenced
-// interval.  Finish should be called to finish all timing.
+// interval.  Finish should be called to finish all timing.  Push, Pop and
+// Finish must not be called concurrently.
+//
+// Work done concurrently, e.g. by other goroutines, is timed with Timers
+// forked from the current interval by Fork, which may be called from any
+// goroutine.  The intervals of each forked Timer are on a track of their own,
+// and are merged into the tree as children of the interval they were forked
+// from once the forked Timer is finished.
 type Timer struct {
 	Zero      time.Time  // Absolute start time of the timer.
 	Intervals []Interval // List of intervals, in depth-first order.
@@ -46,6 +58,24 @@ type Timer struct {
 	// interval.  This makes it easy to determine the current interval, as well as
 	// pop up to the parent interval.  The root is never held in the stack.
 	stack []int
+
+	// mu guards the intervals and the stack against Fork, and against forked
+	// timers that merge their intervals.
+	mu sync.Mutex
+	// tracks counts the tracks of the timers forked from the same root.
+	tracks *int32
+	// track is the track of the intervals of the timer.
+	track int
+	// parent is the timer this one was forked from, and forkIndex the index
+	// of the interval of parent it was forked from.
+	parent    *Timer
+	forkIndex int
+	// forked holds the intervals of finished forked timers by the index of
+	// the open interval they were forked from, until it's closed.
+	forked map[int][][]Interval
+	// late holds the intervals of forked timers that finished after the
+	// interval they were forked from was closed.
+	late [][]Interval
 }
 
 // NewTimer returns a new Timer, with the root interval set to the given name.
@@ -55,13 +85,16 @@ func NewTimer(name string) *Timer {
 			Name: name,
 			End:  InvalidDuration,
 		}},
-		Zero: nowFunc(),
+		Zero:   nowFunc(),
+		tracks: new(int32),
 	}
 }
 
 // Push appends a child with the given name and an open interval to current, and
 // updates the current interval to refer to the newly created child.
 func (t *Timer) Push(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	depth := len(t.stack)
 	if depth == 0 {
 		// Unset the root end time, to handle Push after Finish.
@@ -69,9 +102,10 @@ func (t *Timer) Push(name string) {
 	}
 	t.Intervals = append(t.Intervals, Interval{
 		Name:  name,
-		Depth: depth + 1,
+		Depth: t.Intervals[0].Depth + depth + 1,
 		Start: t.Now(),
 		End:   InvalidDuration,
+		Track: t.track,
 	})
 	t.stack = append(t.stack, len(t.Intervals)-1)
 }
@@ -79,20 +113,103 @@ func (t *Timer) Push(name string) {
 // Pop closes the current interval, and updates the current interval to refer to
 // its parent.  Pop does nothing if the current interval is the root.
 func (t *Timer) Pop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	if last := len(t.stack) - 1; last >= 0 {
-		t.Intervals[t.stack[last]].End = t.Now()
+		index := t.stack[last]
+		t.Intervals[index].End = t.Now()
 		t.stack = t.stack[:last]
+		t.mergeForked(index)
 	}
 }
 
-// Finish finishes all timing, closing all intervals including the root.
+// Finish finishes all timing, closing all intervals including the root.  The
+// intervals of a forked timer are then merged into the timer it was forked
+// from; it must not be used afterwards.
 func (t *Timer) Finish() {
+	t.mu.Lock()
 	end := t.Now()
 	t.Intervals[0].End = end
-	for _, index := range t.stack {
-		t.Intervals[index].End = end
+	for last := len(t.stack) - 1; last >= 0; last-- {
+		t.Intervals[t.stack[last]].End = end
+		t.mergeForked(t.stack[last])
 	}
 	t.stack = t.stack[:0]
+	t.mergeForked(0)
+	for _, intervals := range t.late {
+		// Merge the intervals as children of the root, since the interval
+		// they were forked from is no longer the last of its depth.
+		delta := t.Intervals[0].Depth + 1 - intervals[0].Depth
+		for _, i := range intervals {
+			i.Depth += delta
+			t.Intervals = append(t.Intervals, i)
+		}
+	}
+	t.late = nil
+	parent, index, intervals := t.parent, t.forkIndex, t.Intervals
+	t.parent = nil
+	t.mu.Unlock()
+	if parent != nil {
+		parent.merge(index, intervals)
+	}
+}
+
+// Fork returns a new Timer for work done concurrently with the current
+// interval, with the root interval set to the given name, starting now.  The
+// intervals of the new Timer are on a new track.  Once the new Timer is
+// finished, its intervals are merged into t as children of the current
+// interval, when that interval is closed; if it's already closed, they're
+// merged as children of the root when t is finished.
+func (t *Timer) Fork(name string) *Timer {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	index := 0
+	if last := len(t.stack) - 1; last >= 0 {
+		index = t.stack[last]
+	}
+	if t.tracks == nil {
+		t.tracks = new(int32)
+	}
+	track := int(atomic.AddInt32(t.tracks, 1))
+	return &Timer{
+		Zero: t.Zero,
+		Intervals: []Interval{{
+			Name:  name,
+			Depth: t.Intervals[index].Depth + 1,
+			Start: t.Now(),
+			End:   InvalidDuration,
+			Track: track,
+		}},
+		tracks:    t.tracks,
+		track:     track,
+		parent:    t,
+		forkIndex: index,
+	}
+}
+
+// merge merges the intervals of a finished forked timer, which was forked from
+// the interval at the given index.
+func (t *Timer) merge(index int, intervals []Interval) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Intervals[index].End != InvalidDuration {
+		t.late = append(t.late, intervals)
+		return
+	}
+	if t.forked == nil {
+		t.forked = map[int][][]Interval{}
+	}
+	t.forked[index] = append(t.forked[index], intervals)
+}
+
+// mergeForked appends the intervals of the finished timers forked from the
+// interval at the given index, which has just been closed, and thus is the
+// last interval of its depth.
+func (t *Timer) mergeForked(index int) {
+	for _, intervals := range t.forked[index] {
+		t.Intervals = append(t.Intervals, intervals...)
+	}
+	delete(t.forked, index)
 }
 
 // Now returns the time now relative to timer.Zero.
@@ -102,6 +219,8 @@ func (t *Timer) Now() time.Duration {
 
 // String returns a formatted string describing the tree of time intervals.
 func (t *Timer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	var buf bytes.Buffer
 	IntervalPrinter{Zero: t.Zero}.Print(&buf, t.Intervals, t.Now())
 	return buf.String()
@@ -117,6 +236,12 @@ func (t *Timer) String() string {
 //    00:00:37.000       foo2       18.000s 00:00:55.000
 //    00:00:55.000    bar        25.000s    00:01:20.000
 //    00:01:20.000    baz        19.000s    00:01:39.000
+//
+// Intervals on a different track than their parent, i.e. the roots of forked
+// Timers, are labeled with their track, and gaps are only shown between
+// intervals on the same track.  If the intervals are on more than one track,
+// the printer follows them with the intervals on their critical path: of the
+// intervals that overlap, only the one that ended last is on it.
 type IntervalPrinter struct {
 	// Zero is the absolute start time to use for printing; all interval times are
 	// computed relative to the zero time.  Typically this is set to Timer.Zero to
@@ -179,6 +304,9 @@ type printer struct {
 	now       time.Duration
 	intervals []Interval
 	stack     []int
+	// parents holds the index of the parent of each interval, or -1 if its
+	// parent isn't printed.
+	parents []int
 
 	// Stats collected to help formatting.
 	nowLabel   string
@@ -190,14 +318,120 @@ type printer struct {
 
 func (p *printer) print() error {
 	p.stack = make([]int, 1)
+	p.parents = parentIndices(p.intervals)
 	p.collectStats()
-	return p.walkIntervals(p.printRow)
+	if err := p.walkIntervals(p.printRow); err != nil {
+		return err
+	}
+	if !p.concurrent() {
+		return nil
+	}
+	if _, err := fmt.Fprintln(p.w, "critical path:"); err != nil {
+		return err
+	}
+	for _, index := range p.criticalPath() {
+		i := p.intervals[index]
+		if err := p.printRow(p.label(index), i.Start, i.End, i.Depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parentIndices returns the index of the parent of each of the given
+// intervals, or -1 if its parent isn't in the list.
+func parentIndices(intervals []Interval) []int {
+	parents := make([]int, len(intervals))
+	var stack []int
+	for index, i := range intervals {
+		for len(stack) > 0 && intervals[stack[len(stack)-1]].Depth >= i.Depth {
+			stack = stack[:len(stack)-1]
+		}
+		parents[index] = -1
+		if len(stack) > 0 {
+			parents[index] = stack[len(stack)-1]
+		}
+		stack = append(stack, index)
+	}
+	return parents
+}
+
+// label returns the name to print for the interval at the given index, which
+// includes the track of the interval if it differs from that of its parent.
+func (p *printer) label(index int) string {
+	i, parentTrack := p.intervals[index], 0
+	if parent := p.parents[index]; parent != -1 {
+		parentTrack = p.intervals[parent].Track
+	}
+	if i.Track != parentTrack {
+		return fmt.Sprintf("%s [track %d]", i.Name, i.Track)
+	}
+	return i.Name
+}
+
+// concurrent returns whether the intervals are on more than one track.
+func (p *printer) concurrent() bool {
+	for _, i := range p.intervals {
+		if i.Track != p.intervals[0].Track {
+			return true
+		}
+	}
+	return false
+}
+
+// end returns the end of the interval at the given index, or now if it's open.
+func (p *printer) end(index int) time.Duration {
+	if end := p.intervals[index].End; end != InvalidDuration {
+		return end
+	}
+	return p.now
+}
+
+// criticalPath returns the indices of the intervals on the critical path, in
+// depth-first order.  Starting from the interval that ended last, the critical
+// path steps back to the interval that ended last before that one started,
+// and so on, and recursively does the same for the children of each interval
+// on the path.  Of concurrent intervals, only the one that ended last, which
+// the others had to wait for, is on the critical path.
+func (p *printer) criticalPath() []int {
+	children := make([][]int, len(p.intervals))
+	var top []int
+	for index, parent := range p.parents {
+		if parent == -1 {
+			top = append(top, index)
+		} else {
+			children[parent] = append(children[parent], index)
+		}
+	}
+	return p.criticalChain(children, top, time.Duration(1<<63-1))
+}
+
+// criticalChain returns the critical path through the given sibling intervals
+// that ends by the given time.
+func (p *printer) criticalChain(children [][]int, siblings []int, end time.Duration) []int {
+	var chain []int
+	used := map[int]bool{}
+	for {
+		last := -1
+		for _, index := range siblings {
+			if !used[index] && p.end(index) <= end && (last == -1 || p.end(index) > p.end(last)) {
+				last = index
+			}
+		}
+		if last == -1 {
+			return chain
+		}
+		used[last] = true
+		link := append([]int{last}, p.criticalChain(children, children[last], p.end(last))...)
+		chain = append(link, chain...)
+		end = p.intervals[last].Start
+	}
 }
 
 func (p *printer) walkIntervals(fn func(name string, start, end time.Duration, depth int) error) error {
 	stack := p.stack[:1]
 	stack[0] = 0
-	prev := Interval{"", p.intervals[0].Depth, 0, 0}
+	prev := Interval{"", p.intervals[0].Depth, 0, 0, 0}
 	for index, i := range p.intervals {
 		for i.Depth < prev.Depth && len(stack) > 1 {
 			// Handle the normal case for gaps based on pops, where we have a full
@@ -205,7 +439,7 @@ func (p *printer) walkIntervals(fn func(name string, start, end time.Duration, d
 			// new previous interval.
 			parent := p.intervals[stack[len(stack)-2]]
 			start, end := prev.End, parent.End
-			if gap := end - start; gap >= p.MinGap {
+			if gap := end - start; gap >= p.MinGap && prev.Track == parent.Track {
 				if err := fn("*", start, end, prev.Depth); err != nil {
 					return err
 				}
@@ -219,7 +453,7 @@ func (p *printer) walkIntervals(fn func(name string, start, end time.Duration, d
 			// subtree.  The gap end is based on the start of the current interval,
 			// and we update the stack to start with the current interval.
 			start, end := prev.End, i.Start
-			if gap := end - start; gap >= p.MinGap {
+			if gap := end - start; gap >= p.MinGap && prev.Track == i.Track {
 				if err := fn("*", start, end, prev.Depth); err != nil {
 					return err
 				}
@@ -228,7 +462,7 @@ func (p *printer) walkIntervals(fn func(name string, start, end time.Duration, d
 		case i.Depth == prev.Depth:
 			// Handle the regular case for gaps based on pop/push siblings.
 			start, end := prev.End, i.Start
-			if gap := end - start; gap >= p.MinGap {
+			if gap := end - start; gap >= p.MinGap && prev.Track == i.Track {
 				if err := fn("*", start, end, i.Depth); err != nil {
 					return err
 				}
@@ -237,7 +471,7 @@ func (p *printer) walkIntervals(fn func(name string, start, end time.Duration, d
 		case i.Depth > prev.Depth:
 			// Handle the regular case for gaps based on push children.
 			start, end := prev.Start, i.Start
-			if gap := end - start; gap >= p.MinGap {
+			if gap := end - start; gap >= p.MinGap && prev.Track == i.Track {
 				if err := fn("*", start, end, i.Depth); err != nil {
 					return err
 				}
@@ -245,7 +479,7 @@ func (p *printer) walkIntervals(fn func(name string, start, end time.Duration, d
 			stack = append(stack, index)
 		}
 		// Visit the current interval.
-		if err := fn(i.Name, i.Start, i.End, i.Depth); err != nil {
+		if err := fn(p.label(index), i.Start, i.End, i.Depth); err != nil {
 			return err
 		}
 		prev = i
@@ -254,7 +488,7 @@ func (p *printer) walkIntervals(fn func(name string, start, end time.Duration, d
 	for len(stack) > 1 {
 		parent := p.intervals[stack[len(stack)-2]]
 		start, end := prev.End, parent.End
-		if gap := end - start; gap >= p.MinGap {
+		if gap := end - start; gap >= p.MinGap && prev.Track == parent.Track {
 			if err := fn("*", start, end, prev.Depth); err != nil {
 				return err
 			}
@@ -10,6 +10,7 @@ import (
 	"math/rand"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -90,28 +91,28 @@ func TestTimer(t *testing.T) {
 	}{
 		{
 			nil,
-			[]Interval{{"root", 0, sec(0), InvalidDuration}},
+			[]Interval{{"root", 0, sec(0), InvalidDuration, 0}},
 			`
 00:00:01.000 root 999.000s ---------now
 `,
 		},
 		{
 			[]op{pop{123}},
-			[]Interval{{"root", 0, sec(0), InvalidDuration}},
+			[]Interval{{"root", 0, sec(0), InvalidDuration, 0}},
 			`
 00:00:01.000 root 999.000s ---------now
 `,
 		},
 		{
 			[]op{finish{99}},
-			[]Interval{{"root", 0, sec(0), sec(98)}},
+			[]Interval{{"root", 0, sec(0), sec(98), 0}},
 			`
 00:00:01.000 root 98.000s 00:01:39.000
 `,
 		},
 		{
 			[]op{finish{99}, pop{123}},
-			[]Interval{{"root", 0, sec(0), sec(98)}},
+			[]Interval{{"root", 0, sec(0), sec(98), 0}},
 			`
 00:00:01.000 root 98.000s 00:01:39.000
 `,
@@ -119,8 +120,8 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "abc"}},
 			[]Interval{
-				{"root", 0, sec(0), InvalidDuration},
-				{"abc", 1, sec(9), InvalidDuration},
+				{"root", 0, sec(0), InvalidDuration, 0},
+				{"abc", 1, sec(9), InvalidDuration, 0},
 			},
 			`
 00:00:01.000 root   999.000s    ---------now
@@ -131,8 +132,8 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "abc"}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"abc", 1, sec(9), sec(98)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"abc", 1, sec(9), sec(98), 0},
 			},
 			`
 00:00:01.000 root   98.000s    00:01:39.000
@@ -143,8 +144,8 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "abc"}, pop{20}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"abc", 1, sec(9), sec(19)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"abc", 1, sec(9), sec(19), 0},
 			},
 			`
 00:00:01.000 root   98.000s    00:01:39.000
@@ -156,9 +157,9 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}},
 			[]Interval{
-				{"root", 0, sec(0), InvalidDuration},
-				{"A1", 1, sec(9), InvalidDuration},
-				{"A1_1", 2, sec(19), InvalidDuration},
+				{"root", 0, sec(0), InvalidDuration, 0},
+				{"A1", 1, sec(9), InvalidDuration, 0},
+				{"A1_1", 2, sec(19), InvalidDuration, 0},
 			},
 			`
 00:00:01.000 root       999.000s       ---------now
@@ -171,9 +172,9 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"A1", 1, sec(9), sec(98)},
-				{"A1_1", 2, sec(19), sec(98)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"A1", 1, sec(9), sec(98), 0},
+				{"A1_1", 2, sec(19), sec(98), 0},
 			},
 			`
 00:00:01.000 root       98.000s       00:01:39.000
@@ -186,9 +187,9 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}, pop{30}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"A1", 1, sec(9), sec(98)},
-				{"A1_1", 2, sec(19), sec(29)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"A1", 1, sec(9), sec(98), 0},
+				{"A1_1", 2, sec(19), sec(29), 0},
 			},
 			`
 00:00:01.000 root       98.000s       00:01:39.000
@@ -202,9 +203,9 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}, pop{30}, pop{40}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"A1", 1, sec(9), sec(39)},
-				{"A1_1", 2, sec(19), sec(29)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"A1", 1, sec(9), sec(39), 0},
+				{"A1_1", 2, sec(19), sec(29), 0},
 			},
 			`
 00:00:01.000 root       98.000s       00:01:39.000
@@ -219,10 +220,10 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}, push{30, "A1_1_1"}},
 			[]Interval{
-				{"root", 0, sec(0), InvalidDuration},
-				{"A1", 1, sec(9), InvalidDuration},
-				{"A1_1", 2, sec(19), InvalidDuration},
-				{"A1_1_1", 3, sec(29), InvalidDuration},
+				{"root", 0, sec(0), InvalidDuration, 0},
+				{"A1", 1, sec(9), InvalidDuration, 0},
+				{"A1_1", 2, sec(19), InvalidDuration, 0},
+				{"A1_1_1", 3, sec(29), InvalidDuration, 0},
 			},
 			`
 00:00:01.000 root            999.000s          ---------now
@@ -237,10 +238,10 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}, push{30, "A1_1_1"}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"A1", 1, sec(9), sec(98)},
-				{"A1_1", 2, sec(19), sec(98)},
-				{"A1_1_1", 3, sec(29), sec(98)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"A1", 1, sec(9), sec(98), 0},
+				{"A1_1", 2, sec(19), sec(98), 0},
+				{"A1_1_1", 3, sec(29), sec(98), 0},
 			},
 			`
 00:00:01.000 root            98.000s          00:01:39.000
@@ -255,10 +256,10 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}, push{30, "A1_1_1"}, pop{40}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"A1", 1, sec(9), sec(98)},
-				{"A1_1", 2, sec(19), sec(98)},
-				{"A1_1_1", 3, sec(29), sec(39)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"A1", 1, sec(9), sec(98), 0},
+				{"A1_1", 2, sec(19), sec(98), 0},
+				{"A1_1_1", 3, sec(29), sec(39), 0},
 			},
 			`
 00:00:01.000 root            98.000s          00:01:39.000
@@ -274,10 +275,10 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}, push{30, "A1_1_1"}, pop{40}, pop{55}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"A1", 1, sec(9), sec(98)},
-				{"A1_1", 2, sec(19), sec(54)},
-				{"A1_1_1", 3, sec(29), sec(39)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"A1", 1, sec(9), sec(98), 0},
+				{"A1_1", 2, sec(19), sec(54), 0},
+				{"A1_1_1", 3, sec(29), sec(39), 0},
 			},
 			`
 00:00:01.000 root            98.000s          00:01:39.000
@@ -294,10 +295,10 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}, push{30, "A1_1_1"}, pop{40}, pop{55}, pop{75}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"A1", 1, sec(9), sec(74)},
-				{"A1_1", 2, sec(19), sec(54)},
-				{"A1_1_1", 3, sec(29), sec(39)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"A1", 1, sec(9), sec(74), 0},
+				{"A1_1", 2, sec(19), sec(54), 0},
+				{"A1_1_1", 3, sec(29), sec(39), 0},
 			},
 			`
 00:00:01.000 root            98.000s          00:01:39.000
@@ -315,10 +316,10 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}, finish{30}, push{40, "B1"}},
 			[]Interval{
-				{"root", 0, sec(0), InvalidDuration},
-				{"A1", 1, sec(9), sec(29)},
-				{"A1_1", 2, sec(19), sec(29)},
-				{"B1", 1, sec(39), InvalidDuration},
+				{"root", 0, sec(0), InvalidDuration, 0},
+				{"A1", 1, sec(9), sec(29), 0},
+				{"A1_1", 2, sec(19), sec(29), 0},
+				{"B1", 1, sec(39), InvalidDuration, 0},
 			},
 			`
 00:00:01.000 root       999.000s       ---------now
@@ -333,10 +334,10 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "A1"}, push{20, "A1_1"}, finish{30}, push{40, "B1"}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"A1", 1, sec(9), sec(29)},
-				{"A1_1", 2, sec(19), sec(29)},
-				{"B1", 1, sec(39), sec(98)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"A1", 1, sec(9), sec(29), 0},
+				{"A1_1", 2, sec(19), sec(29), 0},
+				{"B1", 1, sec(39), sec(98), 0},
 			},
 			`
 00:00:01.000 root       98.000s       00:01:39.000
@@ -351,12 +352,12 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "foo"}, push{15, "foo1"}, pop{37}, push{37, "foo2"}, pop{55}, pop{55}, push{55, "bar"}, pop{80}, push{80, "baz"}, pop{99}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"foo", 1, sec(9), sec(54)},
-				{"foo1", 2, sec(14), sec(36)},
-				{"foo2", 2, sec(36), sec(54)},
-				{"bar", 1, sec(54), sec(79)},
-				{"baz", 1, sec(79), sec(98)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"foo", 1, sec(9), sec(54), 0},
+				{"foo1", 2, sec(14), sec(36), 0},
+				{"foo2", 2, sec(36), sec(54), 0},
+				{"bar", 1, sec(54), sec(79), 0},
+				{"baz", 1, sec(79), sec(98), 0},
 			},
 			`
 00:00:01.000 root       98.000s       00:01:39.000
@@ -372,12 +373,12 @@ func TestTimer(t *testing.T) {
 		{
 			[]op{push{10, "foo"}, push{15, "foo1"}, pop{30}, push{37, "foo2"}, pop{50}, pop{53}, push{55, "bar"}, pop{75}, push{80, "baz"}, pop{90}, finish{99}},
 			[]Interval{
-				{"root", 0, sec(0), sec(98)},
-				{"foo", 1, sec(9), sec(52)},
-				{"foo1", 2, sec(14), sec(29)},
-				{"foo2", 2, sec(36), sec(49)},
-				{"bar", 1, sec(54), sec(74)},
-				{"baz", 1, sec(79), sec(89)},
+				{"root", 0, sec(0), sec(98), 0},
+				{"foo", 1, sec(9), sec(52), 0},
+				{"foo1", 2, sec(14), sec(29), 0},
+				{"foo2", 2, sec(36), sec(49), 0},
+				{"bar", 1, sec(54), sec(74), 0},
+				{"baz", 1, sec(79), sec(89), 0},
 			},
 			`
 00:00:01.000 root       98.000s       00:01:39.000
@@ -435,14 +436,14 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		str       string
 	}{
 		{
-			[]Interval{{"abc", 1, sec(9), InvalidDuration}},
+			[]Interval{{"abc", 1, sec(9), InvalidDuration, 0}},
 			`
 00:00:01.000 *     9.000s 00:00:10.000
 00:00:10.000 abc 990.000s ---------now
 `,
 		},
 		{
-			[]Interval{{"abc", 1, sec(9), sec(98)}},
+			[]Interval{{"abc", 1, sec(9), sec(98), 0}},
 			`
 00:00:01.000 *    9.000s 00:00:10.000
 00:00:10.000 abc 89.000s 00:01:39.000
@@ -450,8 +451,8 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1", 1, sec(9), InvalidDuration},
-				{"A1_1", 2, sec(19), InvalidDuration},
+				{"A1", 1, sec(9), InvalidDuration, 0},
+				{"A1_1", 2, sec(19), InvalidDuration, 0},
 			},
 			`
 00:00:01.000 *         9.000s    00:00:10.000
@@ -462,8 +463,8 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1", 1, sec(9), InvalidDuration},
-				{"A1_1", 2, sec(19), sec(49)},
+				{"A1", 1, sec(9), InvalidDuration, 0},
+				{"A1_1", 2, sec(19), sec(49), 0},
 			},
 			`
 00:00:01.000 *         9.000s    00:00:10.000
@@ -475,8 +476,8 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1", 1, sec(9), sec(98)},
-				{"A1_1", 2, sec(19), sec(49)},
+				{"A1", 1, sec(9), sec(98), 0},
+				{"A1_1", 2, sec(19), sec(49), 0},
 			},
 			`
 00:00:01.000 *        9.000s    00:00:10.000
@@ -488,8 +489,8 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1_1", 2, sec(9), sec(19)},
-				{"B1", 1, sec(39), InvalidDuration},
+				{"A1_1", 2, sec(9), sec(19), 0},
+				{"B1", 1, sec(39), InvalidDuration, 0},
 			},
 			`
 00:00:01.000    *         9.000s 00:00:10.000
@@ -500,8 +501,8 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1_1", 2, sec(9), sec(19)},
-				{"B1", 1, sec(39), sec(64)},
+				{"A1_1", 2, sec(9), sec(19), 0},
+				{"B1", 1, sec(39), sec(64), 0},
 			},
 			`
 00:00:01.000    *        9.000s 00:00:10.000
@@ -512,9 +513,9 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1_1", 2, sec(9), sec(19)},
-				{"B1", 1, sec(39), sec(64)},
-				{"C1", 1, sec(69), sec(84)},
+				{"A1_1", 2, sec(9), sec(19), 0},
+				{"B1", 1, sec(39), sec(64), 0},
+				{"C1", 1, sec(69), sec(84), 0},
 			},
 			`
 00:00:01.000    *        9.000s 00:00:10.000
@@ -527,9 +528,9 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1_1", 2, sec(9), sec(19)},
-				{"B1", 1, sec(39), sec(84)},
-				{"B1_1", 2, sec(64), sec(69)},
+				{"A1_1", 2, sec(9), sec(19), 0},
+				{"B1", 1, sec(39), sec(84), 0},
+				{"B1_1", 2, sec(64), sec(69), 0},
 			},
 			`
 00:00:01.000    *        9.000s 00:00:10.000
@@ -543,10 +544,10 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1_1", 2, sec(9), sec(19)},
-				{"B1", 1, sec(39), sec(89)},
-				{"B1_1", 2, sec(64), sec(69)},
-				{"B1_2", 2, sec(79), sec(87)},
+				{"A1_1", 2, sec(9), sec(19), 0},
+				{"B1", 1, sec(39), sec(89), 0},
+				{"B1_1", 2, sec(64), sec(69), 0},
+				{"B1_2", 2, sec(79), sec(87), 0},
 			},
 			`
 00:00:01.000    *        9.000s 00:00:10.000
@@ -562,8 +563,8 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1_1_1", 3, sec(9), sec(19)},
-				{"B1", 1, sec(39), InvalidDuration},
+				{"A1_1_1", 3, sec(9), sec(19), 0},
+				{"B1", 1, sec(39), InvalidDuration, 0},
 			},
 			`
 00:00:01.000       *              9.000s 00:00:10.000
@@ -574,8 +575,8 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1_1_1", 3, sec(9), sec(19)},
-				{"B1", 1, sec(39), sec(64)},
+				{"A1_1_1", 3, sec(9), sec(19), 0},
+				{"B1", 1, sec(39), sec(64), 0},
 			},
 			`
 00:00:01.000       *             9.000s 00:00:10.000
@@ -586,9 +587,9 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1_1_1", 3, sec(9), sec(19)},
-				{"B1", 1, sec(39), sec(64)},
-				{"C1", 1, sec(69), sec(84)},
+				{"A1_1_1", 3, sec(9), sec(19), 0},
+				{"B1", 1, sec(39), sec(64), 0},
+				{"C1", 1, sec(69), sec(84), 0},
 			},
 			`
 00:00:01.000       *             9.000s 00:00:10.000
@@ -601,9 +602,9 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1_1_1", 3, sec(9), sec(19)},
-				{"B1", 1, sec(39), sec(84)},
-				{"B1_1", 2, sec(59), sec(69)},
+				{"A1_1_1", 3, sec(9), sec(19), 0},
+				{"B1", 1, sec(39), sec(84), 0},
+				{"B1_1", 2, sec(59), sec(69), 0},
 			},
 			`
 00:00:01.000       *             9.000s 00:00:10.000
@@ -617,10 +618,10 @@ func TestIntervalPrinterCornerCases(t *testing.T) {
 		},
 		{
 			[]Interval{
-				{"A1_1", 2, sec(9), sec(84)},
-				{"A1_1_1", 3, sec(39), sec(79)},
-				{"A1_1_1_1", 4, sec(54), sec(69)},
-				{"B1", 1, sec(89), sec(99)},
+				{"A1_1", 2, sec(9), sec(84), 0},
+				{"A1_1_1", 3, sec(39), sec(79), 0},
+				{"A1_1_1_1", 4, sec(54), sec(69), 0},
+				{"B1", 1, sec(89), sec(99), 0},
 			},
 			`
 00:00:01.000    *                  9.000s       00:00:10.000
@@ -713,3 +714,130 @@ func BenchmarkTimerString(b *testing.B) {
 	}
 	nowFunc = time.Now
 }
+
+func TestTimerFork(t *testing.T) {
+	f := &fakeNow{0}
+	nowFunc = f.Now
+	defer func() { nowFunc = time.Now }()
+	timer := NewTimer("root")
+	f.now = 1
+	timer.Push("update")
+	f.now = 2
+	a, b := timer.Fork("a"), timer.Fork("b")
+	f.now = 3
+	a.Push("fetch a")
+	b.Push("fetch b")
+	f.now = 5
+	a.Pop()
+	f.now = 6
+	a.Finish()
+	late := timer.Fork("late")
+	f.now = 8
+	b.Finish()
+	f.now = 9
+	timer.Pop()
+	f.now = 11
+	late.Finish()
+	f.now = 12
+	timer.Finish()
+
+	want := []Interval{
+		{"root", 0, sec(0), sec(12), 0},
+		{"update", 1, sec(1), sec(9), 0},
+		{"a", 2, sec(2), sec(6), 1},
+		{"fetch a", 3, sec(3), sec(5), 1},
+		{"b", 2, sec(2), sec(8), 2},
+		{"fetch b", 3, sec(3), sec(8), 2},
+		{"late", 1, sec(6), sec(11), 3},
+	}
+	if got := timer.Intervals; !reflect.DeepEqual(got, want) {
+		t.Errorf("got intervals %v, want %v", got, want)
+	}
+	var buf bytes.Buffer
+	if err := (IntervalPrinter{}).Print(&buf, timer.Intervals, sec(12)); err != nil {
+		t.Fatal(err)
+	}
+	wantStr := `00:00:00.000 root              12.000s          00:00:12.000
+00:00:00.000    *                  1.000s       00:00:01.000
+00:00:01.000    update             8.000s       00:00:09.000
+00:00:02.000       a [track 1]        4.000s    00:00:06.000
+00:00:02.000          *                  1.000s 00:00:03.000
+00:00:03.000          fetch a            2.000s 00:00:05.000
+00:00:05.000          *                  1.000s 00:00:06.000
+00:00:02.000       b [track 2]        6.000s    00:00:08.000
+00:00:02.000          *                  1.000s 00:00:03.000
+00:00:03.000          fetch b            5.000s 00:00:08.000
+00:00:06.000    late [track 3]     5.000s       00:00:11.000
+critical path:
+00:00:00.000 root              12.000s          00:00:12.000
+00:00:06.000    late [track 3]     5.000s       00:00:11.000
+`
+	if got := buf.String(); got != wantStr {
+		t.Errorf("GOT PRINT\n%sWANT\n%s", got, wantStr)
+	}
+
+	// Of the concurrent tracks, the one that ended last is on the critical
+	// path.
+	buf.Reset()
+	if err := (IntervalPrinter{MinGap: sec(10)}).Print(&buf, timer.Intervals[1:6], sec(12)); err != nil {
+		t.Fatal(err)
+	}
+	wantStr = `00:00:01.000 update         8.000s       00:00:09.000
+00:00:02.000    a [track 1]    4.000s    00:00:06.000
+00:00:03.000       fetch a        2.000s 00:00:05.000
+00:00:02.000    b [track 2]    6.000s    00:00:08.000
+00:00:03.000       fetch b        5.000s 00:00:08.000
+critical path:
+00:00:01.000 update         8.000s       00:00:09.000
+00:00:02.000    b [track 2]    6.000s    00:00:08.000
+00:00:03.000       fetch b        5.000s 00:00:08.000
+`
+	if got := buf.String(); got != wantStr {
+		t.Errorf("GOT PRINT\n%sWANT\n%s", got, wantStr)
+	}
+}
+
+func TestTimerForkConcurrent(t *testing.T) {
+	timer := NewTimer("root")
+	timer.Push("work")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fork := timer.Fork(fmt.Sprintf("task %d", i))
+			for j := 0; j < 10; j++ {
+				fork.Push(fmt.Sprintf("step %d", j))
+				fork.Fork("subtask").Finish()
+				fork.Pop()
+			}
+			fork.Finish()
+		}(i)
+	}
+	wg.Wait()
+	timer.Pop()
+	timer.Finish()
+	if got, want := len(timer.Intervals), 2+10*(1+10*2); got != want {
+		t.Fatalf("got %d intervals, want %d", got, want)
+	}
+	// Each task is a child of "work", followed by its own steps.
+	tracks := map[int]bool{}
+	for index, i := range timer.Intervals[2:] {
+		switch {
+		case strings.HasPrefix(i.Name, "task"):
+			if i.Depth != 2 || tracks[i.Track] {
+				t.Errorf("interval %d: got %v", index+2, i)
+			}
+			tracks[i.Track] = true
+		case strings.HasPrefix(i.Name, "step"):
+			if i.Depth != 3 {
+				t.Errorf("interval %d: got %v", index+2, i)
+			}
+		case i.Name == "subtask":
+			if i.Depth != 4 || tracks[i.Track] {
+				t.Errorf("interval %d: got %v", index+2, i)
+			}
+			tracks[i.Track] = true
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package tool
 
 import (
+	"context"
 	"io"
 	"net/url"
 	"os"
@@ -34,6 +35,14 @@ type ContextOpts struct {
 	Stderr   io.Writer
 	Verbose  *bool
 	Timer    *timing.Timer
+	// Ctx cancels the commands run by the sequences of the context.
+	Ctx context.Context
+	// Trace, if not nil, records the commands run by the sequences of the
+	// context.
+	Trace *runutil.Trace
+	// Executor, if not nil, runs the commands of the sequences of the
+	// context in place of starting subprocesses; see runutil.Executor.
+	Executor runutil.Executor
 }
 
 // newContextOpts is the ContextOpts factory.
@@ -47,6 +56,7 @@ func newContextOpts() *ContextOpts {
 		Stderr:   os.Stderr,
 		Verbose:  &VerboseFlag,
 		Timer:    nil,
+		Ctx:      context.Background(),
 	}
 }
 
@@ -76,6 +86,15 @@ func initOpts(defaultOpts, opts *ContextOpts) {
 	if opts.Timer == nil {
 		opts.Timer = defaultOpts.Timer
 	}
+	if opts.Ctx == nil {
+		opts.Ctx = defaultOpts.Ctx
+	}
+	if opts.Trace == nil {
+		opts.Trace = defaultOpts.Trace
+	}
+	if opts.Executor == nil {
+		opts.Executor = defaultOpts.Executor
+	}
 }
 
 // NewContext is the Context factory.
@@ -138,7 +157,22 @@ func (ctx Context) Manifest() string {
 // NewSeq returns a new instance of Sequence initialized using the options
 // stored in the context.
 func (ctx Context) NewSeq() runutil.Sequence {
-	return runutil.NewSequence(ctx.opts.Env, ctx.opts.Stdin, ctx.opts.Stdout, ctx.opts.Stderr, *ctx.opts.Color, *ctx.opts.Verbose)
+	return runutil.NewSequence(ctx.opts.Env, ctx.opts.Stdin, ctx.opts.Stdout, ctx.opts.Stderr, *ctx.opts.Color, *ctx.opts.Verbose).
+		WithContext(ctx.opts.Ctx).
+		WithTrace(ctx.opts.Trace).
+		WithExecutor(ctx.opts.Executor)
+}
+
+// Trace returns the trace that records the commands run by the sequences of
+// the context, which may be nil.
+func (ctx Context) Trace() *runutil.Trace {
+	return ctx.opts.Trace
+}
+
+// Ctx returns the context that cancels the commands run by the sequences of
+// the context.
+func (ctx Context) Ctx() context.Context {
+	return ctx.opts.Ctx
 }
 
 // Stdin returns the standard input of the context.
@@ -179,3 +213,20 @@ func (ctx Context) TimerPop() {
 		ctx.opts.Timer.Pop()
 	}
 }
+
+// TimerFork returns ctx.Timer().Fork(name), or nil if the Timer is nil.  The
+// returned timer is typically used by a clone of the context for work done
+// concurrently, which calls TimerFinish once the work is done.
+func (ctx Context) TimerFork(name string) *timing.Timer {
+	if ctx.opts.Timer != nil {
+		return ctx.opts.Timer.Fork(name)
+	}
+	return nil
+}
+
+// TimerFinish calls ctx.Timer().Finish(), only if the Timer is non-nil.
+func (ctx Context) TimerFinish() {
+	if ctx.opts.Timer != nil {
+		ctx.opts.Timer.Finish()
+	}
+}
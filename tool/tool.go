@@ -17,6 +17,8 @@ var Name string = ""
 var (
 	// Flags for running commands.
 	ColorFlag   bool
+	OfflineFlag bool
+	TraceFlag   bool
 	VerboseFlag bool
 
 	// Flags for working with projects.
@@ -26,6 +28,8 @@ var (
 // InitializeRunFlags initializes flags for running commands.
 func InitializeRunFlags(flags *flag.FlagSet) {
 	flags.BoolVar(&ColorFlag, "color", true, "Use color to format output.")
+	flags.BoolVar(&OfflineFlag, "offline", false, "Don't access the network; use only local objects.")
+	flags.BoolVar(&TraceFlag, "trace", false, "Record the commands that are run in a trace log; see \"jiri help logs\".")
 	flags.BoolVar(&VerboseFlag, "v", false, "Print verbose output.")
 }
 
@@ -12,9 +12,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"fuchsia.googlesource.com/jiri/cmdline"
 	"fuchsia.googlesource.com/jiri/envvar"
+	"fuchsia.googlesource.com/jiri/runutil"
 	"fuchsia.googlesource.com/jiri/timing"
 	"fuchsia.googlesource.com/jiri/tool"
 )
@@ -30,6 +32,16 @@ const (
 	// non-empty value, causes jiri tools to use the existing PATH variable,
 	// rather than mutating it.
 	PreservePathEnv = "JIRI_PRESERVE_PATH"
+
+	// OfflineEnv is the name of the environment variable that, when set to a
+	// true boolean value, e.g. "1" or "true", puts jiri tools in offline mode,
+	// as if the -offline flag was given.
+	OfflineEnv = "JIRI_OFFLINE"
+
+	// TraceLogMaxSize is the size in bytes beyond which the trace log is
+	// rotated, and TraceLogKeep is the number of rotated files that are kept.
+	TraceLogMaxSize = 4 << 20
+	TraceLogKeep    = 3
 )
 
 // X holds the execution environment for the jiri tool and related tools.  This
@@ -41,6 +53,13 @@ type X struct {
 	*tool.Context
 	Root  string
 	Usage func(format string, args ...interface{}) error
+	// Offline is true if jiri must not access the network.  Git operations
+	// use only local objects, and operations that can't be performed without
+	// the network fail.
+	Offline bool
+	// Config holds the settings of the root config file, merged over those of
+	// the user config file.
+	Config *Config
 }
 
 // NewX returns a new execution environment, given a cmdline env.
@@ -51,10 +70,40 @@ func NewX(env *cmdline.Env) (*X, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newX(ctx, env, root)
+}
+
+// NewXForRoot returns a new execution environment for the given root directory,
+// rather than the one specified by the environment.  The root need not exist
+// yet; this is used to create new roots.
+func NewXForRoot(env *cmdline.Env, root string) (*X, error) {
+	return newX(tool.NewContextFromEnv(env), env, root)
+}
+
+func newX(ctx *tool.Context, env *cmdline.Env, root string) (*X, error) {
+	// Stop the commands run by the sequences of the X on SIGINT and SIGTERM.
+	ctx = ctx.Clone(tool.ContextOpts{Ctx: interruptContext()})
+	offline := tool.OfflineFlag
+	if value := ctx.Env()[OfflineEnv]; value != "" {
+		envOffline, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q of $%v: must be a boolean", value, OfflineEnv)
+		}
+		offline = offline || envOffline
+	}
 	x := &X{
 		Context: ctx,
 		Root:    root,
 		Usage:   env.UsageErrorf,
+		Offline: offline,
+	}
+	config, err := LoadConfigs(root)
+	if err != nil {
+		return nil, err
+	}
+	x.Config = config
+	if tool.TraceFlag {
+		x.Context = x.Context.Clone(tool.ContextOpts{Trace: x.TraceLog()})
 	}
 	if ctx.Env()[PreservePathEnv] == "" {
 		// Prepend $JIRI_ROOT/.jiri_root/bin to the PATH, so execing a binary will
@@ -118,9 +167,20 @@ func (x *X) Clone(opts tool.ContextOpts) *X {
 		Context: x.Context.Clone(opts),
 		Root:    x.Root,
 		Usage:   x.Usage,
+		Offline: x.Offline,
+		Config:  x.Config,
 	}
 }
 
+// RequireOnline returns an error if x is in offline mode.  The operation
+// describes what requires network access, e.g. "cl upload".
+func (x *X) RequireOnline(operation string) error {
+	if x.Offline {
+		return fmt.Errorf("%v requires network access, which is disabled in offline mode", operation)
+	}
+	return nil
+}
+
 // UsageErrorf prints the error message represented by the printf-style format
 // and args, followed by the usage output.  The implementation typically calls
 // cmdline.Env.UsageErrorf.
@@ -141,6 +201,25 @@ func (x *X) JiriManifestFile() string {
 	return filepath.Join(x.Root, JiriManifestFile)
 }
 
+// ConfigFile returns the path to the root config file.
+func (x *X) ConfigFile() string {
+	return filepath.Join(x.RootMetaDir(), ConfigFile)
+}
+
+// CacheDir returns the path to the directory holding the caches of the root,
+// which may be set in the root config file.
+func (x *X) CacheDir() string {
+	if x.Config != nil {
+		if dir, ok := x.Config.Get(CacheDirKey); ok && dir != "" {
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(x.Root, dir)
+			}
+			return dir
+		}
+	}
+	return x.RootMetaDir()
+}
+
 // BinDir returns the path to the bin directory.
 func (x *X) BinDir() string {
 	return filepath.Join(x.RootMetaDir(), "bin")
@@ -151,6 +230,18 @@ func (x *X) ScriptsDir() string {
 	return filepath.Join(x.RootMetaDir(), "scripts")
 }
 
+// LogsDir returns the path to the directory of logs.
+func (x *X) LogsDir() string {
+	return filepath.Join(x.RootMetaDir(), "logs")
+}
+
+// TraceLog returns the trace that the -trace flag records the commands run by
+// jiri in.  Its file is rotated once it grows beyond TraceLogMaxSize, keeping
+// TraceLogKeep rotated files.
+func (x *X) TraceLog() *runutil.Trace {
+	return runutil.NewTrace(filepath.Join(x.LogsDir(), "trace.jsonl"), TraceLogMaxSize, TraceLogKeep)
+}
+
 // UpdateHistoryDir returns the path to the update history directory.
 func (x *X) UpdateHistoryDir() string {
 	return filepath.Join(x.RootMetaDir(), "update_history")